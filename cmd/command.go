@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/azalio/tg-summary/internal/storage"
+)
+
+const usage = `usage:
+  tg-summary                              run the service
+  tg-summary outbox list [-status S] [-limit N]
+                                          show outbox items; S is pending, sent, dead (default) or all
+  tg-summary outbox retry ID...           requeue dead-lettered items
+  tg-summary search [-chat ID] [-author ID] [-from DATE] [-to DATE] [-limit N] QUERY...
+                                          full-text search over stored messages; DATE is YYYY-MM-DD
+  tg-summary migrate status               show schema migrations
+  tg-summary migrate up [VERSION]         apply migrations up to VERSION (default: all)
+  tg-summary migrate down [STEPS]         revert the last STEPS migrations (default: 1)
+  tg-summary migrate verify               check applied migrations against their checksums`
+
+// runCommand executes a maintenance command instead of the service.
+// Every command except migrate brings the schema up to date first.
+func runCommand(ctx context.Context, w io.Writer, store *storage.GormStorage, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return migrateCommand(ctx, w, store, args[1:])
+	case "outbox":
+		if err := store.Init(ctx); err != nil {
+			return err
+		}
+		return outboxCommand(ctx, w, store, args[1:])
+	case "search":
+		if err := store.Init(ctx); err != nil {
+			return err
+		}
+		return searchCommand(ctx, w, store, args[1:])
+	case "help", "-h", "--help":
+		fmt.Fprintln(w, usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
+
+func outboxCommand(ctx context.Context, w io.Writer, store storage.Storage, args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("outbox list", flag.ContinueOnError)
+		fs.SetOutput(w)
+		status := fs.String("status", storage.OutboxDead, "pending, sent, dead or all")
+		limit := fs.Int("limit", 50, "maximum number of items")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		filter := *status
+		switch filter {
+		case "all":
+			filter = ""
+		case storage.OutboxPending, storage.OutboxSent, storage.OutboxDead:
+		default:
+			return fmt.Errorf("unknown status %q", filter)
+		}
+		items, err := store.ListOutbox(ctx, filter, *limit)
+		if err != nil {
+			return err
+		}
+		printOutbox(w, items)
+		return nil
+	case "retry":
+		if len(args) < 2 {
+			return errors.New("outbox retry: item IDs required")
+		}
+		for _, arg := range args[1:] {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid item ID %q", arg)
+			}
+			ok, err := store.RequeueOutbox(ctx, id, time.Now())
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("item %d not found or not dead-lettered", id)
+			}
+			fmt.Fprintf(w, "item %d requeued\n", id)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown outbox command %q\n%s", args[0], usage)
+	}
+}
+
+func searchCommand(ctx context.Context, w io.Writer, store storage.Storage, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	fs.SetOutput(w)
+	chatID := fs.Int64("chat", 0, "only this chat")
+	authorID := fs.Int64("author", 0, "only this author (user ID)")
+	from := fs.String("from", "", "first day, YYYY-MM-DD")
+	to := fs.String("to", "", "last day (inclusive), YYYY-MM-DD")
+	limit := fs.Int("limit", 20, "maximum number of messages")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("search: query required")
+	}
+	filter := storage.SearchFilter{ChatID: *chatID, AuthorID: *authorID, Limit: *limit}
+	var err error
+	if filter.From, err = parseDay(*from); err != nil {
+		return err
+	}
+	if filter.To, err = parseDay(*to); err != nil {
+		return err
+	}
+	if !filter.To.IsZero() {
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+	results, err := store.SearchMessages(ctx, strings.Join(fs.Args(), " "), filter)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(w, "nothing found")
+	}
+	for _, r := range results {
+		author := r.Author.DisplayName
+		if author == "" {
+			author = strconv.FormatInt(r.AuthorID, 10)
+		}
+		fmt.Fprintf(w, "%s  chat %d  message %d  %s\n  %s\n",
+			time.Unix(r.Timestamp, 0).Format(time.DateTime), r.ChatID, r.MessageID, author,
+			strings.ReplaceAll(r.Snippet, "\n", " "))
+	}
+	return nil
+}
+
+// parseDay parses YYYY-MM-DD as the start of that day in local time; "" is the zero time.
+func parseDay(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, want YYYY-MM-DD", s)
+	}
+	return t, nil
+}
+
+func migrateCommand(ctx context.Context, w io.Writer, store *storage.GormStorage, args []string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New(usage)
+	}
+	m, err := store.Migrator()
+	if err != nil {
+		return err
+	}
+	n := 0
+	if len(args) == 2 {
+		if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+			return fmt.Errorf("invalid number %q", args[1])
+		}
+	}
+	switch args[0] {
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printMigrations(w, statuses)
+		return m.Verify(ctx)
+	case "up":
+		if n > m.Latest() {
+			return fmt.Errorf("unknown version %d, latest is %d", n, m.Latest())
+		}
+		done, err := m.Up(ctx, n)
+		for _, mig := range done {
+			fmt.Fprintf(w, "applied %04d_%s\n", mig.Version, mig.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Fprintln(w, "schema is up to date")
+		}
+		return err
+	case "down":
+		done, err := m.Down(ctx, max(n, 1))
+		for _, mig := range done {
+			fmt.Fprintf(w, "reverted %04d_%s\n", mig.Version, mig.Name)
+		}
+		return err
+	case "verify":
+		if err := m.Verify(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "ok")
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], usage)
+	}
+}
+
+func printMigrations(w io.Writer, statuses []storage.MigrationStatus) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+	for _, st := range statuses {
+		applied := "-"
+		if st.Applied() {
+			applied = st.AppliedAt.Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\n", st.Version, st.Name, applied)
+	}
+	tw.Flush()
+}
+
+func printOutbox(w io.Writer, items []storage.OutboxItem) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tCHANNEL\tCHAT\tATTEMPTS\tUPDATED\tNEXT ATTEMPT\tLAST ERROR")
+	for _, it := range items {
+		next := "-"
+		if it.Status == storage.OutboxPending {
+			next = time.Unix(it.NextAttemptAt, 0).Format(time.DateTime)
+		}
+		lastErr := strings.ReplaceAll(it.LastError, "\n", " ")
+		if r := []rune(lastErr); len(r) > 120 {
+			lastErr = string(r[:119]) + "…"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%d\t%s\t%s\t%s\n",
+			it.ID, it.Status, it.Channel, it.ChatID, it.Attempts,
+			time.Unix(it.UpdatedAt, 0).Format(time.DateTime), next, lastErr)
+	}
+	tw.Flush()
+}
@@ -3,12 +3,19 @@ package main
 import (
 	"context"
 	"log" // Standard logger only for initial fatal error during logger setup
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata" // IANA timezones for hosts and containers without zoneinfo
 
 	"go.uber.org/zap"
 
+	"github.com/azalio/tg-summary/internal/collector"
 	"github.com/azalio/tg-summary/internal/config"
 	"github.com/azalio/tg-summary/internal/delivery"
 	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/pipeline"
 	"github.com/azalio/tg-summary/internal/scheduler"
 	"github.com/azalio/tg-summary/internal/storage"
 	"github.com/azalio/tg-summary/internal/summarizer"
@@ -22,7 +29,7 @@ func main() {
 	defer cleanup()                 // Ensure logs are flushed on exit
 	logger.Info("tg-summary service starting...")
 
-	// --- Initialize components (using production stubs) ---
+	// --- Initialize components ---
 	logger.Info("Initializing components...")
 
 	// --- Load config ---
@@ -31,81 +38,242 @@ func main() {
 		logger.Fatal("Failed to load config", zap.Error(err))
 	}
 
+	msgStorage, err := storage.Open(cfg.DatabaseURL)
+	if err != nil {
+		logger.Fatal("Failed to initialize storage", zap.Error(err))
+	}
+	defer msgStorage.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Служебные команды (например, `tg-summary outbox list`) работают только с базой.
+	// Они запускаются до миграций, чтобы `migrate` мог управлять ими сам.
+	if args := os.Args[1:]; len(args) > 0 {
+		if err := runCommand(ctx, os.Stdout, msgStorage, args); err != nil {
+			logger.Fatal("Command failed", zap.Strings("args", args), zap.Error(err))
+		}
+		return
+	}
+
+	if err := msgStorage.Init(ctx); err != nil {
+		logger.Fatal("Failed to migrate storage", zap.Error(err))
+	}
+
 	// --- Pass config to components ---
 	tgClient, err := telegram.NewRealTelegramClient(logger.Named("telegram"), cfg)
 	if err != nil {
 		logger.Fatal("Failed to initialize Telegram client", zap.Error(err))
 	}
-	msgStorage, err := storage.NewGormStorage(cfg.SqlitePath)
+	msgCollector := collector.NewCollector(logger.Named("collector"), tgClient, msgStorage, cfg.CollectWindow, cfg.CollectRefresh)
+	if cfg.RealtimeUpdates {
+		tgClient.SetUpdateSink(collector.NewIngestor(logger.Named("ingestor"), msgStorage, cfg.TrackedChatIDs))
+	}
+	llmSummarizer := newSummarizer(logger, cfg)
+	// Дайджесты сначала пишутся в outbox, доставка идёт из него с повторами
+	outbox := newOutbox(logger, cfg, tgClient, msgStorage)
+	digestPipeline := pipeline.NewPipeline(logger.Named("pipeline"), cfg, msgCollector, msgStorage, llmSummarizer, outbox)
+	catchUp, err := scheduler.ParseCatchUpPolicy(cfg.CatchUp)
 	if err != nil {
-		logger.Fatal("Failed to initialize storage", zap.Error(err))
+		logger.Fatal("Invalid SCHEDULER_CATCH_UP", zap.Error(err))
 	}
-	_ = msgStorage                                     // avoid unused variable warning
-	llmSummarizer := summarizer.NewOpenAISummarizer()  // TODO: Pass logger/config if needed
-	digestSender := delivery.NewTelegramDigestSender() // TODO: Pass logger/config if needed
-	taskScheduler := scheduler.NewCronScheduler()      // TODO: Pass logger/config if needed
-
-	// --- Basic check of component linkage ---
-	ctx := context.Background()
+	taskScheduler := scheduler.NewCronScheduler(logger.Named("scheduler"), msgStorage, catchUp)
 
-	// Telegram Client Check + бизнес-логика внутри client.Run
+	// Вся работа с Telegram идёт внутри client.Run: сессия живёт, пока сервис не остановлен.
 	err = tgClient.Run(ctx, func(ctx context.Context, client *telegramtd.Client) error {
 		logger.Info("Telegram client authorized (session is alive)")
 
-		// Получить список групп
-		groups, err := tgClient.ListGroups(ctx, client)
+		// Доставка из outbox, в том числе недоставленного до перезапуска
+		dispatchCtx, cancelDispatch := context.WithCancel(ctx)
+		dispatched := make(chan struct{})
+		go func() {
+			defer close(dispatched)
+			outbox.Run(dispatchCtx)
+		}()
+		defer func() {
+			cancelDispatch()
+			<-dispatched
+		}()
+
+		listChats := func(ctx context.Context) ([]telegram.GroupInfo, error) {
+			groups, err := tgClient.ListGroups(ctx, client)
+			if err != nil {
+				return nil, err
+			}
+			return trackedGroups(groups, cfg.TrackedChatIDs), nil
+		}
+
+		// Первичный сбор, чтобы к первому дайджесту история уже была в базе
+		chats, err := listChats(ctx)
 		if err != nil {
 			logger.Error("Failed to list groups after authorization", zap.Error(err))
 		} else {
-			for _, g := range groups {
-				logger.Info("Group found",
+			for _, g := range chats {
+				logger.Info("Tracking group",
 					zap.Int64("chat_id", g.ChatID),
 					zap.String("title", g.Title),
 					zap.String("type", string(g.Type)),
 				)
 			}
-			logger.Info("ListGroups succeeded", zap.Int("group_count", len(groups)))
+			results := msgCollector.Collect(ctx, chats)
+			failed := 0
+			for _, r := range results {
+				if r.Err != nil {
+					failed++
+				}
+			}
+			logger.Info("Collection finished", zap.Int("chats", len(results)), zap.Int("failed", failed))
 		}
 
-		// Здесь можно вызывать другие бизнес-методы (FetchMessages, Summarize, Delivery и т.д.)
-		return nil
+		// Отдельное задание на каждый часовой пояс получателей: 09:00 по местному времени
+		for _, loc := range digestLocations(cfg) {
+			err := taskScheduler.AddJob("digest "+loc.String(), cfg.DigestSchedule, func(ctx context.Context, at time.Time) error {
+				chats, err := listChats(ctx)
+				if err != nil {
+					return err
+				}
+				return digestPipeline.Run(ctx, chatsInLocation(chats, cfg, loc), at)
+			}, scheduler.WithLocation(loc))
+			if err != nil {
+				return err
+			}
+		}
+		if err := taskScheduler.Start(); err != nil {
+			return err
+		}
+		logger.Info("Service is running", zap.String("digest_schedule", cfg.DigestSchedule))
+
+		<-ctx.Done()
+		logger.Info("Shutting down...")
+		return taskScheduler.Stop()
 	})
 	if err != nil {
 		logger.Fatal("Telegram client run failed", zap.Error(err))
 	}
+	logger.Info("tg-summary service stopped")
+}
 
-	// Summarizer Check (Example: Summarize пустой список)
-	summary, err := llmSummarizer.Summarize([]telegram.Message{}) // Re-declare summary, assign to existing err
-	if err != nil {
-		logger.Fatal("Failed to summarize (stub)", zap.Error(err))
+// newSummarizer selects the summarization backend configured by LLM_BACKEND.
+// LLM backends are split by map-reduce and fall back to the extractive digest on failure.
+func newSummarizer(logger applog.Logger, cfg *config.Config) summarizer.Summarizer {
+	extractive := summarizer.NewExtractiveSummarizer()
+	var llm summarizer.Summarizer
+	switch cfg.LLMBackend {
+	case "extractive":
+		return extractive
+	case "ollama":
+		llm = summarizer.NewOllamaSummarizer(logger.Named("ollama"), cfg)
+	case "openai":
+		if cfg.LLMAPIKey == "" && cfg.LLMBaseURL == config.DefaultLLMBaseURL {
+			logger.Warn("LLM_API_KEY is not set, using extractive summarizer")
+			return extractive
+		}
+		llm = summarizer.NewOpenAISummarizer(logger.Named("summarizer"), cfg)
+	default:
+		logger.Fatal("Unknown LLM_BACKEND", zap.String("backend", cfg.LLMBackend))
 	}
-	logger.Info("Summarizer generated summary (stub)", zap.String("summary", summary))
+	return summarizer.NewFallbackSummarizer(logger.Named("fallback"),
+		summarizer.NewMapReduceSummarizer(logger.Named("mapreduce"), llm, cfg), extractive)
+}
 
-	// Delivery Check (Example: Send dummy summary)
-	err = digestSender.SendDigest(123, summary) // Assign to existing err
-	if err != nil {
-		logger.Fatal("Failed to send digest (stub)", zap.Error(err))
+// newOutbox builds the delivery channels listed in DIGEST_DELIVERY behind a durable outbox.
+func newOutbox(logger applog.Logger, cfg *config.Config, tgClient telegram.TelegramClient, store delivery.OutboxStore) *delivery.Outbox {
+	sender := delivery.NewOutbox(logger.Named("outbox"), store, cfg.OutboxMaxAttempts)
+	for _, name := range cfg.DeliveryChannels {
+		switch name {
+		case "telegram":
+			sender.Add(name, delivery.NewTelegramDigestSender(logger.Named("delivery"), tgClient, cfg))
+		case "bot":
+			if cfg.BotToken == "" || len(cfg.BotChatIDs) == 0 {
+				logger.Fatal("Bot delivery requires TELEGRAM_BOT_TOKEN and TELEGRAM_BOT_CHAT_IDS")
+			}
+			for _, ch := range delivery.NewBotChannels(logger.Named("bot"), cfg) {
+				sender.Add(ch.Name, ch.Sender)
+			}
+		case "email":
+			emailSender, err := delivery.NewEmailSender(logger.Named("email"), cfg)
+			if err != nil {
+				logger.Fatal("Invalid email delivery config", zap.Error(err))
+			}
+			sender.Add(name, emailSender)
+		case "webhook":
+			if len(cfg.WebhookURLs) == 0 {
+				logger.Fatal("Webhook delivery requires WEBHOOK_URLS")
+			}
+			if cfg.WebhookSecret == "" {
+				logger.Warn("WEBHOOK_SECRET is not set, webhook payloads will not be signed")
+			}
+			for _, ch := range delivery.NewWebhookChannels(logger.Named("webhook"), cfg) {
+				sender.Add(ch.Name, ch.Sender)
+			}
+		case "slack":
+			if len(cfg.SlackWebhookURLs) == 0 {
+				logger.Fatal("Slack delivery requires SLACK_WEBHOOK_URLS")
+			}
+			for _, ch := range delivery.NewSlackChannels(logger.Named("slack"), cfg) {
+				sender.Add(ch.Name, ch.Sender)
+			}
+		case "discord":
+			if len(cfg.DiscordWebhookURLs) == 0 {
+				logger.Fatal("Discord delivery requires DISCORD_WEBHOOK_URLS")
+			}
+			for _, ch := range delivery.NewDiscordChannels(logger.Named("discord"), cfg) {
+				sender.Add(ch.Name, ch.Sender)
+			}
+		case "matrix":
+			if cfg.MatrixHomeserver == "" || cfg.MatrixAccessToken == "" || len(cfg.MatrixRoomIDs) == 0 {
+				logger.Fatal("Matrix delivery requires MATRIX_HOMESERVER_URL, MATRIX_ACCESS_TOKEN and MATRIX_ROOM_IDS")
+			}
+			for _, ch := range delivery.NewMatrixChannels(logger.Named("matrix"), cfg) {
+				sender.Add(ch.Name, ch.Sender)
+			}
+		default:
+			logger.Fatal("Unknown delivery channel in DIGEST_DELIVERY", zap.String("channel", name))
+		}
 	}
-	logger.Info("Digest sent (stub).")
+	return sender
+}
 
-	// Scheduler Check
-	err = taskScheduler.Start() // Assign to existing err
-	if err != nil {
-		logger.Fatal("Failed to start scheduler (stub)", zap.Error(err))
+// trackedGroups filters groups by the configured chat IDs; an empty list keeps all groups.
+func trackedGroups(groups []telegram.GroupInfo, ids []int64) []telegram.GroupInfo {
+	if len(ids) == 0 {
+		return groups
+	}
+	wanted := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	var res []telegram.GroupInfo
+	for _, g := range groups {
+		if wanted[g.ChatID] {
+			res = append(res, g)
+		}
 	}
-	logger.Info("Scheduler started (stub).")
+	return res
+}
 
-	// --- Keep service running (example) ---
-	// In a real app, you'd likely wait for a signal or the scheduler to finish.
-	// For now, just print completion.
-	logger.Info("tg-summary service initialization check complete.")
+// digestLocations returns the distinct timezones of digest recipients.
+func digestLocations(cfg *config.Config) []*time.Location {
+	locs := []*time.Location{cfg.DigestLocation}
+	seen := map[string]bool{locs[0].String(): true}
+	for _, loc := range cfg.ChatLocations {
+		if !seen[loc.String()] {
+			seen[loc.String()] = true
+			locs = append(locs, loc)
+		}
+	}
+	return locs
+}
 
-	// Example: Stop scheduler after check
-	err = taskScheduler.Stop() // Assign to existing err
-	if err != nil {
-		logger.Warn("Failed to stop scheduler (stub)", zap.Error(err))
+// chatsInLocation keeps the chats whose digest timezone is loc.
+func chatsInLocation(chats []telegram.GroupInfo, cfg *config.Config, loc *time.Location) []telegram.GroupInfo {
+	var res []telegram.GroupInfo
+	for _, c := range chats {
+		if cfg.LocationFor(c.ChatID).String() == loc.String() {
+			res = append(res, c)
+		}
 	}
-	logger.Info("Scheduler stopped (stub).")
+	return res
 }
 
 // initLogger initializes the application logger and returns it along with a cleanup function.
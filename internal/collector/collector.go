@@ -0,0 +1,170 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/storage"
+	"github.com/azalio/tg-summary/internal/telegram"
+	"go.uber.org/zap"
+)
+
+// ChatResult — итог сбора по одному чату
+type ChatResult struct {
+	ChatID  int64
+	Title   string
+	From    int64 // начало окна выгрузки (unixtime)
+	Fetched int   // сколько сообщений получено из Telegram
+	Err     error
+}
+
+// Collector загружает новые сообщения из Telegram и сохраняет их в Storage.
+// Повторный запуск продолжает с последнего сохранённого сообщения
+// и заново читает недавние, чтобы обновить их реакции и просмотры.
+type Collector struct {
+	client  telegram.TelegramClient
+	store   storage.Storage
+	log     applog.Logger
+	window  time.Duration    // глубина выгрузки для чата без истории
+	refresh time.Duration    // сколько последних сообщений перечитывать; 0 — не перечитывать
+	now     func() time.Time // подменяется в тестах
+}
+
+// NewCollector creates a new Collector.
+// window limits how far back history is loaded for a chat that has no stored messages yet.
+// refresh is how far back already stored messages are fetched again so that their
+// reactions and view counts stay current; 0 fetches only new messages.
+func NewCollector(logger applog.Logger, client telegram.TelegramClient, store storage.Storage, window, refresh time.Duration) *Collector {
+	return &Collector{
+		client:  client,
+		store:   store,
+		log:     logger,
+		window:  window,
+		refresh: refresh,
+		now:     time.Now,
+	}
+}
+
+// Collect fetches and stores new messages for every chat.
+// Errors are reported per chat; one failing chat does not stop the others.
+func (c *Collector) Collect(ctx context.Context, chats []telegram.GroupInfo) []ChatResult {
+	results := make([]ChatResult, 0, len(chats))
+	for _, chat := range chats {
+		if ctx.Err() != nil {
+			results = append(results, ChatResult{ChatID: chat.ChatID, Title: chat.Title, Err: ctx.Err()})
+			continue
+		}
+		res := c.collectChat(ctx, chat)
+		if res.Err != nil {
+			c.log.Error("Failed to collect chat",
+				zap.Int64("chat_id", chat.ChatID),
+				zap.String("title", chat.Title),
+				zap.Error(res.Err),
+			)
+		} else {
+			c.log.Info("Chat collected",
+				zap.Int64("chat_id", chat.ChatID),
+				zap.String("title", chat.Title),
+				zap.Int("fetched", res.Fetched),
+			)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func (c *Collector) collectChat(ctx context.Context, chat telegram.GroupInfo) ChatResult {
+	res := ChatResult{ChatID: chat.ChatID, Title: chat.Title}
+
+	if err := c.store.SaveChat(ctx, &storage.Chat{ID: chat.ChatID, Title: chat.Title, Type: string(chat.Type)}); err != nil {
+		res.Err = fmt.Errorf("save chat: %w", err)
+		return res
+	}
+
+	last, err := c.store.GetLastMessageTimestamp(ctx, chat.ChatID)
+	if err != nil {
+		res.Err = fmt.Errorf("get last message timestamp: %w", err)
+		return res
+	}
+	// The last second is fetched again: messages sharing its timestamp may be missing,
+	// duplicates are ignored by SaveMessage.
+	// Recent messages are fetched again to refresh their reactions and views.
+	res.From = last
+	switch {
+	case last == 0:
+		res.From = c.now().Add(-c.window).Unix()
+	case c.refresh > 0:
+		res.From = min(last, c.now().Add(-c.refresh).Unix())
+	}
+
+	msgs, err := c.client.FetchMessages(ctx, chat.ChatID, res.From, 0)
+	if err != nil {
+		res.Err = fmt.Errorf("fetch messages: %w", err)
+		return res
+	}
+	res.Fetched = len(msgs)
+
+	savedUsers := make(map[int64]bool)
+	for _, m := range msgs {
+		// Without the sender entity the name is a placeholder; keep the stored one.
+		if m.SenderID != 0 && !m.SenderUnknown && !savedUsers[m.SenderID] {
+			user := &storage.User{ID: m.SenderID, Username: m.SenderUsername, DisplayName: m.Sender}
+			if err := c.store.SaveUser(ctx, user); err != nil {
+				res.Err = fmt.Errorf("save user %d: %w", m.SenderID, err)
+				return res
+			}
+			savedUsers[m.SenderID] = true
+		}
+		if err := c.store.SaveMessage(ctx, toStorageMessage(m)); err != nil {
+			res.Err = fmt.Errorf("save message %d: %w", m.ID, err)
+			return res
+		}
+	}
+	return res
+}
+
+func toStorageMessage(m telegram.Message) *storage.Message {
+	msg := &storage.Message{
+		ChatID:    m.ChatID,
+		MessageID: m.ID,
+		AuthorID:  m.SenderID,
+		Text:      m.Text,
+		Timestamp: m.Timestamp,
+		EditedAt:  m.EditedAt,
+		Views:     m.Views,
+		Forwards:  m.Forwards,
+	}
+	if m.ReplyToID != 0 {
+		replyTo := m.ReplyToID
+		msg.ReplyToMessageID = &replyTo
+	}
+	if m.Media != nil {
+		msg.Media = toStorageMedia(m.Media)
+	}
+	for _, r := range m.Reactions {
+		msg.Reactions = append(msg.Reactions, storage.MessageReaction{Emoji: r.Emoji, Count: r.Count, ReactorIDs: r.UserIDs})
+	}
+	return msg
+}
+
+func toStorageMedia(m *telegram.Media) *storage.MessageMedia {
+	media := &storage.MessageMedia{
+		Type:     string(m.Type),
+		FileName: m.FileName,
+		MIMEType: m.MIMEType,
+		Size:     m.Size,
+		URL:      m.URL,
+		Title:    m.Title,
+	}
+	if p := m.Poll; p != nil {
+		media.PollQuestion = p.Question
+		media.PollVoters = p.TotalVoters
+		media.PollClosed = p.Closed
+		for _, o := range p.Options {
+			media.PollOptions = append(media.PollOptions, storage.PollOption{Text: o.Text, Voters: o.Voters})
+		}
+	}
+	return media
+}
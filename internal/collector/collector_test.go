@@ -0,0 +1,203 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/storage"
+	"github.com/azalio/tg-summary/internal/telegram"
+	gotdtelegram "github.com/gotd/td/telegram"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTelegramClient serves messages from memory and records requested windows.
+type fakeTelegramClient struct {
+	messages map[int64][]telegram.Message
+	errs     map[int64]error
+	fromArgs map[int64][]int64
+}
+
+func newFakeTelegramClient() *fakeTelegramClient {
+	return &fakeTelegramClient{
+		messages: make(map[int64][]telegram.Message),
+		errs:     make(map[int64]error),
+		fromArgs: make(map[int64][]int64),
+	}
+}
+
+func (f *fakeTelegramClient) Run(ctx context.Context, fn func(ctx context.Context, api *gotdtelegram.Client) error) error {
+	return fn(ctx, nil)
+}
+
+func (f *fakeTelegramClient) FetchMessages(ctx context.Context, chatID int64, from, to int64) ([]telegram.Message, error) {
+	f.fromArgs[chatID] = append(f.fromArgs[chatID], from)
+	if err := f.errs[chatID]; err != nil {
+		return nil, err
+	}
+	var res []telegram.Message
+	for _, m := range f.messages[chatID] {
+		if m.Timestamp >= from && (to == 0 || m.Timestamp < to) {
+			res = append(res, m)
+		}
+	}
+	return res, nil
+}
+
+func (f *fakeTelegramClient) ListGroups(ctx context.Context, api *gotdtelegram.Client) ([]telegram.GroupInfo, error) {
+	return nil, nil
+}
+
+func (f *fakeTelegramClient) SendMessage(ctx context.Context, chatID int64, text string) error {
+	return nil
+}
+
+func newTestCollector(t *testing.T, client telegram.TelegramClient) (*Collector, *storage.GormStorage) {
+	st, err := storage.NewGormStorage(filepath.Join(t.TempDir(), "collector.db"))
+	require.NoError(t, err)
+	require.NoError(t, st.Init(context.Background()))
+	t.Cleanup(func() { _ = st.Close() })
+
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+
+	c := NewCollector(logger, client, st, 24*time.Hour, 0)
+	c.now = func() time.Time { return time.Unix(100_000, 0) }
+	return c, st
+}
+
+func TestCollector_IncrementalResume(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeTelegramClient()
+	c, st := newTestCollector(t, client)
+	chats := []telegram.GroupInfo{
+		{ChatID: 1, Title: "Group", Type: telegram.GroupTypeGroup},
+		{ChatID: 2, Title: "Super", Type: telegram.GroupTypeSupergroup},
+	}
+
+	client.messages[1] = []telegram.Message{
+		{ID: 10, ChatID: 1, SenderID: 7, Sender: "Ivan", SenderUsername: "ivan", Text: "hi", Timestamp: 99_000},
+		{ID: 11, ChatID: 1, SenderID: 8, Sender: "Olga", Text: "hello", Timestamp: 99_500, ReplyToID: 10},
+	}
+	// Same Telegram message ID in another chat must not collide.
+	client.messages[2] = []telegram.Message{
+		{ID: 10, ChatID: 2, SenderID: 7, Sender: "Ivan", Text: "other chat", Timestamp: 99_100,
+			Media: &telegram.Media{Type: telegram.MediaPoll, Poll: &telegram.Poll{
+				Question: "Lunch?", Options: []telegram.PollOption{{Text: "Yes", Voters: 3}}, TotalVoters: 3,
+			}}},
+	}
+
+	results := c.Collect(ctx, chats)
+	require.Len(t, results, 2)
+	for _, r := range results {
+		require.NoError(t, r.Err)
+		require.Equal(t, int64(100_000-24*3600), r.From)
+	}
+	require.Equal(t, 2, results[0].Fetched)
+	require.Equal(t, 1, results[1].Fetched)
+
+	msgs, err := st.GetMessagesAfter(ctx, 1, 0)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	require.NotNil(t, msgs[1].ReplyToMessageID)
+	require.Equal(t, int64(10), *msgs[1].ReplyToMessageID)
+
+	msgs, err = st.GetMessagesAfter(ctx, 2, 0)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.NotNil(t, msgs[0].Media)
+	require.Equal(t, "Lunch?", msgs[0].Media.PollQuestion)
+	require.Equal(t, []storage.PollOption{{Text: "Yes", Voters: 3}}, msgs[0].Media.PollOptions)
+
+	// Second run resumes from the last stored timestamp.
+	client.messages[1] = append(client.messages[1],
+		telegram.Message{ID: 12, ChatID: 1, SenderID: 7, Sender: "Ivan", Text: "new", Timestamp: 99_900})
+	results = c.Collect(ctx, chats[:1])
+	require.NoError(t, results[0].Err)
+	require.Equal(t, int64(99_500), results[0].From)
+	require.Equal(t, 2, results[0].Fetched) // the boundary message is fetched again and ignored
+
+	msgs, err = st.GetMessagesAfter(ctx, 1, 0)
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+}
+
+func TestCollector_PerChatErrors(t *testing.T) {
+	client := newFakeTelegramClient()
+	c, _ := newTestCollector(t, client)
+	client.errs[1] = errors.New("flood wait")
+	client.messages[2] = []telegram.Message{{ID: 1, ChatID: 2, SenderID: 7, Text: "ok", Timestamp: 99_999}}
+
+	results := c.Collect(context.Background(), []telegram.GroupInfo{
+		{ChatID: 1, Title: "Broken", Type: telegram.GroupTypeGroup},
+		{ChatID: 2, Title: "Fine", Type: telegram.GroupTypeGroup},
+	})
+	require.Len(t, results, 2)
+	require.ErrorContains(t, results[0].Err, "flood wait")
+	require.NoError(t, results[1].Err)
+	require.Equal(t, 1, results[1].Fetched)
+}
+
+func TestCollector_RefreshRecentReactions(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeTelegramClient()
+	c, st := newTestCollector(t, client)
+	c.refresh = 2 * time.Hour
+	chats := []telegram.GroupInfo{{ChatID: 1, Title: "Channel", Type: telegram.GroupTypeSupergroup}}
+
+	client.messages[1] = []telegram.Message{
+		{ID: 1, ChatID: 1, Text: "old", Timestamp: 90_000, Views: 10},
+		{ID: 2, ChatID: 1, Text: "recent", Timestamp: 99_000, Views: 5},
+	}
+	results := c.Collect(ctx, chats)
+	require.NoError(t, results[0].Err)
+
+	// Counters grow after the first run; only messages inside the refresh window are read again.
+	client.messages[1][0].Views = 50
+	client.messages[1][1].Views = 40
+	client.messages[1][1].Forwards = 2
+	client.messages[1][1].Reactions = []telegram.Reaction{{Emoji: "👍", Count: 3, UserIDs: []int64{7}}}
+	results = c.Collect(ctx, chats)
+	require.NoError(t, results[0].Err)
+	require.Equal(t, int64(100_000-2*3600), results[0].From)
+	require.Equal(t, 1, results[0].Fetched)
+
+	msgs, err := st.GetMessagesAfter(ctx, 1, 0)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, 10, msgs[0].Views)
+	require.Equal(t, 40, msgs[1].Views)
+	require.Equal(t, 2, msgs[1].Forwards)
+	require.Len(t, msgs[1].Reactions, 1)
+	require.Equal(t, "👍", msgs[1].Reactions[0].Emoji)
+	require.Equal(t, 3, msgs[1].Reactions[0].Count)
+	require.Equal(t, []int64{7}, msgs[1].Reactions[0].ReactorIDs)
+}
+
+func TestCollector_KeepsStoredSenderWhenEntityMissing(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeTelegramClient()
+	c, st := newTestCollector(t, client)
+	chats := []telegram.GroupInfo{{ChatID: 1, Title: "Group", Type: telegram.GroupTypeGroup}}
+
+	client.messages[1] = []telegram.Message{
+		{ID: 1, ChatID: 1, SenderID: 7, Sender: "Ivan", SenderUsername: "ivan", Text: "hi", Timestamp: 99_000},
+	}
+	require.NoError(t, c.Collect(ctx, chats)[0].Err)
+
+	// The next run gets a page without the user entity.
+	client.messages[1] = []telegram.Message{
+		{ID: 2, ChatID: 1, SenderID: 7, Sender: "id7", SenderUnknown: true, Text: "again", Timestamp: 99_500},
+	}
+	require.NoError(t, c.Collect(ctx, chats)[0].Err)
+
+	msgs, err := st.GetMessagesAfter(ctx, 1, 0)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, "Ivan", msgs[1].Author.DisplayName)
+	require.Equal(t, "ivan", msgs[1].Author.Username)
+}
@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/storage"
+	"github.com/azalio/tg-summary/internal/telegram"
+	"go.uber.org/zap"
+)
+
+var _ telegram.UpdateSink = (*Ingestor)(nil)
+
+// Ingestor сохраняет real-time обновления (новые, изменённые и удалённые сообщения) в Storage.
+type Ingestor struct {
+	store   storage.Storage
+	log     applog.Logger
+	tracked map[int64]bool   // пусто — принимаются все группы
+	now     func() time.Time // подменяется в тестах
+}
+
+// NewIngestor creates a new Ingestor. Updates from chats outside trackedChatIDs
+// are ignored; an empty list accepts every group and supergroup.
+func NewIngestor(logger applog.Logger, store storage.Storage, trackedChatIDs []int64) *Ingestor {
+	tracked := make(map[int64]bool, len(trackedChatIDs))
+	for _, id := range trackedChatIDs {
+		tracked[id] = true
+	}
+	return &Ingestor{store: store, log: logger, tracked: tracked, now: time.Now}
+}
+
+func (i *Ingestor) accepts(chatID int64) bool {
+	return len(i.tracked) == 0 || i.tracked[chatID]
+}
+
+// OnNewMessage implements telegram.UpdateSink.
+func (i *Ingestor) OnNewMessage(ctx context.Context, chat telegram.GroupInfo, msg telegram.Message) error {
+	if !i.accepts(chat.ChatID) {
+		return nil
+	}
+	// Update entities may omit the chat; keep the stored title then.
+	if chat.Title != "" {
+		if err := i.store.SaveChat(ctx, &storage.Chat{ID: chat.ChatID, Title: chat.Title, Type: string(chat.Type)}); err != nil {
+			return fmt.Errorf("save chat: %w", err)
+		}
+	}
+	if err := i.saveSender(ctx, msg); err != nil {
+		return err
+	}
+	if err := i.store.SaveMessage(ctx, toStorageMessage(msg)); err != nil {
+		return fmt.Errorf("save message: %w", err)
+	}
+	i.log.Debug("Message ingested", zap.Int64("chat_id", chat.ChatID), zap.Int64("message_id", msg.ID))
+	return nil
+}
+
+// OnEditMessage implements telegram.UpdateSink.
+func (i *Ingestor) OnEditMessage(ctx context.Context, chat telegram.GroupInfo, msg telegram.Message) error {
+	if !i.accepts(chat.ChatID) {
+		return nil
+	}
+	// Telegram sends the edit date with the message; fall back to the arrival time.
+	editedAt := i.now()
+	if msg.EditedAt != 0 {
+		editedAt = time.Unix(msg.EditedAt, 0)
+	}
+	if err := i.store.UpdateMessageText(ctx, chat.ChatID, msg.ID, msg.Text, editedAt); err != nil {
+		return fmt.Errorf("update message: %w", err)
+	}
+	return nil
+}
+
+// OnDeleteMessages implements telegram.UpdateSink.
+func (i *Ingestor) OnDeleteMessages(ctx context.Context, chatID int64, messageIDs []int64) error {
+	if chatID != 0 && !i.accepts(chatID) {
+		return nil
+	}
+	if err := i.store.DeleteMessages(ctx, chatID, messageIDs, i.now()); err != nil {
+		return fmt.Errorf("delete messages: %w", err)
+	}
+	return nil
+}
+
+// saveSender stores the author of the message. Update entities may omit the sender;
+// the stored name and username are kept then instead of being replaced by a placeholder.
+func (i *Ingestor) saveSender(ctx context.Context, msg telegram.Message) error {
+	if msg.SenderID == 0 || msg.SenderUnknown {
+		return nil
+	}
+	user := &storage.User{ID: msg.SenderID, Username: msg.SenderUsername, DisplayName: msg.Sender}
+	if err := i.store.SaveUser(ctx, user); err != nil {
+		return fmt.Errorf("save user %d: %w", msg.SenderID, err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/storage"
+	"github.com/azalio/tg-summary/internal/telegram"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngestor_NewEditDelete(t *testing.T) {
+	ctx := context.Background()
+	st, err := storage.NewGormStorage(filepath.Join(t.TempDir(), "ingestor.db"))
+	require.NoError(t, err)
+	require.NoError(t, st.Init(ctx))
+	defer st.Close()
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	defer cleanup()
+
+	ing := NewIngestor(logger, st, []int64{1, 2})
+	ing.now = func() time.Time { return time.Unix(500, 0) }
+	group := telegram.GroupInfo{ChatID: 1, Title: "Group", Type: telegram.GroupTypeGroup}
+	super := telegram.GroupInfo{ChatID: 2, Title: "Super", Type: telegram.GroupTypeSupergroup}
+	untracked := telegram.GroupInfo{ChatID: 3, Title: "Other", Type: telegram.GroupTypeGroup}
+
+	require.NoError(t, ing.OnNewMessage(ctx, group, telegram.Message{ID: 10, ChatID: 1, SenderID: 7, Sender: "Ivan", SenderUsername: "ivan", Text: "v1", Timestamp: 100}))
+	require.NoError(t, ing.OnNewMessage(ctx, super, telegram.Message{ID: 10, ChatID: 2, SenderID: 7, Sender: "Ivan", SenderUsername: "ivan", Text: "super", Timestamp: 101}))
+	require.NoError(t, ing.OnNewMessage(ctx, untracked, telegram.Message{ID: 11, ChatID: 3, Text: "skip", Timestamp: 102}))
+
+	require.NoError(t, ing.OnEditMessage(ctx, group, telegram.Message{ID: 10, ChatID: 1, Text: "v2", Timestamp: 100}))
+	msgs, err := st.GetMessagesAfter(ctx, 1, 0)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, "v2", msgs[0].Text)
+	require.Equal(t, int64(500), msgs[0].EditedAt)
+	// An edit with its own date replaces the text again and keeps both prior versions.
+	require.NoError(t, ing.OnEditMessage(ctx, group, telegram.Message{ID: 10, ChatID: 1, Text: "v3", Timestamp: 100, EditedAt: 600}))
+	revs, err := st.MessageRevisions(ctx, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	require.Equal(t, "v2", revs[1].Text)
+
+	msgs, err = st.GetMessagesAfter(ctx, 3, 0)
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+
+	// Basic group deletions carry no chat ID and must not touch supergroups.
+	require.NoError(t, ing.OnDeleteMessages(ctx, 0, []int64{10}))
+	msgs, err = st.GetMessagesAfter(ctx, 1, 0)
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+	msgs, err = st.GetMessagesAfter(ctx, 2, 0)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+
+	require.NoError(t, ing.OnDeleteMessages(ctx, 2, []int64{10}))
+	msgs, err = st.GetMessagesAfter(ctx, 2, 0)
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+
+	// An update without user entities keeps the stored name of the sender.
+	require.NoError(t, ing.OnNewMessage(ctx, super, telegram.Message{ID: 20, ChatID: 2, SenderID: 7, Sender: "id7", SenderUnknown: true, Text: "no users", Timestamp: 200}))
+	msgs, err = st.GetMessagesAfter(ctx, 2, 0)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, "Ivan", msgs[0].Author.DisplayName)
+	require.Equal(t, "ivan", msgs[0].Author.Username)
+}
@@ -3,19 +3,96 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	applog "github.com/azalio/tg-summary/internal/log"
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
 )
 
+// DefaultLLMBaseURL is used when LLM_BASE_URL is not set.
+const DefaultLLMBaseURL = "https://api.openai.com/v1"
+
+// OllamaContextReserve is added to SUMMARY_CHUNK_TOKENS for the default OLLAMA_NUM_CTX:
+// room for the system prompt, the chat header and the JSON answer.
+const OllamaContextReserve = 2048
+
+// DefaultOutboxMaxAttempts is used when OUTBOX_MAX_ATTEMPTS is not set: with the
+// outbox backoff (1 min, doubling up to 1 h) an item is retried for about two hours.
+const DefaultOutboxMaxAttempts = 8
+
 // Config holds all application configuration.
 type Config struct {
 	TelegramAppID      int
 	TelegramAppHash    string
 	TelegramPhone      string
 	TelegramSessionDir string
-	SqlitePath         string // путь до файла SQLite
+	DatabaseURL        string        // postgres://… для PostgreSQL, иначе путь до файла SQLite
+	TrackedChatIDs     []int64       // чаты для сбора; пусто — все группы из диалогов
+	CollectWindow      time.Duration // глубина первой выгрузки для нового чата
+	CollectRefresh     time.Duration // за сколько последних часов перечитывать реакции и просмотры; 0 — не перечитывать
+	RealtimeUpdates    bool          // слушать обновления Telegram после сбора истории
+
+	// LLM-бэкенд суммаризации: openai (любой OpenAI-совместимый API) или ollama
+	LLMBackend string
+
+	// OpenAI-совместимый LLM API (OpenAI, vLLM, LM Studio, OpenRouter, ...)
+	LLMBaseURL     string
+	LLMAPIKey      string
+	LLMModel       string
+	LLMTemperature float64
+	LLMTimeout     time.Duration
+
+	// Локальный Ollama-сервер
+	OllamaURL       string
+	OllamaModel     string
+	OllamaKeepAlive string // сколько держать модель в памяти, например "5m"
+	OllamaNumCtx    int    // окно контекста модели (num_ctx); иначе Ollama молча обрезает длинный промпт
+
+	// Дайджест
+	DigestSchedule string // cron-выражение запуска, например "0 9 * * *"
+	DigestLanguage string // язык дайджеста; пусто — язык чата
+	// Отмечать в дайджесте правки и удаления сообщений после отправки
+	DigestNoteChanges bool
+	// Часовой пояс получателя: расписание и «вчерашний день» считаются в нём
+	DigestLocation *time.Location
+	ChatLocations  map[int64]*time.Location // переопределения по чатам
+	// Каналы доставки дайджестов: telegram (от имени аккаунта), bot
+	DeliveryChannels []string
+	// Получатель дайджестов: 0 — «Избранное» (Saved Messages) аккаунта, иначе ID чата из диалогов
+	DigestPeer int64
+	// Telegram Bot API
+	BotToken   string
+	BotChatIDs []int64
+	BotAPIURL  string
+	// Email (SMTP)
+	SMTPHost     string
+	SMTPPort     int
+	SMTPSecurity string // starttls, tls (неявный TLS) или none
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	SMTPTo       []string
+	SMTPSubject  string // шаблон text/template: {{.Title}}, {{.Date}}, {{.Period}}
+	// Webhook: JSON POST, подпись HMAC-SHA256 в заголовке X-Signature-256
+	WebhookURLs   []string
+	WebhookSecret string
+	// Slack и Discord: входящие вебхуки
+	SlackWebhookURLs   []string
+	DiscordWebhookURLs []string
+	// Matrix client-server API
+	MatrixHomeserver  string
+	MatrixAccessToken string
+	MatrixRoomIDs     []string
+	// Outbox: сколько попыток доставки в канал до перевода в dead-letter
+	OutboxMaxAttempts int
+	// Что делать с запусками, пропущенными пока сервис не работал: all, latest, skip
+	CatchUp string
+
+	// Map-reduce суммаризация больших чатов
+	SummaryChunkTokens int // бюджет токенов на один запрос к LLM
+	SummaryConcurrency int // сколько частей суммаризуется параллельно
 	// Add other config fields as needed
 }
 
@@ -30,7 +107,11 @@ func Load(logger applog.Logger) (*Config, error) {
 	appHash := os.Getenv("TELEGRAM_APP_HASH")
 	phone := os.Getenv("TELEGRAM_PHONE")
 	sessionDir := os.Getenv("TELEGRAM_SESSION_DIR")
-	sqlitePath := os.Getenv("SQLITE_PATH")
+	// DATABASE_URL выбирает бэкенд по схеме; SQLITE_PATH оставлен для совместимости
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		databaseURL = os.Getenv("SQLITE_PATH")
+	}
 
 	missing := false
 	if appIDStr == "" {
@@ -49,8 +130,8 @@ func Load(logger applog.Logger) (*Config, error) {
 		logger.Error("Missing TELEGRAM_SESSION_DIR in environment")
 		missing = true
 	}
-	if sqlitePath == "" {
-		logger.Error("Missing SQLITE_PATH in environment")
+	if databaseURL == "" {
+		logger.Error("Missing DATABASE_URL (or SQLITE_PATH) in environment")
 		missing = true
 	}
 	if missing {
@@ -63,15 +144,306 @@ func Load(logger applog.Logger) (*Config, error) {
 		return nil, err
 	}
 
+	chatIDs, err := parseChatIDs(os.Getenv("TELEGRAM_CHAT_IDS"))
+	if err != nil {
+		logger.Error("Invalid TELEGRAM_CHAT_IDS, must be comma-separated integers", zap.Error(err))
+		return nil, err
+	}
+
+	collectWindow := 24 * time.Hour
+	if v := os.Getenv("COLLECT_WINDOW"); v != "" {
+		collectWindow, err = time.ParseDuration(v)
+		if err != nil {
+			logger.Error("Invalid COLLECT_WINDOW, must be a Go duration", zap.String("value", v), zap.Error(err))
+			return nil, err
+		}
+	}
+
+	collectRefresh := 48 * time.Hour
+	if v := os.Getenv("COLLECT_REFRESH_WINDOW"); v != "" {
+		collectRefresh, err = time.ParseDuration(v)
+		if err != nil {
+			logger.Error("Invalid COLLECT_REFRESH_WINDOW, must be a Go duration", zap.String("value", v), zap.Error(err))
+			return nil, err
+		}
+	}
+
+	realtime := false
+	if v := os.Getenv("TELEGRAM_UPDATES"); v != "" {
+		realtime, err = strconv.ParseBool(v)
+		if err != nil {
+			logger.Error("Invalid TELEGRAM_UPDATES, must be boolean", zap.String("value", v), zap.Error(err))
+			return nil, err
+		}
+	}
+
+	noteChanges := false
+	if v := os.Getenv("DIGEST_NOTE_CHANGES"); v != "" {
+		noteChanges, err = strconv.ParseBool(v)
+		if err != nil {
+			logger.Error("Invalid DIGEST_NOTE_CHANGES, must be boolean", zap.String("value", v), zap.Error(err))
+			return nil, err
+		}
+	}
+
+	llmBackend := os.Getenv("LLM_BACKEND")
+	if llmBackend == "" {
+		llmBackend = "openai"
+	}
+	llmBaseURL := os.Getenv("LLM_BASE_URL")
+	if llmBaseURL == "" {
+		llmBaseURL = DefaultLLMBaseURL
+	}
+	llmModel := os.Getenv("LLM_MODEL")
+	if llmModel == "" {
+		llmModel = "gpt-4o-mini"
+	}
+	llmTemperature := 0.2
+	if v := os.Getenv("LLM_TEMPERATURE"); v != "" {
+		llmTemperature, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			logger.Error("Invalid LLM_TEMPERATURE, must be a number", zap.String("value", v), zap.Error(err))
+			return nil, err
+		}
+	}
+	llmTimeout := 2 * time.Minute
+	if v := os.Getenv("LLM_TIMEOUT"); v != "" {
+		llmTimeout, err = time.ParseDuration(v)
+		if err != nil {
+			logger.Error("Invalid LLM_TIMEOUT, must be a Go duration", zap.String("value", v), zap.Error(err))
+			return nil, err
+		}
+	}
+
+	ollamaURL := os.Getenv("OLLAMA_URL")
+	if ollamaURL == "" {
+		ollamaURL = "http://localhost:11434"
+	}
+	ollamaModel := os.Getenv("OLLAMA_MODEL")
+	if ollamaModel == "" {
+		ollamaModel = "llama3.1"
+	}
+
+	digestSchedule := os.Getenv("DIGEST_SCHEDULE")
+	if digestSchedule == "" {
+		digestSchedule = "0 9 * * *"
+	}
+
+	digestLocation := time.Local
+	if v := os.Getenv("DIGEST_TIMEZONE"); v != "" {
+		digestLocation, err = time.LoadLocation(v)
+		if err != nil {
+			logger.Error("Invalid DIGEST_TIMEZONE, must be an IANA timezone", zap.String("value", v), zap.Error(err))
+			return nil, err
+		}
+	}
+	chatLocations, err := parseChatLocations(os.Getenv("DIGEST_CHAT_TIMEZONES"))
+	if err != nil {
+		logger.Error("Invalid DIGEST_CHAT_TIMEZONES, must be comma-separated chat_id=Area/City pairs", zap.Error(err))
+		return nil, err
+	}
+
+	var digestPeer int64
+	if v := os.Getenv("DIGEST_PEER"); v != "" && v != "me" && v != "self" {
+		digestPeer, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			logger.Error("Invalid DIGEST_PEER, must be a chat ID or \"me\"", zap.String("value", v), zap.Error(err))
+			return nil, err
+		}
+	}
+
+	deliveryChannels := parseList(os.Getenv("DIGEST_DELIVERY"))
+	if len(deliveryChannels) == 0 {
+		deliveryChannels = []string{"telegram"}
+	}
+	botChatIDs, err := parseChatIDs(os.Getenv("TELEGRAM_BOT_CHAT_IDS"))
+	if err != nil {
+		logger.Error("Invalid TELEGRAM_BOT_CHAT_IDS, must be comma-separated integers", zap.Error(err))
+		return nil, err
+	}
+	botAPIURL := os.Getenv("TELEGRAM_BOT_API_URL")
+	if botAPIURL == "" {
+		botAPIURL = "https://api.telegram.org"
+	}
+
+	smtpSecurity := os.Getenv("SMTP_SECURITY")
+	if smtpSecurity == "" {
+		smtpSecurity = "starttls"
+	}
+	smtpDefaultPort := map[string]int{"starttls": 587, "tls": 465}[smtpSecurity]
+	if smtpDefaultPort == 0 {
+		smtpDefaultPort = 25
+	}
+	smtpPort, err := intEnv("SMTP_PORT", smtpDefaultPort)
+	if err != nil {
+		logger.Error("Invalid SMTP_PORT, must be integer", zap.Error(err))
+		return nil, err
+	}
+
+	outboxMaxAttempts, err := intEnv("OUTBOX_MAX_ATTEMPTS", DefaultOutboxMaxAttempts)
+	if err == nil && outboxMaxAttempts < 1 {
+		err = &ConfigError{Msg: "must be at least 1"}
+	}
+	if err != nil {
+		logger.Error("Invalid OUTBOX_MAX_ATTEMPTS, must be a positive integer", zap.Error(err))
+		return nil, err
+	}
+
+	catchUp := os.Getenv("SCHEDULER_CATCH_UP")
+	if catchUp == "" {
+		catchUp = "latest"
+	}
+
+	chunkTokens, err := intEnv("SUMMARY_CHUNK_TOKENS", 6000)
+	if err != nil {
+		logger.Error("Invalid SUMMARY_CHUNK_TOKENS, must be integer", zap.Error(err))
+		return nil, err
+	}
+	// Чанк транскрипта плюс системный промпт и ответ должны поместиться в контекст модели;
+	// без разбиения на чанки остаётся контекст модели по умолчанию.
+	defaultNumCtx := 0
+	if chunkTokens > 0 {
+		defaultNumCtx = chunkTokens + OllamaContextReserve
+	}
+	numCtx, err := intEnv("OLLAMA_NUM_CTX", defaultNumCtx)
+	if err != nil {
+		logger.Error("Invalid OLLAMA_NUM_CTX, must be integer", zap.Error(err))
+		return nil, err
+	}
+	concurrency, err := intEnv("SUMMARY_CONCURRENCY", 2)
+	if err != nil {
+		logger.Error("Invalid SUMMARY_CONCURRENCY, must be integer", zap.Error(err))
+		return nil, err
+	}
+
 	return &Config{
 		TelegramAppID:      appID,
 		TelegramAppHash:    appHash,
 		TelegramPhone:      phone,
 		TelegramSessionDir: sessionDir,
-		SqlitePath:         sqlitePath,
+		DatabaseURL:        databaseURL,
+		TrackedChatIDs:     chatIDs,
+		CollectWindow:      collectWindow,
+		CollectRefresh:     collectRefresh,
+		RealtimeUpdates:    realtime,
+		LLMBackend:         llmBackend,
+		LLMBaseURL:         llmBaseURL,
+		LLMAPIKey:          os.Getenv("LLM_API_KEY"),
+		LLMModel:           llmModel,
+		LLMTemperature:     llmTemperature,
+		LLMTimeout:         llmTimeout,
+		OllamaURL:          ollamaURL,
+		OllamaModel:        ollamaModel,
+		OllamaKeepAlive:    os.Getenv("OLLAMA_KEEP_ALIVE"),
+		OllamaNumCtx:       numCtx,
+		DigestSchedule:     digestSchedule,
+		DigestLanguage:     os.Getenv("DIGEST_LANGUAGE"),
+		DigestNoteChanges:  noteChanges,
+		DigestLocation:     digestLocation,
+		ChatLocations:      chatLocations,
+		DeliveryChannels:   deliveryChannels,
+		DigestPeer:         digestPeer,
+		BotToken:           os.Getenv("TELEGRAM_BOT_TOKEN"),
+		BotChatIDs:         botChatIDs,
+		BotAPIURL:          botAPIURL,
+		SMTPHost:           os.Getenv("SMTP_HOST"),
+		SMTPPort:           smtpPort,
+		SMTPSecurity:       smtpSecurity,
+		SMTPUsername:       os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:       os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:           os.Getenv("SMTP_FROM"),
+		SMTPTo:             parseList(os.Getenv("SMTP_TO")),
+		SMTPSubject:        os.Getenv("SMTP_SUBJECT"),
+		WebhookURLs:        parseList(os.Getenv("WEBHOOK_URLS")),
+		WebhookSecret:      os.Getenv("WEBHOOK_SECRET"),
+		SlackWebhookURLs:   parseList(os.Getenv("SLACK_WEBHOOK_URLS")),
+		DiscordWebhookURLs: parseList(os.Getenv("DISCORD_WEBHOOK_URLS")),
+		MatrixHomeserver:   strings.TrimRight(os.Getenv("MATRIX_HOMESERVER_URL"), "/"),
+		MatrixAccessToken:  os.Getenv("MATRIX_ACCESS_TOKEN"),
+		MatrixRoomIDs:      parseList(os.Getenv("MATRIX_ROOM_IDS")),
+		OutboxMaxAttempts:  outboxMaxAttempts,
+		CatchUp:            catchUp,
+		SummaryChunkTokens: chunkTokens,
+		SummaryConcurrency: concurrency,
 	}, nil
 }
 
+// LocationFor returns the digest timezone of a chat.
+func (c *Config) LocationFor(chatID int64) *time.Location {
+	if loc, ok := c.ChatLocations[chatID]; ok {
+		return loc
+	}
+	if c.DigestLocation == nil {
+		return time.Local
+	}
+	return c.DigestLocation
+}
+
+// intEnv reads an integer environment variable, returning def when it is unset.
+func intEnv(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
+
+// parseChatLocations parses "chat_id=Area/City" pairs separated by commas; empty input yields nil.
+func parseChatLocations(s string) (map[int64]*time.Location, error) {
+	var res map[int64]*time.Location
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		idStr, name, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, &ConfigError{Msg: "missing '=' in " + strconv.Quote(part)}
+		}
+		id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		loc, err := time.LoadLocation(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		if res == nil {
+			res = make(map[int64]*time.Location)
+		}
+		res[id] = loc
+	}
+	return res, nil
+}
+
+// parseList splits a comma-separated list, dropping empty items.
+func parseList(s string) []string {
+	var res []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			res = append(res, part)
+		}
+	}
+	return res
+}
+
+// parseChatIDs parses a comma-separated list of chat IDs; empty input yields nil.
+func parseChatIDs(s string) ([]int64, error) {
+	var ids []int64
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
 // ErrMissingConfig is returned when required config is missing.
 var ErrMissingConfig = &ConfigError{"missing required Telegram config in environment"}
 
@@ -80,4 +452,4 @@ type ConfigError struct {
 	Msg string
 }
 
-func (e *ConfigError) Error() string { return e.Msg }
\ No newline at end of file
+func (e *ConfigError) Error() string { return e.Msg }
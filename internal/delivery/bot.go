@@ -0,0 +1,244 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/summarizer"
+	"go.uber.org/zap"
+)
+
+const (
+	// botMessageLimit is the Bot API limit for one text message.
+	botMessageLimit = 4096
+	// botMaxRetries bounds retries of a message rejected with 429 Too Many Requests.
+	botMaxRetries = 3
+)
+
+// BotAPIError is an unsuccessful Bot API response.
+type BotAPIError struct {
+	Code        int
+	Description string
+	RetryAfter  time.Duration // set for 429 responses
+}
+
+func (e *BotAPIError) Error() string {
+	return fmt.Sprintf("bot api error %d: %s", e.Code, e.Description)
+}
+
+var _ DigestSender = (*BotSender)(nil)
+
+// BotSender pushes digests through the Telegram Bot HTTP API, so they come from
+// a bot instead of the user's own account. Messages use parse_mode=HTML.
+type BotSender struct {
+	apiURL     string
+	token      string
+	chatIDs    []int64
+	httpClient *http.Client
+	log        applog.Logger
+	sleep      func(ctx context.Context, d time.Duration) error
+}
+
+// NewBotSender creates a new instance of BotSender using injected config and logger.
+func NewBotSender(logger applog.Logger, cfg *config.Config) *BotSender {
+	return &BotSender{
+		apiURL:     strings.TrimRight(cfg.BotAPIURL, "/"),
+		token:      cfg.BotToken,
+		chatIDs:    cfg.BotChatIDs,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		log:        logger,
+		sleep:      sleepContext,
+	}
+}
+
+// NewBotChannels creates a sender per chat in TELEGRAM_BOT_CHAT_IDS, named
+// "bot:" + chat ID.
+func NewBotChannels(logger applog.Logger, cfg *config.Config) []Channel {
+	channels := make([]Channel, 0, len(cfg.BotChatIDs))
+	for _, chatID := range cfg.BotChatIDs {
+		s := NewBotSender(logger, cfg)
+		s.chatIDs = []int64{chatID}
+		channels = append(channels, Channel{Name: fmt.Sprintf("bot:%d", chatID), Sender: s})
+	}
+	return channels
+}
+
+type botSendMessageRequest struct {
+	ChatID             int64              `json:"chat_id"`
+	Text               string             `json:"text"`
+	ParseMode          string             `json:"parse_mode"`
+	LinkPreviewOptions botLinkPreviewOpts `json:"link_preview_options"`
+}
+
+type botLinkPreviewOpts struct {
+	IsDisabled bool `json:"is_disabled"`
+}
+
+type botResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// SendDigest implements the DigestSender interface.
+// Every target chat gets the whole digest; a failing chat does not stop the others.
+func (s *BotSender) SendDigest(ctx context.Context, digest *summarizer.Digest) error {
+	parts := packLines(telegramHTMLLines(digest), botMessageLimit, utf16Len, stripHTMLTags)
+	var errs []error
+	for _, chatID := range s.chatIDs {
+		for i, part := range parts {
+			if err := s.sendMessage(ctx, chatID, part); err != nil {
+				errs = append(errs, fmt.Errorf("chat %d, part %d/%d: %w", chatID, i+1, len(parts), err))
+				break
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	s.log.Info("Digest sent by bot",
+		zap.Int64("chat_id", digest.Chat.ID),
+		zap.Int("targets", len(s.chatIDs)),
+		zap.Int("parts", len(parts)),
+	)
+	return nil
+}
+
+// sendMessage calls sendMessage, waiting out 429 responses as told by retry_after.
+func (s *BotSender) sendMessage(ctx context.Context, chatID int64, text string) error {
+	for attempt := 0; ; attempt++ {
+		err := s.call(ctx, "sendMessage", botSendMessageRequest{
+			ChatID:             chatID,
+			Text:               text,
+			ParseMode:          "HTML",
+			LinkPreviewOptions: botLinkPreviewOpts{IsDisabled: true},
+		})
+		var apiErr *BotAPIError
+		if !errors.As(err, &apiErr) || apiErr.Code != http.StatusTooManyRequests || attempt >= botMaxRetries {
+			return err
+		}
+		wait := max(apiErr.RetryAfter, time.Second)
+		s.log.Warn("Bot API rate limit, retrying", zap.Int64("target", chatID), zap.Duration("retry_after", wait))
+		if err := s.sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *BotSender) call(ctx context.Context, method string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	endpoint := fmt.Sprintf("%s/bot%s/%s", s.apiURL, s.token, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("bot api %s: %w", method, stripURL(err))
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read bot api response: %w", err)
+	}
+	var out botResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return fmt.Errorf("decode bot api response (status %d): %w", resp.StatusCode, err)
+	}
+	if !out.OK {
+		return &BotAPIError{
+			Code:        out.ErrorCode,
+			Description: out.Description,
+			RetryAfter:  time.Duration(out.Parameters.RetryAfter) * time.Second,
+		}
+	}
+	return nil
+}
+
+// telegramHTMLLines renders the digest with the Bot API HTML subset, one list item
+// per line; an empty line separates sections.
+func telegramHTMLLines(d *summarizer.Digest) []string {
+	esc := html.EscapeString
+	refs := func(ids []int64) string {
+		var parts []string
+		for _, r := range d.Refs(ids) {
+			parts = append(parts, fmt.Sprintf(`<a href="%s">%s</a>`, esc(r.URL), esc(r.Label())))
+		}
+		if len(parts) == 0 {
+			return ""
+		}
+		return " (" + strings.Join(parts, ", ") + ")"
+	}
+
+	var lines []string
+	if d.Chat.Title != "" {
+		head := "<b>" + esc(d.Chat.Title) + "</b>"
+		if period := d.Period(); period != "" {
+			head += " — " + esc(period)
+		}
+		lines = append(lines, head, "")
+	}
+	if d.Overview != "" {
+		lines = append(lines, esc(d.Overview), "")
+	}
+	for _, t := range d.Topics {
+		lines = append(lines, fmt.Sprintf("• <b>%s</b> — %s%s", esc(t.Title), esc(t.Summary), refs(t.MessageIDs)))
+	}
+	if len(d.ActionItems) > 0 {
+		if len(d.Topics) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "<b>Action items</b>")
+		for _, a := range d.ActionItems {
+			line := "• "
+			if a.Owner != "" {
+				line += "<i>" + esc(a.Owner) + "</i>: "
+			}
+			lines = append(lines, line+esc(a.Text)+refs(a.MessageIDs))
+		}
+	}
+	if note := d.ChangesNote(); note != "" {
+		lines = append(lines, "", "<i>"+esc(note)+"</i>")
+	}
+	return lines
+}
+
+// stripURL drops the request URL that net/http puts into its errors: bot, webhook
+// and chat platform URLs contain tokens, which must stay out of logs and errors.
+func stripURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,205 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/storage"
+	"github.com/azalio/tg-summary/internal/summarizer"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBotAPI stands in for api.telegram.org and records sendMessage calls.
+type fakeBotAPI struct {
+	mu         sync.Mutex
+	messages   []botSendMessageRequest
+	rateLimits int // how many next calls answer 429
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path != "/botTOKEN/sendMessage" {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":404,"description":"Not Found"}`))
+		return
+	}
+	if f.rateLimits > 0 {
+		f.rateLimits--
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 7","parameters":{"retry_after":7}}`))
+		return
+	}
+	var req botSendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if req.ChatID == 403 {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`))
+		return
+	}
+	f.messages = append(f.messages, req)
+	_, _ = w.Write([]byte(`{"ok":true,"result":{}}`))
+}
+
+func newTestBotSender(t *testing.T, api *fakeBotAPI, chatIDs ...int64) (*BotSender, *[]time.Duration) {
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+
+	s := NewBotSender(logger, &config.Config{BotAPIURL: srv.URL, BotToken: "TOKEN", BotChatIDs: chatIDs})
+	var waits []time.Duration
+	s.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return s, &waits
+}
+
+func sampleDigest() *summarizer.Digest {
+	return &summarizer.Digest{
+		Chat: summarizer.ChatInfo{
+			ID:    1234567890,
+			Title: "Dev <team>",
+			Type:  "supergroup",
+			From:  time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC),
+			To:    time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+		},
+		Overview: "Release & hotfix",
+		Topics: []summarizer.Topic{
+			{Title: "Release", Summary: "Shipped v2", MessageIDs: []int64{10, 12}},
+		},
+		ActionItems: []summarizer.ActionItem{
+			{Text: "Write the changelog", Owner: "Anna", MessageIDs: []int64{15}},
+		},
+	}
+}
+
+func TestBotSender_SendsHTML(t *testing.T) {
+	api := &fakeBotAPI{}
+	s, _ := newTestBotSender(t, api, 100, 200)
+
+	require.NoError(t, s.SendDigest(context.Background(), sampleDigest()))
+	require.Len(t, api.messages, 2)
+	msg := api.messages[0]
+	require.Equal(t, int64(100), msg.ChatID)
+	require.Equal(t, int64(200), api.messages[1].ChatID)
+	require.Equal(t, "HTML", msg.ParseMode)
+	require.True(t, msg.LinkPreviewOptions.IsDisabled)
+	require.Equal(t, `<b>Dev &lt;team&gt;</b> — 17.10.2026 00:00 – 18.10.2026 00:00
+
+Release &amp; hotfix
+
+• <b>Release</b> — Shipped v2 (<a href="https://t.me/c/1234567890/10">#10</a>, <a href="https://t.me/c/1234567890/12">#12</a>)
+
+<b>Action items</b>
+• <i>Anna</i>: Write the changelog (<a href="https://t.me/c/1234567890/15">#15</a>)`, msg.Text)
+}
+
+func TestTelegramHTML_ChangesNote(t *testing.T) {
+	d := sampleDigest()
+	d.EditedIDs = []int64{12}
+	d.DeletedCount = 2
+	lines := telegramHTMLLines(d)
+	require.Contains(t, lines, `• <b>Release</b> — Shipped v2 (<a href="https://t.me/c/1234567890/10">#10</a>, <a href="https://t.me/c/1234567890/12">#12 ✎</a>)`)
+	require.Equal(t, "<i>✎ marks messages edited after posting. 2 messages deleted after posting are not included.</i>", lines[len(lines)-1])
+}
+
+func TestBotSender_SplitsLongDigests(t *testing.T) {
+	api := &fakeBotAPI{}
+	s, _ := newTestBotSender(t, api, 100)
+
+	d := sampleDigest()
+	for i := 0; i < 120; i++ {
+		d.Topics = append(d.Topics, summarizer.Topic{Title: "Topic", Summary: strings.Repeat("слово ", 10), MessageIDs: []int64{int64(i)}})
+	}
+	require.NoError(t, s.SendDigest(context.Background(), d))
+	require.Greater(t, len(api.messages), 1)
+	for _, m := range api.messages {
+		require.LessOrEqual(t, utf16Len(m.Text), botMessageLimit)
+		require.Equal(t, strings.Count(m.Text, "<b>"), strings.Count(m.Text, "</b>"))
+	}
+}
+
+func TestBotSender_RetriesOn429(t *testing.T) {
+	api := &fakeBotAPI{rateLimits: 2}
+	s, waits := newTestBotSender(t, api, 100)
+
+	require.NoError(t, s.SendDigest(context.Background(), sampleDigest()))
+	require.Len(t, api.messages, 1)
+	require.Equal(t, []time.Duration{7 * time.Second, 7 * time.Second}, *waits)
+}
+
+func TestBotSender_GivesUpAfterRetries(t *testing.T) {
+	api := &fakeBotAPI{rateLimits: botMaxRetries + 1}
+	s, waits := newTestBotSender(t, api, 100)
+
+	err := s.SendDigest(context.Background(), sampleDigest())
+	var apiErr *BotAPIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusTooManyRequests, apiErr.Code)
+	require.Len(t, *waits, botMaxRetries)
+}
+
+func TestBotSender_FailingTargetDoesNotStopOthers(t *testing.T) {
+	api := &fakeBotAPI{}
+	s, _ := newTestBotSender(t, api, 403, 100)
+
+	err := s.SendDigest(context.Background(), sampleDigest())
+	require.ErrorContains(t, err, "chat 403")
+	require.ErrorContains(t, err, "bot was blocked")
+	require.NotContains(t, err.Error(), "TOKEN")
+	require.Len(t, api.messages, 1)
+	require.Equal(t, int64(100), api.messages[0].ChatID)
+}
+
+func TestBotChannels_OutboxRetriesOnlyFailedChat(t *testing.T) {
+	api := &fakeBotAPI{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	defer cleanup()
+	channels := NewBotChannels(logger, &config.Config{BotAPIURL: srv.URL, BotToken: "TOKEN", BotChatIDs: []int64{100, 403}})
+	require.Equal(t, []string{"bot:100", "bot:403"}, []string{channels[0].Name, channels[1].Name})
+
+	o, st, clock := newTestOutbox(t, 5)
+	for _, ch := range channels {
+		o.Add(ch.Name, ch.Sender)
+	}
+	ctx := context.Background()
+	require.NoError(t, o.SendDigest(ctx, sampleDigest()))
+	require.NoError(t, o.Dispatch(ctx))
+	items := outboxItems(t, st)
+	require.Equal(t, storage.OutboxSent, items["bot:100"].Status)
+	require.Equal(t, storage.OutboxPending, items["bot:403"].Status)
+
+	clock.advance(time.Minute)
+	require.NoError(t, o.Dispatch(ctx))
+	require.Len(t, api.messages, 1, "the chat that got the digest does not get it again")
+	require.Equal(t, int64(100), api.messages[0].ChatID)
+}
+
+func TestPackLines(t *testing.T) {
+	count := func(s string) int { return len([]rune(s)) }
+	lines := []string{"title", "", "aaaa", "bbbb", "", "cccc"}
+	require.Equal(t, []string{"title\n\naaaa", "bbbb\n\ncccc"}, packLines(lines, 12, count, nil))
+
+	long := "<b>one</b> two &amp; three four"
+	require.Equal(t, []string{"one two", "&amp;", "three four"}, packLines([]string{long}, 10, count, stripHTMLTags))
+
+	linked := "see <https://x.io|#1 (edited)> now"
+	require.Equal(t, []string{"see", "<https://x.io|#1 (edited)>", "now"}, packLines([]string{linked}, 26, count, nil))
+}
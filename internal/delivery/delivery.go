@@ -1,26 +1,80 @@
 package delivery
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/summarizer"
+	"github.com/azalio/tg-summary/internal/telegram"
+	"go.uber.org/zap"
+)
 
 // DigestSender defines the interface for sending digests.
 type DigestSender interface {
-	SendDigest(chatID int64, digest string) error
+	// SendDigest delivers a digest; ctx cancels and bounds the delivery.
+	SendDigest(ctx context.Context, digest *summarizer.Digest) error
 }
 
+var _ DigestSender = (*TelegramDigestSender)(nil)
+
 // TelegramDigestSender is the production implementation using Telegram.
+// Digests are sent from the user account through the same authorized session as
+// the collector: to the account's Saved Messages by default, or to a configured peer.
+// SendDigest must therefore be called from within TelegramClient.Run.
 type TelegramDigestSender struct {
-	// Add fields needed for Telegram client/bot, e.g., botToken string, client *tgbotapi.BotAPI
+	client telegram.TelegramClient
+	peer   int64
+	log    applog.Logger
 }
 
 // NewTelegramDigestSender creates a new instance of TelegramDigestSender.
-func NewTelegramDigestSender() *TelegramDigestSender {
-	// TODO: Initialize Telegram client/bot here
-	return &TelegramDigestSender{}
+func NewTelegramDigestSender(logger applog.Logger, client telegram.TelegramClient, cfg *config.Config) *TelegramDigestSender {
+	return &TelegramDigestSender{
+		client: client,
+		peer:   cfg.DigestPeer,
+		log:    logger,
+	}
 }
 
 // SendDigest implements the DigestSender interface.
-func (s *TelegramDigestSender) SendDigest(chatID int64, digest string) error {
-	// TODO: Implement sending digest via Telegram
-	fmt.Printf("TelegramDigestSender: SendDigest called for chat %d (not implemented)\n", chatID)
+func (s *TelegramDigestSender) SendDigest(ctx context.Context, digest *summarizer.Digest) error {
+	if err := s.client.SendMessage(ctx, s.peer, digest.Text()); err != nil {
+		return err
+	}
+	s.log.Info("Digest sent to Telegram", zap.Int64("chat_id", digest.Chat.ID), zap.Int64("peer", s.peer))
 	return nil
 }
+
+var _ DigestSender = (*MultiSender)(nil)
+
+// MultiSender delivers every digest through all added channels.
+// A failing channel does not stop the others; errors are returned joined.
+type MultiSender struct {
+	names   []string
+	senders []DigestSender
+}
+
+// NewMultiSender creates an empty MultiSender.
+func NewMultiSender() *MultiSender {
+	return &MultiSender{}
+}
+
+// Add registers a delivery channel under a name used in errors.
+func (m *MultiSender) Add(name string, sender DigestSender) {
+	m.names = append(m.names, name)
+	m.senders = append(m.senders, sender)
+}
+
+// SendDigest implements the DigestSender interface.
+func (m *MultiSender) SendDigest(ctx context.Context, digest *summarizer.Digest) error {
+	var errs []error
+	for i, s := range m.senders {
+		if err := s.SendDigest(ctx, digest); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.names[i], err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -1,8 +1,17 @@
 package delivery
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/summarizer"
+	"github.com/azalio/tg-summary/internal/telegram"
+	gotdtelegram "github.com/gotd/td/telegram"
+	"github.com/stretchr/testify/require"
 )
 
 // MockDigestSender is a mock implementation of DigestSender for testing
@@ -20,23 +29,87 @@ func NewMockDigestSender() *MockDigestSender {
 }
 
 // SendDigest implements the DigestSender interface for the mock
-func (m *MockDigestSender) SendDigest(chatID int64, digest string) error {
-	fmt.Printf("MockDigestSender: SendDigest called for chat %d\n", chatID)
+func (m *MockDigestSender) SendDigest(ctx context.Context, digest *summarizer.Digest) error {
+	fmt.Printf("MockDigestSender: SendDigest called for chat %d\n", digest.Chat.ID)
 	if m.SendError != nil {
 		return m.SendError
 	}
-	m.SentDigests[chatID] = digest
+	m.SentDigests[digest.Chat.ID] = digest.Text()
 	return nil
 }
 
 // Example test using the mock (keep testing import)
 func TestDeliveryMock(t *testing.T) {
 	mockSender := NewMockDigestSender()
-	err := mockSender.SendDigest(123, "Test digest")
+	err := mockSender.SendDigest(context.Background(), testDigest(123, "Test digest"))
 	if err != nil {
 		t.Errorf("SendDigest failed: %v", err)
 	}
 	if _, ok := mockSender.SentDigests[123]; !ok {
 		t.Errorf("Digest for chat 123 was not sent")
 	}
-}
\ No newline at end of file
+}
+
+// fakeTelegramClient records sent messages.
+type fakeTelegramClient struct {
+	sent    map[int64][]string
+	sendErr error
+}
+
+func (f *fakeTelegramClient) Run(ctx context.Context, fn func(ctx context.Context, api *gotdtelegram.Client) error) error {
+	return fn(ctx, nil)
+}
+
+func (f *fakeTelegramClient) FetchMessages(ctx context.Context, chatID int64, from, to int64) ([]telegram.Message, error) {
+	return nil, nil
+}
+
+func (f *fakeTelegramClient) ListGroups(ctx context.Context, api *gotdtelegram.Client) ([]telegram.GroupInfo, error) {
+	return nil, nil
+}
+
+func (f *fakeTelegramClient) SendMessage(ctx context.Context, chatID int64, text string) error {
+	if f.sendErr != nil {
+		return f.sendErr
+	}
+	f.sent[chatID] = append(f.sent[chatID], text)
+	return nil
+}
+
+func TestTelegramDigestSender(t *testing.T) {
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+	ctx := context.Background()
+
+	client := &fakeTelegramClient{sent: make(map[int64][]string)}
+	saved := NewTelegramDigestSender(logger, client, &config.Config{})
+	require.NoError(t, saved.SendDigest(ctx, testDigest(-100500, "digest")))
+	require.Equal(t, []string{"digest"}, client.sent[telegram.SavedMessagesChatID])
+
+	peer := NewTelegramDigestSender(logger, client, &config.Config{DigestPeer: 777})
+	require.NoError(t, peer.SendDigest(ctx, testDigest(-100500, "other")))
+	require.Equal(t, []string{"other"}, client.sent[777])
+
+	client.sendErr = telegram.ErrNotRunning
+	require.True(t, errors.Is(peer.SendDigest(ctx, testDigest(1, "x")), telegram.ErrNotRunning))
+}
+
+func TestMultiSender(t *testing.T) {
+	ok := NewMockDigestSender()
+	failing := NewMockDigestSender()
+	failing.SendError = errors.New("boom")
+
+	m := NewMultiSender()
+	m.Add("failing", failing)
+	m.Add("ok", ok)
+	err := m.SendDigest(context.Background(), testDigest(5, "hello"))
+	require.ErrorIs(t, err, failing.SendError)
+	require.Contains(t, err.Error(), "failing: boom")
+	require.Equal(t, "hello", ok.SentDigests[5])
+}
+
+// testDigest returns a digest with an overview only, so Text() equals overview.
+func testDigest(chatID int64, overview string) *summarizer.Digest {
+	return &summarizer.Digest{Chat: summarizer.ChatInfo{ID: chatID}, Overview: overview}
+}
@@ -0,0 +1,192 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/summarizer"
+	"go.uber.org/zap"
+)
+
+const (
+	// discordMessageLimit bounds the text of one webhook message. Embed descriptions
+	// allow more, but 2000 keeps each part in line with the plain message limit.
+	discordMessageLimit = 2000
+	discordTitleLimit   = 256
+	discordEmbedColor   = 0x229ED9 // Telegram blue
+)
+
+var _ DigestSender = (*DiscordSender)(nil)
+
+// DiscordSender posts digests to Discord webhooks as embeds.
+type DiscordSender struct {
+	urls  []string
+	first int // number of urls[0] in DISCORD_WEBHOOK_URLS, for errors
+	api   jsonAPI
+	log   applog.Logger
+}
+
+// NewDiscordSender creates a new instance of DiscordSender using injected config and logger.
+func NewDiscordSender(logger applog.Logger, cfg *config.Config) *DiscordSender {
+	return &DiscordSender{
+		urls:  cfg.DiscordWebhookURLs,
+		first: 1,
+		api:   newJSONAPI(logger),
+		log:   logger,
+	}
+}
+
+// NewDiscordChannels creates a sender per URL in DISCORD_WEBHOOK_URLS, named
+// "discord:" + hash of the URL.
+func NewDiscordChannels(logger applog.Logger, cfg *config.Config) []Channel {
+	channels := make([]Channel, 0, len(cfg.DiscordWebhookURLs))
+	for i, url := range cfg.DiscordWebhookURLs {
+		s := NewDiscordSender(logger, cfg)
+		s.urls, s.first = []string{url}, i+1
+		channels = append(channels, Channel{Name: urlChannelName("discord", url), Sender: s})
+	}
+	return channels
+}
+
+type discordMessage struct {
+	Embeds          []discordEmbed         `json:"embeds"`
+	AllowedMentions discordAllowedMentions `json:"allowed_mentions"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title,omitempty"`
+	Description string         `json:"description"`
+	Color       int            `json:"color"`
+	Footer      *discordFooter `json:"footer,omitempty"`
+}
+
+type discordFooter struct {
+	Text string `json:"text"`
+}
+
+// discordAllowedMentions with an empty Parse list keeps chat text like "@everyone" from pinging anyone.
+type discordAllowedMentions struct {
+	Parse []string `json:"parse"`
+}
+
+// SendDigest implements the DigestSender interface.
+// Every webhook gets the whole digest; a failing webhook does not stop the others.
+func (s *DiscordSender) SendDigest(ctx context.Context, digest *summarizer.Digest) error {
+	msgs := discordMessages(digest)
+	var errs []error
+	for i, raw := range s.urls {
+		endpoint, err := discordEndpoint(raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("discord webhook #%d: %w", s.first+i, err))
+			continue
+		}
+		for j, msg := range msgs {
+			if _, err := s.api.do(ctx, http.MethodPost, endpoint, nil, msg); err != nil {
+				errs = append(errs, fmt.Errorf("discord webhook #%d, part %d/%d: %w", s.first+i, j+1, len(msgs), err))
+				break
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	s.log.Info("Digest sent to Discord",
+		zap.Int64("chat_id", digest.Chat.ID),
+		zap.Int("webhooks", len(s.urls)),
+		zap.Int("parts", len(msgs)),
+	)
+	return nil
+}
+
+// discordEndpoint adds wait=true so that Discord reports rejected messages
+// instead of accepting them with 204. Existing parameters like thread_id are kept.
+func discordEndpoint(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", errors.New("invalid webhook URL")
+	}
+	q := u.Query()
+	q.Set("wait", "true")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// discordMessages renders the digest as Discord markdown and splits it into
+// messages of one embed each; the first carries the chat title.
+func discordMessages(d *summarizer.Digest) []discordMessage {
+	esc := discordEscape
+	refs := func(ids []int64) string {
+		var parts []string
+		for _, r := range d.Refs(ids) {
+			parts = append(parts, fmt.Sprintf("[%s](%s)", r.Label(), r.URL))
+		}
+		if len(parts) == 0 {
+			return ""
+		}
+		return " (" + strings.Join(parts, ", ") + ")"
+	}
+
+	var lines []string
+	if d.Overview != "" {
+		lines = append(lines, esc(d.Overview), "")
+	}
+	for _, t := range d.Topics {
+		lines = append(lines, fmt.Sprintf("**%s** — %s%s", esc(t.Title), esc(t.Summary), refs(t.MessageIDs)))
+	}
+	if len(d.ActionItems) > 0 {
+		if len(d.Topics) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "**Action items**")
+		for _, a := range d.ActionItems {
+			line := "• "
+			if a.Owner != "" {
+				line += "*" + esc(a.Owner) + "*: "
+			}
+			lines = append(lines, line+esc(a.Text)+refs(a.MessageIDs))
+		}
+	}
+	if note := d.ChangesNote(); note != "" {
+		lines = append(lines, "", "*"+esc(note)+"*")
+	}
+
+	parts := packLines(lines, discordMessageLimit, utf16Len, nil)
+	if len(parts) == 0 {
+		parts = []string{""}
+	}
+	msgs := make([]discordMessage, 0, len(parts))
+	for i, part := range parts {
+		embed := discordEmbed{Description: part, Color: discordEmbedColor}
+		if i == 0 {
+			embed.Title = truncateRunes(d.Chat.Title, discordTitleLimit)
+		}
+		footer := d.Period()
+		if len(parts) > 1 {
+			footer = strings.TrimPrefix(fmt.Sprintf("%s · %d/%d", footer, i+1, len(parts)), " · ")
+		}
+		if footer != "" {
+			embed.Footer = &discordFooter{Text: footer}
+		}
+		msgs = append(msgs, discordMessage{Embeds: []discordEmbed{embed}, AllowedMentions: discordAllowedMentions{Parse: []string{}}})
+	}
+	return msgs
+}
+
+var discordEscaper = strings.NewReplacer(
+	`\`, `\\`, `*`, `\*`, `_`, `\_`, `~`, `\~`, "`", "\\`", `|`, `\|`, `[`, `\[`, `]`, `\]`,
+)
+
+// discordEscape keeps chat text from being read as Discord markdown.
+func discordEscape(s string) string {
+	s = discordEscaper.Replace(s)
+	if strings.HasPrefix(s, ">") || strings.HasPrefix(s, "#") || strings.HasPrefix(s, "-") {
+		s = `\` + s
+	}
+	return s
+}
@@ -0,0 +1,69 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/summarizer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscordSender_Embeds(t *testing.T) {
+	hook := &fakeChatAPI{}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	defer cleanup()
+	s := NewDiscordSender(logger, &config.Config{DiscordWebhookURLs: []string{srv.URL + "/api/webhooks/1/tok?thread_id=42"}})
+	s.api, _ = testJSONAPI(t)
+
+	d := sampleDigest()
+	d.Topics[0].Summary = "Shipped *v2* to @everyone"
+	require.NoError(t, s.SendDigest(context.Background(), d))
+
+	var msg discordMessage
+	req := hook.decode(t, 0, &msg)
+	require.Equal(t, "true", req.URL.Query().Get("wait"))
+	require.Equal(t, "42", req.URL.Query().Get("thread_id"))
+	require.NotNil(t, msg.AllowedMentions.Parse)
+	require.Empty(t, msg.AllowedMentions.Parse)
+	require.Len(t, msg.Embeds, 1)
+	embed := msg.Embeds[0]
+	require.Equal(t, "Dev <team>", embed.Title)
+	require.Equal(t, "17.10.2026 00:00 – 18.10.2026 00:00", embed.Footer.Text)
+	require.Equal(t, "Release & hotfix\n\n"+
+		`**Release** — Shipped \*v2\* to @everyone ([#10](https://t.me/c/1234567890/10), [#12](https://t.me/c/1234567890/12))`+"\n\n"+
+		"**Action items**\n"+
+		"• *Anna*: Write the changelog ([#15](https://t.me/c/1234567890/15))", embed.Description)
+}
+
+func TestDiscordMessages_Splits(t *testing.T) {
+	d := sampleDigest()
+	d.Topics = nil
+	for i := range 40 {
+		d.Topics = append(d.Topics, summarizer.Topic{Title: fmt.Sprintf("Topic %d", i), Summary: strings.Repeat("слово ", 20)})
+	}
+
+	msgs := discordMessages(d)
+	require.Greater(t, len(msgs), 1)
+	for i, m := range msgs {
+		require.LessOrEqual(t, utf16Len(m.Embeds[0].Description), discordMessageLimit)
+		require.Equal(t, fmt.Sprintf("17.10.2026 00:00 – 18.10.2026 00:00 · %d/%d", i+1, len(msgs)), m.Embeds[0].Footer.Text)
+		if i > 0 {
+			require.Empty(t, m.Embeds[0].Title)
+		}
+	}
+	require.Equal(t, "Dev <team>", msgs[0].Embeds[0].Title)
+}
+
+func TestDiscordEscape(t *testing.T) {
+	require.Equal(t, `a\_b \*c\* \[x\](y) \`+"`z\\`", discordEscape("a_b *c* [x](y) `z`"))
+	require.Equal(t, `\> quote`, discordEscape("> quote"))
+	require.Equal(t, `\# heading`, discordEscape("# heading"))
+}
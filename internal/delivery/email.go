@@ -0,0 +1,357 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/summarizer"
+	"go.uber.org/zap"
+)
+
+// SMTP connection security modes.
+const (
+	SMTPStartTLS = "starttls" // plain connection upgraded with STARTTLS (port 587)
+	SMTPTLS      = "tls"      // implicit TLS (port 465)
+	SMTPNone     = "none"     // no encryption, for local relays only
+)
+
+// DefaultEmailSubject is used when SMTP_SUBJECT is not set.
+const DefaultEmailSubject = "Дайджест {{.Title}} за {{.Date}}"
+
+var _ DigestSender = (*EmailSender)(nil)
+
+// EmailSender delivers digests by SMTP as multipart/alternative messages with
+// an HTML rendering and a plaintext fallback.
+type EmailSender struct {
+	host      string
+	port      int
+	security  string
+	username  string
+	password  string
+	from      *mail.Address
+	to        []*mail.Address
+	subject   *template.Template
+	tlsConfig *tls.Config
+	log       applog.Logger
+	now       func() time.Time
+}
+
+// NewEmailSender creates a new instance of EmailSender using injected config and logger.
+func NewEmailSender(logger applog.Logger, cfg *config.Config) (*EmailSender, error) {
+	switch cfg.SMTPSecurity {
+	case SMTPStartTLS, SMTPTLS, SMTPNone:
+	default:
+		return nil, fmt.Errorf("unknown SMTP security mode %q", cfg.SMTPSecurity)
+	}
+	if cfg.SMTPFrom == "" || len(cfg.SMTPTo) == 0 {
+		return nil, errors.New("email delivery requires a sender and at least one recipient")
+	}
+	// Both "addr" and "Name <addr>" are accepted: the address goes to the SMTP
+	// envelope, the full form to the headers.
+	from, err := mail.ParseAddress(cfg.SMTPFrom)
+	if err != nil {
+		return nil, fmt.Errorf("parse SMTP_FROM: %w", err)
+	}
+	to, err := mail.ParseAddressList(strings.Join(cfg.SMTPTo, ", "))
+	if err != nil {
+		return nil, fmt.Errorf("parse SMTP_TO: %w", err)
+	}
+	subject := cfg.SMTPSubject
+	if subject == "" {
+		subject = DefaultEmailSubject
+	}
+	tmpl, err := template.New("subject").Parse(subject)
+	if err != nil {
+		return nil, fmt.Errorf("parse subject template: %w", err)
+	}
+	return &EmailSender{
+		host:      cfg.SMTPHost,
+		port:      cfg.SMTPPort,
+		security:  cfg.SMTPSecurity,
+		username:  cfg.SMTPUsername,
+		password:  cfg.SMTPPassword,
+		from:      from,
+		to:        to,
+		subject:   tmpl,
+		tlsConfig: &tls.Config{ServerName: cfg.SMTPHost, MinVersion: tls.VersionTLS12},
+		log:       logger,
+		now:       time.Now,
+	}, nil
+}
+
+// subjectData is available to the subject template.
+type subjectData struct {
+	Title  string // chat title
+	Date   string // first day of the digest window, 02.01.2006
+	Period string // full window, see Digest.Period
+	ChatID int64
+}
+
+// SendDigest implements the DigestSender interface.
+func (s *EmailSender) SendDigest(ctx context.Context, digest *summarizer.Digest) error {
+	msg, err := s.buildMessage(digest)
+	if err != nil {
+		return err
+	}
+	if err := s.send(ctx, msg); err != nil {
+		return fmt.Errorf("smtp %s:%d: %w", s.host, s.port, err)
+	}
+	s.log.Info("Digest sent by email", zap.Int64("chat_id", digest.Chat.ID), zap.Int("recipients", len(s.to)))
+	return nil
+}
+
+// send delivers one message over a new SMTP session. Cancelling ctx closes the connection.
+func (s *EmailSender) send(ctx context.Context, msg []byte) error {
+	addr := net.JoinHostPort(s.host, strconv.Itoa(s.port))
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	if s.security == SMTPTLS {
+		tlsConn := tls.Client(conn, s.tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return err
+		}
+		conn = tlsConn
+	}
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if s.security == SMTPStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("server does not support STARTTLS")
+		}
+		if err := c.StartTLS(s.tlsConfig); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+	if s.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+	if err := c.Mail(s.from.Address); err != nil {
+		return err
+	}
+	for _, rcpt := range s.to {
+		if err := c.Rcpt(rcpt.Address); err != nil {
+			return fmt.Errorf("recipient %s: %w", rcpt.Address, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// buildMessage renders the full RFC 5322 message.
+func (s *EmailSender) buildMessage(d *summarizer.Digest) ([]byte, error) {
+	now := s.now()
+	date := d.Chat.From
+	if date.IsZero() {
+		date = now
+	}
+	var subject strings.Builder
+	err := s.subject.Execute(&subject, subjectData{
+		Title:  d.Chat.Title,
+		Date:   date.Format("02.01.2006"),
+		Period: d.Period(),
+		ChatID: d.Chat.ID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("render subject: %w", err)
+	}
+	var htmlBody bytes.Buffer
+	if err := emailHTML.Execute(&htmlBody, newDigestView(d)); err != nil {
+		return nil, fmt.Errorf("render html: %w", err)
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+	to := make([]string, 0, len(s.to))
+	for _, a := range s.to {
+		to = append(to, a.String())
+	}
+	header("From", s.from.String())
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", messageID(s.from.Address))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=utf-8", plainText(d)},
+		{"text/html; charset=utf-8", htmlBody.String()},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndexByte(from, '@'); at >= 0 {
+		domain = from[at+1:]
+	}
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return "<" + hex.EncodeToString(b[:]) + "@" + domain + ">"
+}
+
+// plainText renders the digest without markup; links follow the message numbers.
+func plainText(d *summarizer.Digest) string {
+	refs := func(ids []int64) string {
+		var parts []string
+		for _, r := range d.Refs(ids) {
+			parts = append(parts, r.Label()+" "+r.URL)
+		}
+		if len(parts) == 0 {
+			return ""
+		}
+		return " (" + strings.Join(parts, ", ") + ")"
+	}
+	var b strings.Builder
+	if d.Chat.Title != "" {
+		b.WriteString(d.Chat.Title)
+		if period := d.Period(); period != "" {
+			b.WriteString(" — " + period)
+		}
+		b.WriteString("\n\n")
+	}
+	if d.Overview != "" {
+		b.WriteString(d.Overview + "\n\n")
+	}
+	for _, t := range d.Topics {
+		fmt.Fprintf(&b, "* %s — %s%s\n", t.Title, t.Summary, refs(t.MessageIDs))
+	}
+	if len(d.ActionItems) > 0 {
+		b.WriteString("\nAction items\n")
+		for _, a := range d.ActionItems {
+			b.WriteString("* ")
+			if a.Owner != "" {
+				b.WriteString(a.Owner + ": ")
+			}
+			b.WriteString(a.Text + refs(a.MessageIDs) + "\n")
+		}
+	}
+	if note := d.ChangesNote(); note != "" {
+		b.WriteString("\n" + note + "\n")
+	}
+	return strings.TrimSpace(b.String()) + "\n"
+}
+
+// digestView is the digest prepared for the HTML templates.
+type digestView struct {
+	Title       string
+	Period      string
+	Overview    string
+	Topics      []digestItem
+	ActionItems []digestItem
+	Note        string // see Digest.ChangesNote
+}
+
+type digestItem struct {
+	Title string
+	Text  string
+	Refs  []summarizer.Ref
+}
+
+func newDigestView(d *summarizer.Digest) digestView {
+	v := digestView{Title: d.Chat.Title, Period: d.Period(), Overview: d.Overview, Note: d.ChangesNote()}
+	for _, t := range d.Topics {
+		v.Topics = append(v.Topics, digestItem{Title: t.Title, Text: t.Summary, Refs: d.Refs(t.MessageIDs)})
+	}
+	for _, a := range d.ActionItems {
+		v.ActionItems = append(v.ActionItems, digestItem{Title: a.Owner, Text: a.Text, Refs: d.Refs(a.MessageIDs)})
+	}
+	return v
+}
+
+// digestHTML renders the digest as an HTML fragment; the email wraps it in a
+// document, Matrix sends it as formatted_body.
+var digestHTML = htmltemplate.Must(htmltemplate.New("digest").Parse(`
+{{- with .Title}}<h2>{{.}}</h2>{{end}}
+{{- with .Period}}<p style="color: #666;">{{.}}</p>{{end}}
+{{- with .Overview}}<p>{{.}}</p>{{end}}
+{{- with .Topics}}
+<ul>
+{{- range .}}
+<li><b>{{.Title}}</b> — {{.Text}}{{template "refs" .Refs}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- with .ActionItems}}
+<h3>Action items</h3>
+<ul>
+{{- range .}}
+<li>{{with .Title}}<i>{{.}}</i>: {{end}}{{.Text}}{{template "refs" .Refs}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- with .Note}}<p style="color: #666;"><i>{{.}}</i></p>{{end}}
+{{- define "refs"}}{{if .}} ({{range $i, $r := .}}{{if $i}}, {{end}}<a href="{{$r.URL}}">{{$r.Label}}</a>{{end}}){{end}}{{end}}`))
+
+var emailHTML = htmltemplate.Must(htmltemplate.Must(digestHTML.Clone()).New("email").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"></head>
+<body style="font-family: sans-serif; line-height: 1.4;">
+{{- template "digest" .}}
+</body>
+</html>
+`))
@@ -0,0 +1,318 @@
+package delivery
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSMTPServer is a minimal in-process SMTP server that accepts one message per session.
+type fakeSMTPServer struct {
+	ln        net.Listener
+	tlsConfig *tls.Config // for STARTTLS
+	startTLS  bool
+
+	mu       sync.Mutex
+	auth     string
+	from     string
+	rcpts    []string
+	data     string
+	upgraded bool
+}
+
+func newFakeSMTPServer(t *testing.T, ln net.Listener, tlsConfig *tls.Config, startTLS bool) *fakeSMTPServer {
+	s := &fakeSMTPServer{ln: ln, tlsConfig: tlsConfig, startTLS: startTLS}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			if s.startTLS && !s.isUpgraded() {
+				_ = tp.PrintfLine("250-fake\r\n250-STARTTLS\r\n250 AUTH PLAIN")
+			} else {
+				_ = tp.PrintfLine("250-fake\r\n250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			_ = tp.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.upgraded = true
+			s.mu.Unlock()
+			conn = tlsConn
+			tp = textproto.NewConn(conn)
+		case "AUTH":
+			s.mu.Lock()
+			s.auth = arg
+			s.mu.Unlock()
+			_ = tp.PrintfLine("235 ok")
+		case "MAIL":
+			s.mu.Lock()
+			s.from = arg
+			s.mu.Unlock()
+			_ = tp.PrintfLine("250 ok")
+		case "RCPT":
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, arg)
+			s.mu.Unlock()
+			_ = tp.PrintfLine("250 ok")
+		case "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+			data, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data = string(data)
+			s.mu.Unlock()
+			_ = tp.PrintfLine("250 queued")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+// received returns what the last session delivered.
+func (s *fakeSMTPServer) received() (auth, from string, rcpts []string, data string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.auth, s.from, s.rcpts, s.data
+}
+
+func (s *fakeSMTPServer) isUpgraded() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.upgraded
+}
+
+// testTLS borrows the self-signed certificate of httptest (valid for 127.0.0.1).
+func testTLS(t *testing.T) (server *tls.Config, roots *x509.CertPool) {
+	srv := httptest.NewTLSServer(nil)
+	t.Cleanup(srv.Close)
+	roots = x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	return &tls.Config{Certificates: srv.TLS.Certificates}, roots
+}
+
+func newTestEmailSender(t *testing.T, addr net.Addr, security string, roots *x509.CertPool) *EmailSender {
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+	tcp := addr.(*net.TCPAddr)
+	s, err := NewEmailSender(logger, &config.Config{
+		SMTPHost:     "127.0.0.1",
+		SMTPPort:     tcp.Port,
+		SMTPSecurity: security,
+		SMTPUsername: "bot",
+		SMTPPassword: "secret",
+		SMTPFrom:     "Digest Bot <digest@example.com>",
+		SMTPTo:       []string{"Anna <anna@example.com>", "ivan@example.com"},
+		SMTPSubject:  "{{.Title}} {{.Date}}",
+	})
+	require.NoError(t, err)
+	if roots != nil {
+		s.tlsConfig.RootCAs = roots
+	}
+	s.now = func() time.Time { return time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC) }
+	return s
+}
+
+// parseEmail returns the decoded subject and the plaintext and HTML parts.
+func parseEmail(t *testing.T, data string) (subject, plain, html string) {
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	require.NoError(t, err)
+	subject, err = new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/alternative", mediaType)
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, "quoted-printable", p.Header.Get("Content-Transfer-Encoding"))
+		body, err := io.ReadAll(quotedprintable.NewReader(p))
+		require.NoError(t, err)
+		switch ct := p.Header.Get("Content-Type"); ct {
+		case "text/plain; charset=utf-8":
+			plain = string(body)
+		case "text/html; charset=utf-8":
+			html = string(body)
+		default:
+			t.Fatalf("unexpected part %s", ct)
+		}
+	}
+	return subject, plain, html
+}
+
+func TestEmailSender_PlainSMTP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := newFakeSMTPServer(t, ln, nil, false)
+	s := newTestEmailSender(t, ln.Addr(), SMTPNone, nil)
+
+	require.NoError(t, s.SendDigest(context.Background(), sampleDigest()))
+
+	auth, from, rcpts, data := srv.received()
+	require.Equal(t, "FROM:<digest@example.com>", from)
+	require.Equal(t, []string{"TO:<anna@example.com>", "TO:<ivan@example.com>"}, rcpts)
+	require.Contains(t, data, "From: \"Digest Bot\" <digest@example.com>\n")
+	require.Contains(t, data, "To: \"Anna\" <anna@example.com>, <ivan@example.com>\n")
+	require.Regexp(t, `Message-ID: <[0-9a-f]+@example\.com>\n`, data)
+	method, creds, _ := strings.Cut(auth, " ")
+	require.Equal(t, "PLAIN", method)
+	decoded, err := base64.StdEncoding.DecodeString(creds)
+	require.NoError(t, err)
+	require.Equal(t, "\x00bot\x00secret", string(decoded))
+
+	subject, plain, html := parseEmail(t, data)
+	require.Equal(t, "Dev <team> 17.10.2026", subject)
+	require.Contains(t, plain, "Dev <team> — 17.10.2026 00:00 – 18.10.2026 00:00")
+	require.Contains(t, plain, "* Release — Shipped v2 (#10 https://t.me/c/1234567890/10, #12 https://t.me/c/1234567890/12)")
+	require.Contains(t, plain, "* Anna: Write the changelog")
+	require.Contains(t, html, "<h2>Dev &lt;team&gt;</h2>")
+	require.Contains(t, html, "<p>Release &amp; hotfix</p>")
+	require.Contains(t, html, `<li><b>Release</b> — Shipped v2 (<a href="https://t.me/c/1234567890/10">#10</a>, <a href="https://t.me/c/1234567890/12">#12</a>)</li>`)
+	require.Contains(t, html, "<li><i>Anna</i>: Write the changelog")
+}
+
+func TestEmailSender_ImplicitTLS(t *testing.T) {
+	serverTLS, roots := testTLS(t)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", serverTLS)
+	require.NoError(t, err)
+	srv := newFakeSMTPServer(t, ln, nil, false)
+	s := newTestEmailSender(t, ln.Addr(), SMTPTLS, roots)
+
+	require.NoError(t, s.SendDigest(context.Background(), sampleDigest()))
+	_, _, rcpts, data := srv.received()
+	require.Len(t, rcpts, 2)
+	require.NotEmpty(t, data)
+}
+
+func TestEmailSender_StartTLS(t *testing.T) {
+	serverTLS, roots := testTLS(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := newFakeSMTPServer(t, ln, serverTLS, true)
+	s := newTestEmailSender(t, ln.Addr(), SMTPStartTLS, roots)
+
+	require.NoError(t, s.SendDigest(context.Background(), sampleDigest()))
+	require.True(t, srv.isUpgraded())
+	_, _, _, data := srv.received()
+	require.NotEmpty(t, data)
+}
+
+func TestEmailSender_StartTLSRequired(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := newFakeSMTPServer(t, ln, nil, false)
+	s := newTestEmailSender(t, ln.Addr(), SMTPStartTLS, nil)
+
+	err = s.SendDigest(context.Background(), sampleDigest())
+	require.ErrorContains(t, err, "STARTTLS")
+	auth, _, _, data := srv.received()
+	require.Empty(t, auth, "credentials are never sent in clear text")
+	require.Empty(t, data)
+}
+
+func TestEmailSender_ContextCancelsSession(t *testing.T) {
+	// A server that accepts but never greets.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			_, _ = bufio.NewReader(conn).ReadString('\n')
+		}
+	}()
+	s := newTestEmailSender(t, ln.Addr(), SMTPNone, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	require.Error(t, s.SendDigest(ctx, sampleDigest()))
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestNewEmailSender_Validation(t *testing.T) {
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+	base := config.Config{SMTPHost: "smtp.example.com", SMTPPort: 587, SMTPSecurity: SMTPStartTLS, SMTPFrom: "a@example.com", SMTPTo: []string{"b@example.com"}}
+
+	_, err = NewEmailSender(logger, &base)
+	require.NoError(t, err)
+
+	bad := base
+	bad.SMTPSecurity = "ssl"
+	_, err = NewEmailSender(logger, &bad)
+	require.Error(t, err)
+
+	bad = base
+	bad.SMTPTo = nil
+	_, err = NewEmailSender(logger, &bad)
+	require.Error(t, err)
+
+	bad = base
+	bad.SMTPFrom = "Digest Bot <digest@"
+	_, err = NewEmailSender(logger, &bad)
+	require.ErrorContains(t, err, "SMTP_FROM")
+
+	bad = base
+	bad.SMTPTo = []string{"b@example.com", "not an address"}
+	_, err = NewEmailSender(logger, &bad)
+	require.ErrorContains(t, err, "SMTP_TO")
+
+	bad = base
+	bad.SMTPSubject = "{{.Title"
+	_, err = NewEmailSender(logger, &bad)
+	require.Error(t, err)
+}
@@ -0,0 +1,114 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	applog "github.com/azalio/tg-summary/internal/log"
+	"go.uber.org/zap"
+)
+
+// httpMaxRetries bounds retries of a request rejected with 429 Too Many Requests.
+const httpMaxRetries = 3
+
+// HTTPError is an unsuccessful response from a chat platform HTTP API.
+type HTTPError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // advised delay for 429 responses
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("http status %d: %s", e.StatusCode, e.Body)
+}
+
+// jsonAPI sends JSON requests to chat platform APIs (Slack, Discord, Matrix).
+// Webhook URLs and tokens are secrets, so they never appear in returned errors.
+type jsonAPI struct {
+	httpClient *http.Client
+	log        applog.Logger
+	sleep      func(ctx context.Context, d time.Duration) error
+}
+
+func newJSONAPI(logger applog.Logger) jsonAPI {
+	return jsonAPI{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		log:        logger,
+		sleep:      sleepContext,
+	}
+}
+
+// do sends payload and returns the response body, waiting out 429 responses.
+func (a jsonAPI) do(ctx context.Context, method, endpoint string, header http.Header, payload any) ([]byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		raw, err := a.doOnce(ctx, method, endpoint, header, body)
+		var httpErr *HTTPError
+		if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests || attempt >= httpMaxRetries {
+			return raw, err
+		}
+		wait := max(httpErr.RetryAfter, time.Second)
+		a.log.Warn("Rate limited, retrying", zap.Duration("retry_after", wait))
+		if err := a.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (a jsonAPI) doOnce(ctx context.Context, method, endpoint string, header http.Header, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, stripURL(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, stripURL(err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &HTTPError{
+			StatusCode: resp.StatusCode,
+			Body:       string(bytes.TrimSpace(raw[:min(len(raw), 512)])),
+			RetryAfter: retryAfter(resp.Header, raw),
+		}
+	}
+	return raw, nil
+}
+
+// retryAfter reads the advised delay from the Retry-After header (seconds) or,
+// failing that, from the body: Discord sends "retry_after" in seconds, Matrix
+// sends "retry_after_ms".
+func retryAfter(header http.Header, body []byte) time.Duration {
+	if secs, err := strconv.ParseFloat(header.Get("Retry-After"), 64); err == nil && secs > 0 {
+		return time.Duration(secs * float64(time.Second))
+	}
+	var fields struct {
+		RetryAfter   float64 `json:"retry_after"`
+		RetryAfterMS int64   `json:"retry_after_ms"`
+	}
+	if json.Unmarshal(body, &fields) != nil {
+		return 0
+	}
+	if fields.RetryAfterMS > 0 {
+		return time.Duration(fields.RetryAfterMS) * time.Millisecond
+	}
+	return time.Duration(fields.RetryAfter * float64(time.Second))
+}
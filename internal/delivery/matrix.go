@@ -0,0 +1,89 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/summarizer"
+	"go.uber.org/zap"
+)
+
+var _ DigestSender = (*MatrixSender)(nil)
+
+// MatrixSender posts digests to Matrix rooms through the client-server API as
+// m.notice events with an HTML formatted_body and a plaintext body.
+type MatrixSender struct {
+	homeserver string
+	token      string
+	roomIDs    []string
+	first      int // index of roomIDs[0] in MATRIX_ROOM_IDS, part of transaction IDs
+	api        jsonAPI
+	log        applog.Logger
+}
+
+// NewMatrixSender creates a new instance of MatrixSender using injected config and logger.
+func NewMatrixSender(logger applog.Logger, cfg *config.Config) *MatrixSender {
+	return &MatrixSender{
+		homeserver: cfg.MatrixHomeserver,
+		token:      cfg.MatrixAccessToken,
+		roomIDs:    cfg.MatrixRoomIDs,
+		api:        newJSONAPI(logger),
+		log:        logger,
+	}
+}
+
+// NewMatrixChannels creates a sender per room in MATRIX_ROOM_IDS, named "matrix:" +
+// room ID.
+func NewMatrixChannels(logger applog.Logger, cfg *config.Config) []Channel {
+	channels := make([]Channel, 0, len(cfg.MatrixRoomIDs))
+	for i, roomID := range cfg.MatrixRoomIDs {
+		s := NewMatrixSender(logger, cfg)
+		s.roomIDs, s.first = []string{roomID}, i
+		channels = append(channels, Channel{Name: "matrix:" + roomID, Sender: s})
+	}
+	return channels
+}
+
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format"`
+	FormattedBody string `json:"formatted_body"`
+}
+
+// SendDigest implements the DigestSender interface.
+// Every room gets the digest; a failing room does not stop the others.
+func (s *MatrixSender) SendDigest(ctx context.Context, digest *summarizer.Digest) error {
+	var html strings.Builder
+	if err := digestHTML.Execute(&html, newDigestView(digest)); err != nil {
+		return fmt.Errorf("render html: %w", err)
+	}
+	msg := matrixMessage{
+		// m.notice marks automated messages, so other bots do not react to them.
+		MsgType:       "m.notice",
+		Body:          plainText(digest),
+		Format:        "org.matrix.custom.html",
+		FormattedBody: html.String(),
+	}
+	header := http.Header{"Authorization": {"Bearer " + s.token}}
+	var errs []error
+	for i, roomID := range s.roomIDs {
+		// The transaction ID is derived from the digest, so outbox retries of the same event are idempotent.
+		endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s-%d",
+			s.homeserver, url.PathEscape(roomID), deliveryID(digest.Chat), s.first+i)
+		if _, err := s.api.do(ctx, http.MethodPut, endpoint, header, msg); err != nil {
+			errs = append(errs, fmt.Errorf("matrix room %s: %w", roomID, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	s.log.Info("Digest sent to Matrix", zap.Int64("chat_id", digest.Chat.ID), zap.Int("rooms", len(s.roomIDs)))
+	return nil
+}
@@ -0,0 +1,64 @@
+package delivery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatrixSender_FormattedBody(t *testing.T) {
+	hs := &fakeChatAPI{}
+	srv := httptest.NewServer(hs)
+	defer srv.Close()
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	defer cleanup()
+	s := NewMatrixSender(logger, &config.Config{
+		MatrixHomeserver:  srv.URL,
+		MatrixAccessToken: "syt_token",
+		MatrixRoomIDs:     []string{"!room:example.org", "!other:example.org"},
+	})
+	s.api, _ = testJSONAPI(t)
+
+	require.NoError(t, s.SendDigest(context.Background(), sampleDigest()))
+	require.Equal(t, 2, hs.count())
+
+	var msg matrixMessage
+	req := hs.decode(t, 0, &msg)
+	require.Equal(t, http.MethodPut, req.Method)
+	require.Equal(t, "Bearer syt_token", req.Header.Get("Authorization"))
+	require.True(t, strings.HasPrefix(req.URL.EscapedPath(), "/_matrix/client/v3/rooms/%21room:example.org/send/m.room.message/"), req.URL.EscapedPath())
+
+	require.Equal(t, "m.notice", msg.MsgType)
+	require.Equal(t, "org.matrix.custom.html", msg.Format)
+	require.Contains(t, msg.Body, "* Release — Shipped v2 (#10 https://t.me/c/1234567890/10, #12 https://t.me/c/1234567890/12)")
+	require.Contains(t, msg.FormattedBody, "<h2>Dev &lt;team&gt;</h2>")
+	require.Contains(t, msg.FormattedBody, `<li><b>Release</b> — Shipped v2 (<a href="https://t.me/c/1234567890/10">#10</a>, <a href="https://t.me/c/1234567890/12">#12</a>)</li>`)
+	require.NotContains(t, msg.FormattedBody, "<html>", "formatted_body is a fragment")
+
+	var other matrixMessage
+	req2 := hs.decode(t, 1, &other)
+	require.NotEqual(t, req.URL.Path[strings.LastIndexByte(req.URL.Path, '/'):], req2.URL.Path[strings.LastIndexByte(req2.URL.Path, '/'):], "each event gets its own transaction ID")
+
+	// A retry of the same digest reuses the transaction ID, so the homeserver drops the duplicate.
+	require.NoError(t, s.SendDigest(context.Background(), sampleDigest()))
+	retry := hs.decode(t, 2, &other)
+	require.Equal(t, req.URL.Path, retry.URL.Path)
+
+	// A room sent on its own keeps the transaction ID it gets among all the rooms.
+	channels := NewMatrixChannels(logger, &config.Config{
+		MatrixHomeserver:  srv.URL,
+		MatrixAccessToken: "syt_token",
+		MatrixRoomIDs:     []string{"!room:example.org", "!other:example.org"},
+	})
+	require.Equal(t, "matrix:!other:example.org", channels[1].Name)
+	require.NoError(t, channels[1].Sender.SendDigest(context.Background(), sampleDigest()))
+	own := hs.decode(t, 3, &other)
+	require.Equal(t, req2.URL.Path, own.URL.Path)
+}
@@ -0,0 +1,258 @@
+package delivery
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/storage"
+	"github.com/azalio/tg-summary/internal/summarizer"
+	"go.uber.org/zap"
+)
+
+const (
+	outboxBaseBackoff  = time.Minute
+	outboxMaxBackoff   = time.Hour
+	outboxPollInterval = time.Minute
+	outboxBatchSize    = 50
+	outboxSendTimeout  = 2 * time.Minute
+	outboxErrorLimit   = 1000 // characters of the last error kept per item
+)
+
+// errPermanent marks failures that retrying cannot fix.
+var errPermanent = errors.New("permanent failure")
+
+// OutboxStore persists outbox items; implemented by storage.GormStorage.
+type OutboxStore interface {
+	EnqueueOutbox(ctx context.Context, items []storage.OutboxItem) error
+	DueOutbox(ctx context.Context, now time.Time, limit int) ([]storage.OutboxItem, error)
+	SaveOutboxItem(ctx context.Context, item *storage.OutboxItem) error
+}
+
+var _ DigestSender = (*Outbox)(nil)
+
+// Outbox makes delivery durable. SendDigest only writes one item per channel to
+// storage; Run delivers them, retrying every channel on its own with exponential
+// backoff. Items still failing after maxAttempts are dead-lettered until retried
+// by hand. Delivery is at least once: an item sent right before a crash is sent again.
+type Outbox struct {
+	store       OutboxStore
+	names       []string
+	senders     map[string]DigestSender
+	maxAttempts int
+	wake        chan struct{}
+	log         applog.Logger
+	now         func() time.Time
+}
+
+// NewOutbox creates an Outbox without channels.
+func NewOutbox(logger applog.Logger, store OutboxStore, maxAttempts int) *Outbox {
+	return &Outbox{
+		store:       store,
+		senders:     make(map[string]DigestSender),
+		maxAttempts: max(maxAttempts, 1),
+		wake:        make(chan struct{}, 1),
+		log:         logger,
+		now:         time.Now,
+	}
+}
+
+// Add registers a delivery channel. The name is stored with every item,
+// so it must stay the same across restarts.
+func (o *Outbox) Add(name string, sender DigestSender) {
+	o.names = append(o.names, name)
+	o.senders[name] = sender
+}
+
+// Channel is one delivery target registered as its own outbox channel: a failing
+// target is retried on its own without sending the digest again to the targets
+// that already accepted it.
+type Channel struct {
+	Name   string // stable across restarts and free of tokens
+	Sender DigestSender
+}
+
+// urlChannelName names the channel of a secret URL by its hash.
+func urlChannelName(kind, url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return kind + ":" + hex.EncodeToString(sum[:4])
+}
+
+// outboxPayload is the stored digest. Digest.Chat is not serialized by the
+// digest itself, and the timezone name is kept to restore the window's location.
+type outboxPayload struct {
+	ChatID   int64              `json:"chat_id"`
+	Title    string             `json:"title"`
+	Type     string             `json:"type"`
+	From     time.Time          `json:"from"`
+	To       time.Time          `json:"to"`
+	Timezone string             `json:"timezone"`
+	Digest   *summarizer.Digest `json:"digest"`
+}
+
+// SendDigest implements the DigestSender interface by enqueueing the digest for
+// every channel. A digest already enqueued for the same chat and window is skipped,
+// so a re-run of the digest job does not deliver twice.
+func (o *Outbox) SendDigest(ctx context.Context, digest *summarizer.Digest) error {
+	payload, err := json.Marshal(outboxPayload{
+		ChatID:   digest.Chat.ID,
+		Title:    digest.Chat.Title,
+		Type:     digest.Chat.Type,
+		From:     digest.Chat.From,
+		To:       digest.Chat.To,
+		Timezone: digest.Chat.Location().String(),
+		Digest:   digest,
+	})
+	if err != nil {
+		return err
+	}
+	now := o.now().Unix()
+	key := digestKey(digest.Chat)
+	items := make([]storage.OutboxItem, 0, len(o.names))
+	for _, name := range o.names {
+		items = append(items, storage.OutboxItem{
+			DigestKey:     key,
+			Channel:       name,
+			ChatID:        digest.Chat.ID,
+			Payload:       string(payload),
+			Status:        storage.OutboxPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+	if err := o.store.EnqueueOutbox(ctx, items); err != nil {
+		return fmt.Errorf("enqueue digest: %w", err)
+	}
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// digestKey identifies a digest by chat and window: the same digest built again
+// has the same key.
+func digestKey(chat summarizer.ChatInfo) string {
+	return fmt.Sprintf("%d:%d:%d", chat.ID, chat.From.Unix(), chat.To.Unix())
+}
+
+// Run delivers due items until ctx is done: right after SendDigest and at least
+// once per poll interval. Senders are called from Run, so for the "telegram"
+// channel it must run within TelegramClient.Run.
+func (o *Outbox) Run(ctx context.Context) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+	for {
+		if err := o.Dispatch(ctx); err != nil && ctx.Err() == nil {
+			o.log.Error("Outbox dispatch failed", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-o.wake:
+		}
+	}
+}
+
+// Dispatch makes one delivery attempt for every due item.
+func (o *Outbox) Dispatch(ctx context.Context) error {
+	for {
+		items, err := o.store.DueOutbox(ctx, o.now(), outboxBatchSize)
+		if err != nil {
+			return fmt.Errorf("load due items: %w", err)
+		}
+		for i := range items {
+			if err := o.deliver(ctx, &items[i]); err != nil {
+				return err
+			}
+		}
+		// Attempted items are no longer due, so a full batch means there may be more.
+		if len(items) < outboxBatchSize {
+			return nil
+		}
+	}
+}
+
+// deliver attempts one item and records the outcome.
+func (o *Outbox) deliver(ctx context.Context, item *storage.OutboxItem) error {
+	err := o.send(ctx, item)
+	if err != nil && ctx.Err() != nil {
+		// Interrupted by shutdown: the attempt does not count, the item stays due.
+		return ctx.Err()
+	}
+	now := o.now()
+	item.Attempts++
+	item.UpdatedAt = now.Unix()
+	fields := []zap.Field{
+		zap.Int64("item", item.ID),
+		zap.String("channel", item.Channel),
+		zap.Int64("chat_id", item.ChatID),
+		zap.Int("attempt", item.Attempts),
+	}
+	switch {
+	case err == nil:
+		item.Status = storage.OutboxSent
+		item.LastError = ""
+		o.log.Info("Digest delivered", fields...)
+	case errors.Is(err, errPermanent) || item.Attempts >= o.maxAttempts:
+		item.Status = storage.OutboxDead
+		item.LastError = truncateRunes(err.Error(), outboxErrorLimit)
+		o.log.Error("Digest delivery failed permanently, moved to dead letters", append(fields, zap.Error(err))...)
+	default:
+		backoff := outboxBackoff(item.Attempts)
+		item.NextAttemptAt = now.Add(backoff).Unix()
+		item.LastError = truncateRunes(err.Error(), outboxErrorLimit)
+		o.log.Warn("Digest delivery failed, will retry", append(fields, zap.Duration("backoff", backoff), zap.Error(err))...)
+	}
+	if err := o.store.SaveOutboxItem(ctx, item); err != nil {
+		return fmt.Errorf("save outbox item %d: %w", item.ID, err)
+	}
+	return nil
+}
+
+func (o *Outbox) send(ctx context.Context, item *storage.OutboxItem) error {
+	sender, ok := o.senders[item.Channel]
+	if !ok {
+		return fmt.Errorf("%w: channel %q is not configured", errPermanent, item.Channel)
+	}
+	digest, err := decodeOutboxPayload(item.Payload)
+	if err != nil {
+		return fmt.Errorf("%w: decode payload: %v", errPermanent, err)
+	}
+	sendCtx, cancel := context.WithTimeout(ctx, outboxSendTimeout)
+	defer cancel()
+	return sender.SendDigest(sendCtx, digest)
+}
+
+func decodeOutboxPayload(raw string) (*summarizer.Digest, error) {
+	var p outboxPayload
+	if err := json.Unmarshal([]byte(raw), &p); err != nil {
+		return nil, err
+	}
+	if p.Digest == nil {
+		return nil, errors.New("no digest")
+	}
+	from, to := p.From, p.To
+	// JSON keeps only the UTC offset; restore the zone for rendering and timezone names.
+	if loc, err := time.LoadLocation(p.Timezone); err == nil && !from.IsZero() {
+		from, to = from.In(loc), to.In(loc)
+	}
+	p.Digest.Chat = summarizer.ChatInfo{ID: p.ChatID, Title: p.Title, Type: p.Type, From: from, To: to}
+	return p.Digest, nil
+}
+
+// outboxBackoff returns the delay after the given number of failed attempts.
+func outboxBackoff(attempts int) time.Duration {
+	d := outboxBaseBackoff
+	for i := 1; i < attempts && d < outboxMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, outboxMaxBackoff)
+}
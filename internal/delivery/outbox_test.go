@@ -0,0 +1,203 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/storage"
+	"github.com/azalio/tg-summary/internal/summarizer"
+	"github.com/stretchr/testify/require"
+)
+
+// flakySender fails the first failures calls, then records delivered digests.
+type flakySender struct {
+	mu        sync.Mutex
+	failures  int
+	calls     int
+	delivered []*summarizer.Digest
+}
+
+func (s *flakySender) SendDigest(_ context.Context, digest *summarizer.Digest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.calls <= s.failures {
+		return errors.New("channel unavailable")
+	}
+	s.delivered = append(s.delivered, digest)
+	return nil
+}
+
+type testClock struct{ t time.Time }
+
+func (c *testClock) now() time.Time          { return c.t }
+func (c *testClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestOutbox(t *testing.T, maxAttempts int) (*Outbox, *storage.GormStorage, *testClock) {
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+	st, err := storage.NewGormStorage(filepath.Join(t.TempDir(), "outbox.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { st.Close() })
+	require.NoError(t, st.Init(context.Background()))
+	clock := &testClock{t: time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)}
+	o := NewOutbox(logger, st, maxAttempts)
+	o.now = clock.now
+	return o, st, clock
+}
+
+func outboxItems(t *testing.T, st *storage.GormStorage) map[string]storage.OutboxItem {
+	items, err := st.ListOutbox(context.Background(), "", 100)
+	require.NoError(t, err)
+	byChannel := make(map[string]storage.OutboxItem)
+	for _, it := range items {
+		byChannel[it.Channel] = it
+	}
+	return byChannel
+}
+
+func TestOutbox_DeliversEveryChannel(t *testing.T) {
+	o, st, _ := newTestOutbox(t, 3)
+	tg, mail := &flakySender{}, &flakySender{}
+	o.Add("telegram", tg)
+	o.Add("email", mail)
+	ctx := context.Background()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	digest := sampleDigest()
+	digest.Chat.From = time.Date(2026, 10, 17, 0, 0, 0, 0, berlin)
+	digest.Chat.To = time.Date(2026, 10, 18, 0, 0, 0, 0, berlin)
+	require.NoError(t, o.SendDigest(ctx, digest))
+	require.Empty(t, tg.delivered, "SendDigest only enqueues")
+
+	require.NoError(t, o.Dispatch(ctx))
+	require.Len(t, tg.delivered, 1)
+	require.Len(t, mail.delivered, 1)
+	got := tg.delivered[0]
+	require.Equal(t, digest.Chat.ID, got.Chat.ID)
+	require.Equal(t, "Europe/Berlin", got.Chat.Location().String())
+	require.Equal(t, digest.Period(), got.Period())
+	require.Equal(t, digest.Topics, got.Topics)
+	require.Equal(t, digest.ActionItems, got.ActionItems)
+
+	for _, it := range outboxItems(t, st) {
+		require.Equal(t, storage.OutboxSent, it.Status)
+		require.Equal(t, 1, it.Attempts)
+	}
+
+	// The same digest (e.g. a re-run of the job) is not delivered again.
+	require.NoError(t, o.SendDigest(ctx, digest))
+	require.NoError(t, o.Dispatch(ctx))
+	require.Len(t, tg.delivered, 1)
+}
+
+func TestOutbox_RetriesFailingChannelWithBackoff(t *testing.T) {
+	o, st, clock := newTestOutbox(t, 5)
+	tg, mail := &flakySender{}, &flakySender{failures: 2}
+	o.Add("telegram", tg)
+	o.Add("email", mail)
+	ctx := context.Background()
+
+	require.NoError(t, o.SendDigest(ctx, sampleDigest()))
+	require.NoError(t, o.Dispatch(ctx))
+	items := outboxItems(t, st)
+	require.Equal(t, storage.OutboxSent, items["telegram"].Status)
+	require.Equal(t, storage.OutboxPending, items["email"].Status)
+	require.Equal(t, "channel unavailable", items["email"].LastError)
+	require.Equal(t, clock.t.Add(time.Minute).Unix(), items["email"].NextAttemptAt)
+
+	// Not due yet
+	require.NoError(t, o.Dispatch(ctx))
+	require.Equal(t, 1, mail.calls)
+
+	clock.advance(time.Minute)
+	require.NoError(t, o.Dispatch(ctx))
+	require.Equal(t, clock.t.Add(2*time.Minute).Unix(), outboxItems(t, st)["email"].NextAttemptAt)
+
+	clock.advance(2 * time.Minute)
+	require.NoError(t, o.Dispatch(ctx))
+	items = outboxItems(t, st)
+	require.Equal(t, storage.OutboxSent, items["email"].Status)
+	require.Equal(t, 3, items["email"].Attempts)
+	require.Len(t, mail.delivered, 1)
+	require.Len(t, tg.delivered, 1, "a delivered channel is not repeated")
+}
+
+func TestOutbox_DeadLetters(t *testing.T) {
+	o, st, clock := newTestOutbox(t, 2)
+	mail := &flakySender{failures: 10}
+	o.Add("email", mail)
+	ctx := context.Background()
+
+	require.NoError(t, o.SendDigest(ctx, sampleDigest()))
+	require.NoError(t, o.Dispatch(ctx))
+	clock.advance(time.Hour)
+	require.NoError(t, o.Dispatch(ctx))
+
+	item := outboxItems(t, st)["email"]
+	require.Equal(t, storage.OutboxDead, item.Status)
+	require.Equal(t, 2, item.Attempts)
+
+	clock.advance(24 * time.Hour)
+	require.NoError(t, o.Dispatch(ctx))
+	require.Equal(t, 2, mail.calls, "dead letters are not retried automatically")
+
+	ok, err := st.RequeueOutbox(ctx, item.ID, clock.t)
+	require.NoError(t, err)
+	require.True(t, ok)
+	mail.failures = 0
+	require.NoError(t, o.Dispatch(ctx))
+	require.Equal(t, storage.OutboxSent, outboxItems(t, st)["email"].Status)
+}
+
+func TestOutbox_UnknownChannelIsDeadLettered(t *testing.T) {
+	o, st, _ := newTestOutbox(t, 5)
+	o.Add("slack", &flakySender{})
+	ctx := context.Background()
+	require.NoError(t, o.SendDigest(ctx, sampleDigest()))
+
+	// After a restart without the channel in DIGEST_DELIVERY
+	restarted := NewOutbox(o.log, st, 5)
+	restarted.now = o.now
+	require.NoError(t, restarted.Dispatch(ctx))
+	item := outboxItems(t, st)["slack"]
+	require.Equal(t, storage.OutboxDead, item.Status)
+	require.Contains(t, item.LastError, `channel "slack" is not configured`)
+}
+
+func TestOutbox_ShutdownDoesNotCountAttempt(t *testing.T) {
+	o, st, _ := newTestOutbox(t, 5)
+	o.Add("telegram", senderFunc(func(ctx context.Context, _ *summarizer.Digest) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	require.NoError(t, o.SendDigest(context.Background(), sampleDigest()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, o.Dispatch(ctx), context.DeadlineExceeded)
+	item := outboxItems(t, st)["telegram"]
+	require.Equal(t, storage.OutboxPending, item.Status)
+	require.Zero(t, item.Attempts)
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	require.Equal(t, time.Minute, outboxBackoff(1))
+	require.Equal(t, 2*time.Minute, outboxBackoff(2))
+	require.Equal(t, 32*time.Minute, outboxBackoff(6))
+	require.Equal(t, time.Hour, outboxBackoff(7))
+	require.Equal(t, time.Hour, outboxBackoff(100))
+}
+
+type senderFunc func(ctx context.Context, digest *summarizer.Digest) error
+
+func (f senderFunc) SendDigest(ctx context.Context, digest *summarizer.Digest) error {
+	return f(ctx, digest)
+}
@@ -0,0 +1,175 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/summarizer"
+	"go.uber.org/zap"
+)
+
+// Block Kit limits.
+const (
+	slackMaxBlocks    = 50   // blocks per message
+	slackSectionLimit = 3000 // characters of a section text
+	slackHeaderLimit  = 150  // characters of a header text
+)
+
+var _ DigestSender = (*SlackSender)(nil)
+
+// SlackSender posts digests to Slack incoming webhooks as Block Kit messages.
+type SlackSender struct {
+	urls  []string
+	first int // number of urls[0] in SLACK_WEBHOOK_URLS, for errors
+	api   jsonAPI
+	log   applog.Logger
+}
+
+// NewSlackSender creates a new instance of SlackSender using injected config and logger.
+func NewSlackSender(logger applog.Logger, cfg *config.Config) *SlackSender {
+	return &SlackSender{
+		urls:  cfg.SlackWebhookURLs,
+		first: 1,
+		api:   newJSONAPI(logger),
+		log:   logger,
+	}
+}
+
+// NewSlackChannels creates a sender per URL in SLACK_WEBHOOK_URLS, named "slack:" +
+// hash of the URL.
+func NewSlackChannels(logger applog.Logger, cfg *config.Config) []Channel {
+	channels := make([]Channel, 0, len(cfg.SlackWebhookURLs))
+	for i, url := range cfg.SlackWebhookURLs {
+		s := NewSlackSender(logger, cfg)
+		s.urls, s.first = []string{url}, i+1
+		channels = append(channels, Channel{Name: urlChannelName("slack", url), Sender: s})
+	}
+	return channels
+}
+
+type slackMessage struct {
+	Text   string       `json:"text"` // notification fallback
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type     string       `json:"type"`
+	Text     *slackText   `json:"text,omitempty"`
+	Elements []*slackText `json:"elements,omitempty"`
+}
+
+type slackText struct {
+	Type  string `json:"type"` // plain_text or mrkdwn
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+// SendDigest implements the DigestSender interface.
+// Every webhook gets the whole digest; a failing webhook does not stop the others.
+func (s *SlackSender) SendDigest(ctx context.Context, digest *summarizer.Digest) error {
+	msgs := slackMessages(digest)
+	var errs []error
+	for i, url := range s.urls {
+		for j, msg := range msgs {
+			if _, err := s.api.do(ctx, http.MethodPost, url, nil, msg); err != nil {
+				errs = append(errs, fmt.Errorf("slack webhook #%d, part %d/%d: %w", s.first+i, j+1, len(msgs), err))
+				break
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	s.log.Info("Digest sent to Slack",
+		zap.Int64("chat_id", digest.Chat.ID),
+		zap.Int("webhooks", len(s.urls)),
+		zap.Int("parts", len(msgs)),
+	)
+	return nil
+}
+
+// slackMessages renders the digest as Block Kit blocks, split into messages of at most slackMaxBlocks.
+func slackMessages(d *summarizer.Digest) []slackMessage {
+	refs := func(ids []int64) string {
+		var parts []string
+		for _, r := range d.Refs(ids) {
+			parts = append(parts, fmt.Sprintf("<%s|%s>", r.URL, r.Label()))
+		}
+		if len(parts) == 0 {
+			return ""
+		}
+		return " (" + strings.Join(parts, ", ") + ")"
+	}
+	sections := func(lines ...string) []slackBlock {
+		var blocks []slackBlock
+		for _, text := range packLines(lines, slackSectionLimit, utf8.RuneCountInString, nil) {
+			blocks = append(blocks, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: text}})
+		}
+		return blocks
+	}
+
+	var blocks []slackBlock
+	if d.Chat.Title != "" {
+		blocks = append(blocks, slackBlock{Type: "header", Text: &slackText{Type: "plain_text", Text: truncateRunes(d.Chat.Title, slackHeaderLimit), Emoji: true}})
+	}
+	if period := d.Period(); period != "" {
+		blocks = append(blocks, slackBlock{Type: "context", Elements: []*slackText{{Type: "mrkdwn", Text: slackEscape(period)}}})
+	}
+	if d.Overview != "" {
+		blocks = append(blocks, sections(slackEscape(d.Overview))...)
+	}
+	if len(d.Topics) > 0 {
+		blocks = append(blocks, slackBlock{Type: "divider"})
+		for _, t := range d.Topics {
+			blocks = append(blocks, sections(fmt.Sprintf("*%s*\n%s%s", slackEscape(t.Title), slackEscape(t.Summary), refs(t.MessageIDs)))...)
+		}
+	}
+	if len(d.ActionItems) > 0 {
+		blocks = append(blocks, slackBlock{Type: "divider"})
+		lines := []string{"*Action items*"}
+		for _, a := range d.ActionItems {
+			line := "• "
+			if a.Owner != "" {
+				line += "_" + slackEscape(a.Owner) + "_: "
+			}
+			lines = append(lines, line+slackEscape(a.Text)+refs(a.MessageIDs))
+		}
+		blocks = append(blocks, sections(lines...)...)
+	}
+	if note := d.ChangesNote(); note != "" {
+		blocks = append(blocks, slackBlock{Type: "context", Elements: []*slackText{{Type: "mrkdwn", Text: "_" + slackEscape(note) + "_"}}})
+	}
+
+	fallback := d.Chat.Title
+	if period := d.Period(); period != "" {
+		fallback = strings.TrimPrefix(fallback+" — "+period, " — ")
+	}
+	fallback = slackEscape(fallback)
+	var msgs []slackMessage
+	for len(blocks) > 0 {
+		n := min(len(blocks), slackMaxBlocks)
+		msgs = append(msgs, slackMessage{Text: fallback, Blocks: blocks[:n]})
+		blocks = blocks[n:]
+	}
+	return msgs
+}
+
+// slackEscape escapes the control characters of Slack mrkdwn.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// truncateRunes shortens s to at most n runes, marking the cut with an ellipsis.
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,185 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/summarizer"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChatAPI records JSON requests and answers them with the queued responses, then 200.
+type fakeChatAPI struct {
+	mu        sync.Mutex
+	responses []func(w http.ResponseWriter)
+	requests  []*http.Request
+	bodies    [][]byte
+}
+
+func (f *fakeChatAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	f.requests = append(f.requests, r)
+	f.bodies = append(f.bodies, body)
+	var respond func(w http.ResponseWriter)
+	if len(f.responses) > 0 {
+		respond, f.responses = f.responses[0], f.responses[1:]
+	}
+	f.mu.Unlock()
+	if respond != nil {
+		respond(w)
+		return
+	}
+	_, _ = io.WriteString(w, "ok")
+}
+
+// decode unmarshals the i-th request body into v.
+func (f *fakeChatAPI) decode(t *testing.T, i int, v any) *http.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	require.Less(t, i, len(f.requests))
+	require.NoError(t, json.Unmarshal(f.bodies[i], v))
+	return f.requests[i]
+}
+
+func (f *fakeChatAPI) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.requests)
+}
+
+func testJSONAPI(t *testing.T) (jsonAPI, *[]time.Duration) {
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+	api := newJSONAPI(logger)
+	var sleeps []time.Duration
+	api.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return ctx.Err()
+	}
+	return api, &sleeps
+}
+
+func TestSlackSender_BlockKit(t *testing.T) {
+	hook := &fakeChatAPI{}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	defer cleanup()
+	s := NewSlackSender(logger, &config.Config{SlackWebhookURLs: []string{srv.URL}})
+	s.api, _ = testJSONAPI(t)
+
+	require.NoError(t, s.SendDigest(context.Background(), sampleDigest()))
+
+	var msg slackMessage
+	hook.decode(t, 0, &msg)
+	require.Equal(t, "Dev &lt;team&gt; — 17.10.2026 00:00 – 18.10.2026 00:00", msg.Text)
+	types := make([]string, len(msg.Blocks))
+	for i, b := range msg.Blocks {
+		types[i] = b.Type
+	}
+	require.Equal(t, []string{"header", "context", "section", "divider", "section", "divider", "section"}, types)
+	require.Equal(t, "Dev <team>", msg.Blocks[0].Text.Text, "header is plain text")
+	require.Equal(t, "Release &amp; hotfix", msg.Blocks[2].Text.Text)
+	require.Equal(t, "*Release*\nShipped v2 (<https://t.me/c/1234567890/10|#10>, <https://t.me/c/1234567890/12|#12>)", msg.Blocks[4].Text.Text)
+	require.Equal(t, "*Action items*\n• _Anna_: Write the changelog (<https://t.me/c/1234567890/15|#15>)", msg.Blocks[6].Text.Text)
+}
+
+func TestSlackMessages_SplitsBlocks(t *testing.T) {
+	d := sampleDigest()
+	d.Topics = nil
+	for i := range 60 {
+		d.Topics = append(d.Topics, summarizer.Topic{Title: fmt.Sprintf("Topic %d", i), Summary: "text"})
+	}
+	d.ActionItems = nil
+
+	msgs := slackMessages(d)
+	require.Len(t, msgs, 2)
+	require.Len(t, msgs[0].Blocks, slackMaxBlocks)
+	require.Len(t, msgs[1].Blocks, 3+1+60-slackMaxBlocks) // header, context, overview, divider, topics
+	for _, m := range msgs {
+		require.NotEmpty(t, m.Text)
+	}
+}
+
+func TestSlackMessages_LongLineKeepsLinks(t *testing.T) {
+	d := sampleDigest()
+	d.Topics[0].Summary = strings.Repeat("word ", 500)
+	d.Topics[0].MessageIDs = nil
+	for id := int64(1); id <= 100; id++ {
+		d.Topics[0].MessageIDs = append(d.Topics[0].MessageIDs, id)
+		d.EditedIDs = append(d.EditedIDs, id)
+	}
+
+	var text string
+	for _, m := range slackMessages(d) {
+		for _, b := range m.Blocks {
+			if b.Type != "section" {
+				continue
+			}
+			require.LessOrEqual(t, utf8.RuneCountInString(b.Text.Text), slackSectionLimit)
+			require.Equal(t, strings.Count(b.Text.Text, "<"), strings.Count(b.Text.Text, ">"), "a link is cut in two")
+			text += b.Text.Text + "\n"
+		}
+	}
+	for _, r := range d.Refs(d.Topics[0].MessageIDs) {
+		require.Contains(t, text, fmt.Sprintf("<%s|%s>", r.URL, r.Label()))
+	}
+}
+
+func TestJSONAPI_RetriesRateLimit(t *testing.T) {
+	hook := &fakeChatAPI{responses: []func(w http.ResponseWriter){
+		func(w http.ResponseWriter) {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+		},
+		func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = io.WriteString(w, `{"errcode":"M_LIMIT_EXCEEDED","retry_after_ms":1500}`)
+		},
+	}}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+	api, sleeps := testJSONAPI(t)
+
+	_, err := api.do(context.Background(), http.MethodPost, srv.URL, nil, map[string]string{"a": "b"})
+	require.NoError(t, err)
+	require.Equal(t, 3, hook.count())
+	require.Equal(t, []time.Duration{2 * time.Second, 1500 * time.Millisecond}, *sleeps)
+}
+
+func TestJSONAPI_ErrorHidesURL(t *testing.T) {
+	hook := &fakeChatAPI{responses: []func(w http.ResponseWriter){
+		func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, "no_service")
+		},
+	}}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+	api, _ := testJSONAPI(t)
+
+	secretURL := srv.URL + "/services/T000/B000/secret"
+	_, err := api.do(context.Background(), http.MethodPost, secretURL, nil, struct{}{})
+	var httpErr *HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	require.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+	require.Equal(t, "no_service", httpErr.Body)
+
+	_, err = api.do(context.Background(), http.MethodPost, "http://127.0.0.1:1/services/secret", nil, struct{}{})
+	require.Error(t, err)
+	require.False(t, strings.Contains(err.Error(), "secret"), err.Error())
+}
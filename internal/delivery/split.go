@@ -0,0 +1,136 @@
+package delivery
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// utf16Len measures text the way Telegram limits do.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// packLines joins rendered lines into messages of at most limit (as measured by
+// length), breaking only between lines. An empty line is a paragraph break and is
+// dropped at message boundaries. A single line over the limit is passed through
+// flatten, if set, and cut on spaces, never inside an HTML character reference or
+// a <...> span.
+func packLines(lines []string, limit int, length func(string) int, flatten func(string) string) []string {
+	var msgs []string
+	var cur []string
+	curLen := 0
+	flush := func() {
+		for len(cur) > 0 && cur[len(cur)-1] == "" {
+			cur = cur[:len(cur)-1]
+		}
+		if len(cur) > 0 {
+			msgs = append(msgs, strings.Join(cur, "\n"))
+		}
+		cur, curLen = nil, 0
+	}
+	add := func(line string) {
+		n := length(line)
+		if len(cur) > 0 {
+			n++ // newline
+		}
+		if curLen+n > limit {
+			flush()
+			if line == "" {
+				return
+			}
+			n = length(line)
+		}
+		if len(cur) == 0 && line == "" {
+			return
+		}
+		cur = append(cur, line)
+		curLen += n
+	}
+	for _, line := range lines {
+		if length(line) <= limit {
+			add(line)
+			continue
+		}
+		if flatten != nil {
+			line = flatten(line)
+		}
+		for _, piece := range cutLine(line, limit, length) {
+			add(piece)
+		}
+	}
+	flush()
+	return msgs
+}
+
+// stripHTMLTags flattens a Telegram HTML line: markup cannot span messages.
+func stripHTMLTags(line string) string {
+	return htmlTag.ReplaceAllString(line, "")
+}
+
+// cutLine splits an overlong line into pieces of at most limit. A <...> span, such
+// as a Slack link or mention, is kept whole unless it alone exceeds the limit.
+func cutLine(line string, limit int, length func(string) int) []string {
+	var pieces []string
+	for length(line) > limit {
+		// Largest prefix that fits, on a rune boundary; length is additive over runes.
+		end, n := 0, 0
+		for i, r := range line {
+			n += length(string(r))
+			if n > limit {
+				break
+			}
+			end = i + utf8.RuneLen(r)
+		}
+		prefix := line[:end]
+		cut := end
+		// A space right after the prefix is a break too: the prefix then fits whole.
+		if sp := lastBreak(line[:min(end+1, len(line))]); sp > 0 {
+			cut = sp
+		} else if amp := strings.LastIndexByte(prefix, '&'); amp > strings.LastIndexByte(prefix, ';') {
+			cut = amp // do not split "&amp;"
+		}
+		if lt := strings.LastIndexByte(line[:cut], '<'); lt > 0 && lt > strings.LastIndexByte(line[:cut], '>') {
+			cut = lt
+		}
+		if cut == 0 {
+			_, size := utf8.DecodeRuneInString(line)
+			cut = size
+		}
+		pieces = append(pieces, strings.TrimRight(line[:cut], " "))
+		line = strings.TrimLeft(line[cut:], " ")
+	}
+	if line != "" {
+		pieces = append(pieces, line)
+	}
+	return pieces
+}
+
+// lastBreak returns the index of the last space in s outside a <...> span, or of
+// the last space at all when every one is inside a span.
+func lastBreak(s string) int {
+	sp, inSpan := -1, false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '<':
+			inSpan = true
+		case '>':
+			inSpan = false
+		case ' ':
+			if !inSpan {
+				sp = i
+			}
+		}
+	}
+	if sp < 0 {
+		return strings.LastIndexByte(s, ' ')
+	}
+	return sp
+}
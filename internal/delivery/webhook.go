@@ -0,0 +1,265 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/summarizer"
+	"go.uber.org/zap"
+)
+
+const (
+	// WebhookSignatureHeader carries "sha256=" + hex HMAC-SHA256 of the raw body.
+	WebhookSignatureHeader = "X-Signature-256"
+	// WebhookDeliveryHeader carries the payload ID, identical across retries:
+	// receivers deduplicate deliveries by it.
+	WebhookDeliveryHeader = "X-Digest-Delivery"
+
+	webhookMaxAttempts = 5
+	webhookBaseBackoff = time.Second
+	webhookMaxBackoff  = 30 * time.Second
+)
+
+// WebhookStatusError is a non-2xx webhook response.
+type WebhookStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *WebhookStatusError) Error() string {
+	return fmt.Sprintf("webhook returned status %d: %s", e.StatusCode, e.Body)
+}
+
+var _ DigestSender = (*WebhookSender)(nil)
+
+// WebhookSender POSTs digests as signed JSON to configured URLs, so any internal
+// tool can consume them. Server errors (5xx) and network failures are retried with
+// exponential backoff; other statuses fail immediately.
+type WebhookSender struct {
+	urls       []string
+	first      int // number of urls[0] in WEBHOOK_URLS, for errors and logs
+	secret     []byte
+	httpClient *http.Client
+	log        applog.Logger
+	sleep      func(ctx context.Context, d time.Duration) error
+	now        func() time.Time
+}
+
+// NewWebhookSender creates a new instance of WebhookSender using injected config and logger.
+func NewWebhookSender(logger applog.Logger, cfg *config.Config) *WebhookSender {
+	return &WebhookSender{
+		urls:       cfg.WebhookURLs,
+		first:      1,
+		secret:     []byte(cfg.WebhookSecret),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		log:        logger,
+		sleep:      sleepContext,
+		now:        time.Now,
+	}
+}
+
+// NewWebhookChannels creates a sender per URL in WEBHOOK_URLS, named "webhook:" +
+// hash of the URL.
+func NewWebhookChannels(logger applog.Logger, cfg *config.Config) []Channel {
+	channels := make([]Channel, 0, len(cfg.WebhookURLs))
+	for i, url := range cfg.WebhookURLs {
+		s := NewWebhookSender(logger, cfg)
+		s.urls, s.first = []string{url}, i+1
+		channels = append(channels, Channel{Name: urlChannelName("webhook", url), Sender: s})
+	}
+	return channels
+}
+
+// WebhookPayload is the JSON body of a webhook delivery.
+type WebhookPayload struct {
+	ID          string              `json:"id"`
+	GeneratedAt time.Time           `json:"generated_at"`
+	Chat        WebhookChat         `json:"chat"`
+	Window      WebhookWindow       `json:"window"`
+	Overview    string              `json:"overview"`
+	Topics      []WebhookTopic      `json:"topics"`
+	ActionItems []WebhookActionItem `json:"action_items"`
+	Text        string              `json:"text"`                       // Markdown-ish rendering, see Digest.Text
+	Deleted     int                 `json:"deleted_messages,omitempty"` // window messages deleted after posting
+}
+
+type WebhookChat struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	Type  string `json:"type"`
+}
+
+type WebhookWindow struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Timezone string    `json:"timezone"`
+}
+
+type WebhookTopic struct {
+	Title    string           `json:"title"`
+	Summary  string           `json:"summary"`
+	Messages []WebhookMessage `json:"messages"`
+}
+
+type WebhookActionItem struct {
+	Text     string           `json:"text"`
+	Owner    string           `json:"owner,omitempty"`
+	Messages []WebhookMessage `json:"messages"`
+}
+
+// WebhookMessage references a source message; URL is empty for basic groups.
+type WebhookMessage struct {
+	ID     int64  `json:"id"`
+	URL    string `json:"url,omitempty"`
+	Edited bool   `json:"edited,omitempty"` // edited after posting
+}
+
+// SendDigest implements the DigestSender interface.
+// Every URL gets the same payload; a failing URL does not stop the others, and
+// its retries are bounded by its share of the ctx deadline, so the URLs after it
+// still get a chance within the same send.
+// URLs often embed tokens, so errors and logs refer to them by number.
+func (s *WebhookSender) SendDigest(ctx context.Context, digest *summarizer.Digest) error {
+	payload := s.payload(digest)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i, url := range s.urls {
+		n := s.first + i
+		urlCtx, cancel := deadlineShare(ctx, len(s.urls)-i)
+		err := s.post(urlCtx, n, url, payload.ID, body)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("webhook #%d: %w", n, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	s.log.Info("Digest sent to webhooks", zap.Int64("chat_id", digest.Chat.ID), zap.Int("urls", len(s.urls)))
+	return nil
+}
+
+// deadlineShare splits the time left until the ctx deadline evenly between the
+// given number of remaining URLs.
+func deadlineShare(ctx context.Context, remaining int) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Until(deadline)/time.Duration(remaining))
+}
+
+// post delivers the body to the n-th URL with retries. It gives up early rather
+// than back off past the ctx deadline.
+func (s *WebhookSender) post(ctx context.Context, n int, url, id string, body []byte) error {
+	backoff := webhookBaseBackoff
+	for attempt := 1; ; attempt++ {
+		err := s.postOnce(ctx, url, id, body)
+		if err == nil || !retryableWebhookError(err) || attempt >= webhookMaxAttempts || ctx.Err() != nil {
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= backoff {
+			return err
+		}
+		s.log.Warn("Webhook delivery failed, retrying",
+			zap.Int("webhook", n),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+		if err := s.sleep(ctx, backoff); err != nil {
+			return err
+		}
+		backoff = min(backoff*2, webhookMaxBackoff)
+	}
+}
+
+func (s *WebhookSender) postOnce(ctx context.Context, url, id string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return stripURL(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tg-summary")
+	req.Header.Set(WebhookDeliveryHeader, id)
+	if len(s.secret) > 0 {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook(s.secret, body))
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return stripURL(err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &WebhookStatusError{StatusCode: resp.StatusCode, Body: string(raw)}
+	}
+	return nil
+}
+
+// retryableWebhookError: server errors and transport failures are retried, client errors are not.
+func retryableWebhookError(err error) bool {
+	var statusErr *WebhookStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	return true
+}
+
+// SignWebhook returns the signature header value for body: "sha256=" + hex HMAC-SHA256.
+// Receivers should recompute it over the raw body and compare with hmac.Equal.
+func SignWebhook(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *WebhookSender) payload(d *summarizer.Digest) WebhookPayload {
+	messages := func(ids []int64) []WebhookMessage {
+		res := make([]WebhookMessage, 0, len(ids))
+		for _, id := range ids {
+			res = append(res, WebhookMessage{ID: id, URL: d.Chat.MessageLink(id), Edited: slices.Contains(d.EditedIDs, id)})
+		}
+		return res
+	}
+	p := WebhookPayload{
+		ID:          deliveryID(d.Chat),
+		GeneratedAt: s.now().UTC(),
+		Chat:        WebhookChat{ID: d.Chat.ID, Title: d.Chat.Title, Type: d.Chat.Type},
+		Window:      WebhookWindow{From: d.Chat.From, To: d.Chat.To, Timezone: d.Chat.Location().String()},
+		Overview:    d.Overview,
+		Topics:      []WebhookTopic{},
+		ActionItems: []WebhookActionItem{},
+		Text:        d.Text(),
+		Deleted:     d.DeletedCount,
+	}
+	for _, t := range d.Topics {
+		p.Topics = append(p.Topics, WebhookTopic{Title: t.Title, Summary: t.Summary, Messages: messages(t.MessageIDs)})
+	}
+	for _, a := range d.ActionItems {
+		p.ActionItems = append(p.ActionItems, WebhookActionItem{Text: a.Text, Owner: a.Owner, Messages: messages(a.MessageIDs)})
+	}
+	return p
+}
+
+// deliveryID derives the payload ID from the digest key, so every attempt to deliver
+// the same digest, including outbox retries after a restart, carries the same ID.
+func deliveryID(chat summarizer.ChatInfo) string {
+	sum := sha256.Sum256([]byte(digestKey(chat)))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,231 @@
+package delivery
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// fakeWebhook answers with the given statuses in order, then 200.
+type fakeWebhook struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []webhookRequest
+}
+
+func (f *fakeWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	f.requests = append(f.requests, webhookRequest{header: r.Header.Clone(), body: body})
+	status := http.StatusOK
+	if len(f.statuses) > 0 {
+		status, f.statuses = f.statuses[0], f.statuses[1:]
+	}
+	f.mu.Unlock()
+	w.WriteHeader(status)
+}
+
+func (f *fakeWebhook) received() []webhookRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]webhookRequest(nil), f.requests...)
+}
+
+func newTestWebhookSender(t *testing.T, secret string, urls ...string) (*WebhookSender, *[]time.Duration) {
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+	s := NewWebhookSender(logger, &config.Config{WebhookURLs: urls, WebhookSecret: secret})
+	var sleeps []time.Duration
+	s.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return ctx.Err()
+	}
+	s.now = func() time.Time { return time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC) }
+	return s, &sleeps
+}
+
+func TestWebhookSender_PayloadAndSignature(t *testing.T) {
+	hook := &fakeWebhook{}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+	s, _ := newTestWebhookSender(t, "s3cret", srv.URL)
+
+	d := sampleDigest()
+	d.EditedIDs = []int64{12}
+	d.DeletedCount = 1
+	require.NoError(t, s.SendDigest(context.Background(), d))
+
+	reqs := hook.received()
+	require.Len(t, reqs, 1)
+	req := reqs[0]
+	require.Equal(t, "application/json", req.header.Get("Content-Type"))
+	sig := req.header.Get(WebhookSignatureHeader)
+	require.True(t, hmac.Equal([]byte(SignWebhook([]byte("s3cret"), req.body)), []byte(sig)))
+
+	var p WebhookPayload
+	require.NoError(t, json.Unmarshal(req.body, &p))
+	require.NotEmpty(t, p.ID)
+	require.Equal(t, p.ID, req.header.Get(WebhookDeliveryHeader))
+	require.Equal(t, WebhookChat{ID: 1234567890, Title: "Dev <team>", Type: "supergroup"}, p.Chat)
+	require.Equal(t, "UTC", p.Window.Timezone)
+	require.True(t, p.Window.From.Equal(time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)))
+	require.True(t, p.Window.To.Equal(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)))
+	require.Equal(t, "Release & hotfix", p.Overview)
+	require.NotEmpty(t, p.Topics)
+	require.Equal(t, "Release", p.Topics[0].Title)
+	require.Equal(t, []WebhookMessage{
+		{ID: 10, URL: "https://t.me/c/1234567890/10"},
+		{ID: 12, URL: "https://t.me/c/1234567890/12", Edited: true},
+	}, p.Topics[0].Messages)
+	require.Equal(t, 1, p.Deleted)
+	require.NotEmpty(t, p.ActionItems)
+	require.Equal(t, "Anna", p.ActionItems[0].Owner)
+	require.NotEmpty(t, p.Text)
+}
+
+func TestWebhookSender_NoSecretNoSignature(t *testing.T) {
+	hook := &fakeWebhook{}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+	s, _ := newTestWebhookSender(t, "", srv.URL)
+
+	require.NoError(t, s.SendDigest(context.Background(), sampleDigest()))
+	require.Empty(t, hook.received()[0].header.Get(WebhookSignatureHeader))
+}
+
+func TestWebhookSender_RetriesServerErrorsWithBackoff(t *testing.T) {
+	hook := &fakeWebhook{statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusInternalServerError}}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+	s, sleeps := newTestWebhookSender(t, "k", srv.URL)
+
+	require.NoError(t, s.SendDigest(context.Background(), sampleDigest()))
+
+	reqs := hook.received()
+	require.Len(t, reqs, 4)
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, *sleeps)
+	for _, r := range reqs[1:] {
+		require.Equal(t, reqs[0].body, r.body, "retries resend the same payload")
+		require.Equal(t, reqs[0].header.Get(WebhookDeliveryHeader), r.header.Get(WebhookDeliveryHeader))
+	}
+}
+
+func TestWebhookSender_GivesUpAfterMaxAttempts(t *testing.T) {
+	hook := &fakeWebhook{statuses: []int{500, 500, 500, 500, 500, 500}}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+	s, sleeps := newTestWebhookSender(t, "k", srv.URL)
+
+	err := s.SendDigest(context.Background(), sampleDigest())
+	var statusErr *WebhookStatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, 500, statusErr.StatusCode)
+	require.Len(t, hook.received(), webhookMaxAttempts)
+	require.Len(t, *sleeps, webhookMaxAttempts-1)
+}
+
+func TestWebhookSender_RetriesStayWithinDeadline(t *testing.T) {
+	bad := &fakeWebhook{statuses: []int{500, 500, 500, 500, 500}}
+	badSrv := httptest.NewServer(bad)
+	defer badSrv.Close()
+	good := &fakeWebhook{}
+	goodSrv := httptest.NewServer(good)
+	defer goodSrv.Close()
+	s, sleeps := newTestWebhookSender(t, "k", badSrv.URL, goodSrv.URL)
+
+	// The first URL gets half of the time left, less than the first backoff.
+	ctx, cancel := context.WithTimeout(context.Background(), 3*webhookBaseBackoff/2)
+	defer cancel()
+	err := s.SendDigest(ctx, sampleDigest())
+	require.EqualError(t, err, "webhook #1: webhook returned status 500: ")
+	require.Len(t, bad.received(), 1, "no retry that would back off past the deadline")
+	require.Empty(t, *sleeps)
+	require.Len(t, good.received(), 1, "the next URL still gets the digest")
+}
+
+func TestWebhookChannels_OutboxRetriesOnlyFailedURL(t *testing.T) {
+	good := &fakeWebhook{}
+	goodSrv := httptest.NewServer(good)
+	defer goodSrv.Close()
+	// Fails every in-sender attempt of the first outbox delivery, then accepts.
+	bad := &fakeWebhook{statuses: []int{500, 500, 500, 500, 500}}
+	badSrv := httptest.NewServer(bad)
+	defer badSrv.Close()
+
+	o, st, clock := newTestOutbox(t, 5)
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	defer cleanup()
+	channels := NewWebhookChannels(logger, &config.Config{WebhookURLs: []string{goodSrv.URL, badSrv.URL}, WebhookSecret: "k"})
+	require.Len(t, channels, 2)
+	require.NotEqual(t, channels[0].Name, channels[1].Name)
+	for _, ch := range channels {
+		ch.Sender.(*WebhookSender).sleep = func(ctx context.Context, d time.Duration) error { return nil }
+		o.Add(ch.Name, ch.Sender)
+	}
+	ctx := context.Background()
+
+	require.NoError(t, o.SendDigest(ctx, sampleDigest()))
+	require.NoError(t, o.Dispatch(ctx))
+	items := outboxItems(t, st)
+	require.Equal(t, storage.OutboxSent, items[channels[0].Name].Status)
+	require.Equal(t, storage.OutboxPending, items[channels[1].Name].Status)
+	require.Equal(t, "webhook #2: webhook returned status 500: ", items[channels[1].Name].LastError)
+
+	clock.advance(time.Minute)
+	require.NoError(t, o.Dispatch(ctx))
+	require.Equal(t, storage.OutboxSent, outboxItems(t, st)[channels[1].Name].Status)
+
+	require.Len(t, good.received(), 1, "a URL that accepted the digest is not posted again")
+	reqs := bad.received()
+	require.Len(t, reqs, 6)
+	id := good.received()[0].header.Get(WebhookDeliveryHeader)
+	require.NotEmpty(t, id)
+	for _, r := range reqs {
+		require.Equal(t, id, r.header.Get(WebhookDeliveryHeader), "the delivery ID is stable across outbox attempts")
+	}
+}
+
+func TestWebhookSender_ErrorsHideURL(t *testing.T) {
+	srv := httptest.NewServer(&fakeWebhook{})
+	srv.Close() // connection refused
+	s, _ := newTestWebhookSender(t, "k", srv.URL+"/hooks/s3cret-token")
+
+	err := s.SendDigest(context.Background(), sampleDigest())
+	require.ErrorContains(t, err, "webhook #1: ")
+	require.NotContains(t, err.Error(), "s3cret-token")
+}
+
+func TestWebhookSender_ClientErrorNotRetried(t *testing.T) {
+	bad := &fakeWebhook{statuses: []int{http.StatusUnauthorized}}
+	badSrv := httptest.NewServer(bad)
+	defer badSrv.Close()
+	good := &fakeWebhook{}
+	goodSrv := httptest.NewServer(good)
+	defer goodSrv.Close()
+	s, sleeps := newTestWebhookSender(t, "k", badSrv.URL, goodSrv.URL)
+
+	err := s.SendDigest(context.Background(), sampleDigest())
+	require.ErrorContains(t, err, "webhook #1: webhook returned status 401")
+	require.NotContains(t, err.Error(), badSrv.URL, "URLs may embed tokens")
+	require.Len(t, bad.received(), 1)
+	require.Empty(t, *sleeps)
+	require.Len(t, good.received(), 1, "a failing URL does not stop the others")
+}
@@ -0,0 +1,256 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/azalio/tg-summary/internal/collector"
+	"github.com/azalio/tg-summary/internal/config"
+	"github.com/azalio/tg-summary/internal/delivery"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/storage"
+	"github.com/azalio/tg-summary/internal/summarizer"
+	"github.com/azalio/tg-summary/internal/telegram"
+	"go.uber.org/zap"
+)
+
+// sendTimeout bounds the delivery of one digest.
+const sendTimeout = 2 * time.Minute
+
+// Pipeline is the daily digest job: collect new messages, summarize the window
+// from storage and deliver the digest, chat by chat.
+type Pipeline struct {
+	collector   *collector.Collector
+	store       storage.Storage
+	summarizer  summarizer.Summarizer
+	sender      delivery.DigestSender
+	opts        summarizer.Options
+	location    func(chatID int64) *time.Location
+	backend     string // configured LLM_BACKEND, recorded for runs that failed
+	noteChanges bool   // DIGEST_NOTE_CHANGES
+	log         applog.Logger
+	now         func() time.Time
+}
+
+// NewPipeline creates a new Pipeline.
+func NewPipeline(
+	logger applog.Logger,
+	cfg *config.Config,
+	coll *collector.Collector,
+	store storage.Storage,
+	sum summarizer.Summarizer,
+	sender delivery.DigestSender,
+) *Pipeline {
+	return &Pipeline{
+		collector:   coll,
+		store:       store,
+		summarizer:  sum,
+		sender:      sender,
+		opts:        summarizer.Options{Language: cfg.DigestLanguage},
+		location:    cfg.LocationFor,
+		backend:     cfg.LLMBackend,
+		noteChanges: cfg.DigestNoteChanges,
+		log:         logger,
+		now:         time.Now,
+	}
+}
+
+// DigestWindow returns the previous calendar day of now in loc as [from, to).
+// Bounds are built from local dates, so days around DST switches last 23 or 25 hours.
+func DigestWindow(now time.Time, loc *time.Location) (from, to time.Time) {
+	y, m, d := now.In(loc).Date()
+	to = time.Date(y, m, d, 0, 0, 0, 0, loc)
+	from = time.Date(y, m, d-1, 0, 0, 0, 0, loc)
+	return from, to
+}
+
+// Run produces and delivers digests for the given chats, each covering the day
+// before at in the chat's timezone. at is the planned run time, so a run caught up
+// after downtime summarizes the day it was meant for.
+// A failing chat does not stop the others; all errors are returned joined.
+func (p *Pipeline) Run(ctx context.Context, chats []telegram.GroupInfo, at time.Time) error {
+	var errs []error
+	for _, res := range p.collector.Collect(ctx, chats) {
+		if res.Err != nil {
+			// Stored history is still summarized: a partial digest beats none.
+			errs = append(errs, fmt.Errorf("collect chat %d: %w", res.ChatID, res.Err))
+		}
+	}
+	for _, chat := range chats {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		from, to := DigestWindow(at, p.location(chat.ChatID))
+		if err := p.runChat(ctx, chat, from, to); err != nil {
+			p.log.Error("Digest failed", zap.Int64("chat_id", chat.ChatID), zap.Error(err))
+			errs = append(errs, fmt.Errorf("digest chat %d: %w", chat.ChatID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Pipeline) runChat(ctx context.Context, chat telegram.GroupInfo, from, to time.Time) error {
+	msgs, err := p.loadMessages(ctx, chat.ChatID, from, to)
+	if err != nil {
+		return fmt.Errorf("load messages: %w", err)
+	}
+	info := summarizer.ChatInfo{
+		ID:    chat.ChatID,
+		Title: chat.Title,
+		Type:  string(chat.Type),
+		From:  from,
+		To:    to,
+	}
+	started := p.now()
+	digest, err := p.summarizer.Summarize(ctx, info, msgs, p.opts)
+	if err == nil && p.noteChanges && !digest.Empty() {
+		p.markChanges(ctx, info, digest, msgs)
+	}
+	p.recordRun(ctx, info, len(msgs), started, digest, err)
+	if err != nil {
+		return fmt.Errorf("summarize: %w", err)
+	}
+	if digest.Empty() {
+		p.log.Info("No messages for digest", zap.Int64("chat_id", chat.ChatID))
+		return nil
+	}
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	if err := p.sender.SendDigest(sendCtx, digest); err != nil {
+		return fmt.Errorf("send digest: %w", err)
+	}
+	p.log.Info("Digest handed over for delivery", zap.Int64("chat_id", chat.ChatID), zap.Int("messages", len(msgs)))
+	return nil
+}
+
+// markChanges records on the digest which source messages were edited and how
+// many messages of the window were deleted, so that renderers can note it.
+// The deleted count is best effort: a storage failure only loses the note.
+func (p *Pipeline) markChanges(ctx context.Context, info summarizer.ChatInfo, digest *summarizer.Digest, msgs []telegram.Message) {
+	for _, m := range msgs {
+		if m.EditedAt != 0 {
+			digest.EditedIDs = append(digest.EditedIDs, m.ID)
+		}
+	}
+	deleted, err := p.store.CountDeletedMessages(ctx, info.ID, info.From, info.To)
+	if err != nil {
+		p.log.Warn("Failed to count deleted messages", zap.Int64("chat_id", info.ID), zap.Error(err))
+		return
+	}
+	digest.DeletedCount = deleted
+}
+
+// recordRun stores the summarization run and its digest for history and cost
+// tracking. It is best effort: a storage failure is logged and delivery goes on.
+func (p *Pipeline) recordRun(ctx context.Context, info summarizer.ChatInfo, messages int, started time.Time, digest *summarizer.Digest, sumErr error) {
+	run := &storage.SummaryRun{
+		ChatID:       info.ID,
+		WindowStart:  info.From.Unix(),
+		WindowEnd:    info.To.Unix(),
+		Backend:      p.backend,
+		MessageCount: messages,
+		LatencyMS:    p.now().Sub(started).Milliseconds(),
+		Status:       storage.SummaryRunOK,
+		StartedAt:    started.Unix(),
+	}
+	var record *storage.Digest
+	switch {
+	case sumErr != nil:
+		run.Status = storage.SummaryRunFailed
+		run.Error = sumErr.Error()
+	case digest.Empty():
+		run.Status = storage.SummaryRunEmpty
+	}
+	if digest != nil {
+		run.Backend = digest.Source.Backend
+		run.Model = digest.Source.Model
+		run.PromptVersion = digest.Source.PromptVersion
+		run.PromptTokens = digest.Usage.PromptTokens
+		run.CompletionTokens = digest.Usage.CompletionTokens
+	}
+	if sumErr == nil && !digest.Empty() {
+		content, err := json.Marshal(digest)
+		if err != nil {
+			p.log.Error("Failed to encode digest for history", zap.Int64("chat_id", info.ID), zap.Error(err))
+			return
+		}
+		record = &storage.Digest{
+			ChatID:      info.ID,
+			WindowStart: run.WindowStart,
+			WindowEnd:   run.WindowEnd,
+			Timezone:    info.Location().String(),
+			Text:        digest.Text(),
+			Content:     string(content),
+			CreatedAt:   p.now().Unix(),
+		}
+	}
+	// Recorded even when the run was cancelled by shutdown.
+	if err := p.store.SaveSummaryRun(context.WithoutCancel(ctx), run, record); err != nil {
+		p.log.Error("Failed to save summary run", zap.Int64("chat_id", info.ID), zap.Error(err))
+	}
+}
+
+// loadMessages reads stored messages with from <= timestamp < to.
+func (p *Pipeline) loadMessages(ctx context.Context, chatID int64, from, to time.Time) ([]telegram.Message, error) {
+	stored, err := p.store.GetMessagesAfter(ctx, chatID, from.Unix()-1)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]telegram.Message, 0, len(stored))
+	for _, m := range stored {
+		if m.Timestamp >= to.Unix() {
+			break // sorted by timestamp
+		}
+		msgs = append(msgs, toTelegramMessage(m))
+	}
+	return msgs, nil
+}
+
+func toTelegramMessage(m storage.Message) telegram.Message {
+	msg := telegram.Message{
+		ID:             m.MessageID,
+		ChatID:         m.ChatID,
+		SenderID:       m.AuthorID,
+		Sender:         m.Author.DisplayName,
+		SenderUsername: m.Author.Username,
+		Text:           m.Text,
+		Timestamp:      m.Timestamp,
+		EditedAt:       m.EditedAt,
+		Views:          m.Views,
+		Forwards:       m.Forwards,
+	}
+	if msg.Sender == "" {
+		msg.Sender = m.Author.Username
+	}
+	if m.ReplyToMessageID != nil {
+		msg.ReplyToID = *m.ReplyToMessageID
+	}
+	if m.Media != nil {
+		msg.Media = toTelegramMedia(m.Media)
+	}
+	for _, r := range m.Reactions {
+		msg.Reactions = append(msg.Reactions, telegram.Reaction{Emoji: r.Emoji, Count: r.Count, UserIDs: r.ReactorIDs})
+	}
+	return msg
+}
+
+func toTelegramMedia(m *storage.MessageMedia) *telegram.Media {
+	media := &telegram.Media{
+		Type:     telegram.MediaType(m.Type),
+		FileName: m.FileName,
+		MIMEType: m.MIMEType,
+		Size:     m.Size,
+		URL:      m.URL,
+		Title:    m.Title,
+	}
+	if m.Type == string(telegram.MediaPoll) {
+		media.Poll = &telegram.Poll{Question: m.PollQuestion, TotalVoters: m.PollVoters, Closed: m.PollClosed}
+		for _, o := range m.PollOptions {
+			media.Poll.Options = append(media.Poll.Options, telegram.PollOption{Text: o.Text, Voters: o.Voters})
+		}
+	}
+	return media
+}
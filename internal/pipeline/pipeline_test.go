@@ -0,0 +1,292 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/azalio/tg-summary/internal/collector"
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/storage"
+	"github.com/azalio/tg-summary/internal/summarizer"
+	"github.com/azalio/tg-summary/internal/telegram"
+	gotdtelegram "github.com/gotd/td/telegram"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTelegramClient serves messages from memory.
+type fakeTelegramClient struct {
+	messages map[int64][]telegram.Message
+}
+
+func (f *fakeTelegramClient) Run(ctx context.Context, fn func(ctx context.Context, api *gotdtelegram.Client) error) error {
+	return fn(ctx, nil)
+}
+
+func (f *fakeTelegramClient) FetchMessages(ctx context.Context, chatID int64, from, to int64) ([]telegram.Message, error) {
+	var res []telegram.Message
+	for _, m := range f.messages[chatID] {
+		if m.Timestamp >= from && (to == 0 || m.Timestamp < to) {
+			res = append(res, m)
+		}
+	}
+	return res, nil
+}
+
+func (f *fakeTelegramClient) ListGroups(ctx context.Context, api *gotdtelegram.Client) ([]telegram.GroupInfo, error) {
+	return nil, nil
+}
+
+func (f *fakeTelegramClient) SendMessage(ctx context.Context, chatID int64, text string) error {
+	return nil
+}
+
+// recordingSummarizer keeps the input of every call and returns one topic per message.
+type recordingSummarizer struct {
+	calls map[int64][]telegram.Message
+	err   error
+}
+
+func (r *recordingSummarizer) Summarize(ctx context.Context, chat summarizer.ChatInfo, messages []telegram.Message, opts summarizer.Options) (*summarizer.Digest, error) {
+	r.calls[chat.ID] = messages
+	if r.err != nil {
+		return nil, r.err
+	}
+	d := &summarizer.Digest{
+		Chat:   chat,
+		Usage:  summarizer.Usage{PromptTokens: 100, CompletionTokens: 20},
+		Source: summarizer.Source{Backend: "test", Model: "m1", PromptVersion: summarizer.PromptVersion},
+	}
+	for _, m := range messages {
+		d.Topics = append(d.Topics, summarizer.Topic{Title: m.Sender, Summary: m.Text})
+	}
+	return d, nil
+}
+
+type recordingSender struct {
+	sent map[int64]string
+	err  error
+}
+
+func (r *recordingSender) SendDigest(ctx context.Context, digest *summarizer.Digest) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.sent[digest.Chat.ID] = digest.Text()
+	return nil
+}
+
+func newTestPipeline(t *testing.T, client telegram.TelegramClient, sender *recordingSender) (*Pipeline, *recordingSummarizer, storage.Storage) {
+	st, err := storage.NewGormStorage(filepath.Join(t.TempDir(), "pipeline.db"))
+	require.NoError(t, err)
+	require.NoError(t, st.Init(context.Background()))
+	t.Cleanup(func() { _ = st.Close() })
+
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+
+	sum := &recordingSummarizer{calls: make(map[int64][]telegram.Message)}
+	coll := collector.NewCollector(logger, client, st, 72*time.Hour, 0)
+	p := NewPipeline(logger, &config.Config{DigestLanguage: "ru", DigestLocation: time.UTC, LLMBackend: "openai"}, coll, st, sum, sender)
+	return p, sum, st
+}
+
+func TestDigestWindow(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		loc      *time.Location
+		from     time.Time
+		duration time.Duration
+	}{
+		{
+			name:     "regular day",
+			now:      time.Date(2026, 6, 10, 9, 0, 0, 0, berlin),
+			loc:      berlin,
+			from:     time.Date(2026, 6, 9, 0, 0, 0, 0, berlin),
+			duration: 24 * time.Hour,
+		},
+		{
+			name:     "spring forward",
+			now:      time.Date(2026, 3, 30, 9, 0, 0, 0, berlin),
+			loc:      berlin,
+			from:     time.Date(2026, 3, 29, 0, 0, 0, 0, berlin),
+			duration: 23 * time.Hour,
+		},
+		{
+			name:     "fall back",
+			now:      time.Date(2026, 10, 26, 9, 0, 0, 0, berlin),
+			loc:      berlin,
+			from:     time.Date(2026, 10, 25, 0, 0, 0, 0, berlin),
+			duration: 25 * time.Hour,
+		},
+		{
+			name:     "local day differs from UTC day",
+			now:      time.Date(2026, 6, 10, 20, 0, 0, 0, time.UTC), // 04:00 on June 11 in Shanghai
+			loc:      shanghai,
+			from:     time.Date(2026, 6, 10, 0, 0, 0, 0, shanghai),
+			duration: 24 * time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := DigestWindow(tt.now, tt.loc)
+			require.True(t, tt.from.Equal(from), "from = %s", from)
+			require.Equal(t, tt.duration, to.Sub(from))
+			require.Equal(t, tt.loc, from.Location())
+		})
+	}
+}
+
+func TestPipeline_Run(t *testing.T) {
+	from, to := DigestWindow(time.Now(), time.UTC)
+	start := from.Unix()
+	client := &fakeTelegramClient{messages: map[int64][]telegram.Message{
+		1: {
+			{ID: 1, ChatID: 1, SenderID: 10, Sender: "Alice", Text: "old news", Timestamp: start - 3600},
+			{ID: 2, ChatID: 1, SenderID: 10, Sender: "Alice", Text: "hello", Timestamp: start + 3600},
+			{ID: 3, ChatID: 1, SenderID: 11, Sender: "Bob", Text: "hi", Timestamp: start + 7200, ReplyToID: 2},
+			{ID: 4, ChatID: 1, SenderID: 11, Sender: "Bob", Text: "today", Timestamp: to.Unix()},
+		},
+	}}
+	sender := &recordingSender{sent: make(map[int64]string)}
+	p, sum, st := newTestPipeline(t, client, sender)
+
+	chats := []telegram.GroupInfo{
+		{ChatID: 1, Title: "Group", Type: telegram.GroupTypeGroup},
+		{ChatID: 2, Title: "Quiet", Type: telegram.GroupTypeSupergroup},
+	}
+	require.NoError(t, p.Run(context.Background(), chats, time.Now()))
+
+	got := sum.calls[1]
+	require.Len(t, got, 2, "only messages of the previous day are summarized")
+	require.Equal(t, "Alice", got[0].Sender)
+	require.Equal(t, "Bob", got[1].Sender)
+	require.Equal(t, int64(2), got[1].ReplyToID)
+
+	require.Contains(t, sender.sent[1], "hello")
+	_, sentQuiet := sender.sent[2]
+	require.False(t, sentQuiet, "empty digests are not delivered")
+
+	ctx := context.Background()
+	runs, err := st.ListSummaryRuns(ctx, 0, time.Time{}, 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	status := map[int64]storage.SummaryRun{}
+	for _, r := range runs {
+		status[r.ChatID] = r
+	}
+	require.Equal(t, storage.SummaryRunOK, status[1].Status)
+	require.Equal(t, "m1", status[1].Model)
+	require.Equal(t, 2, status[1].MessageCount)
+	require.Equal(t, 100, status[1].PromptTokens)
+	require.Equal(t, from.Unix(), status[1].WindowStart)
+	require.Equal(t, to.Unix(), status[1].WindowEnd)
+	require.Equal(t, storage.SummaryRunEmpty, status[2].Status)
+
+	digests, err := st.ListDigests(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, digests, 1)
+	require.Equal(t, sender.sent[1], digests[0].Text)
+	require.Equal(t, status[1].ID, digests[0].SummaryRunID)
+	require.Contains(t, digests[0].Content, `"summary":"hello"`)
+}
+
+func TestPipeline_Run_NotesEditsAndDeletions(t *testing.T) {
+	from, _ := DigestWindow(time.Now(), time.UTC)
+	ts := from.Unix() + 60
+	client := &fakeTelegramClient{messages: map[int64][]telegram.Message{
+		1: {
+			{ID: 1, ChatID: 1, SenderID: 10, Sender: "Alice", Text: "final text", Timestamp: ts, EditedAt: ts + 60},
+			{ID: 2, ChatID: 1, SenderID: 10, Sender: "Alice", Text: "as posted", Timestamp: ts + 120},
+		},
+	}}
+	sender := &recordingSender{sent: make(map[int64]string)}
+	p, sum, st := newTestPipeline(t, client, sender)
+	p.noteChanges = true
+	ctx := context.Background()
+	require.NoError(t, st.SaveMessage(ctx, &storage.Message{ChatID: 1, MessageID: 3, Text: "oops", Timestamp: ts - 30}))
+	require.NoError(t, st.DeleteMessages(ctx, 1, []int64{3}, time.Unix(ts+240, 0)))
+
+	require.NoError(t, p.Run(ctx, []telegram.GroupInfo{{ChatID: 1, Title: "Group", Type: telegram.GroupTypeGroup}}, time.Now()))
+
+	require.Len(t, sum.calls[1], 2, "deleted messages are not summarized")
+	require.Equal(t, "final text", sum.calls[1][0].Text)
+	require.Contains(t, sender.sent[1], "1 message deleted after posting is not included.")
+	digests, err := st.ListDigests(ctx, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, digests, 1)
+	require.Contains(t, digests[0].Content, `"edited_message_ids":[1]`)
+	require.Contains(t, digests[0].Content, `"deleted_messages":1`)
+}
+
+func TestPipeline_Run_RecordsFailedRun(t *testing.T) {
+	from, _ := DigestWindow(time.Now(), time.UTC)
+	client := &fakeTelegramClient{messages: map[int64][]telegram.Message{
+		1: {{ID: 1, ChatID: 1, SenderID: 10, Sender: "Alice", Text: "one", Timestamp: from.Unix() + 60}},
+	}}
+	p, sum, st := newTestPipeline(t, client, &recordingSender{sent: make(map[int64]string)})
+	sum.err = errors.New("llm down")
+
+	err := p.Run(context.Background(), []telegram.GroupInfo{{ChatID: 1, Title: "A", Type: telegram.GroupTypeSupergroup}}, time.Now())
+	require.ErrorIs(t, err, sum.err)
+
+	runs, err := st.ListSummaryRuns(context.Background(), 1, time.Time{}, 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	require.Equal(t, storage.SummaryRunFailed, runs[0].Status)
+	require.Equal(t, "openai", runs[0].Backend, "configured backend is recorded when the run failed")
+	require.Equal(t, "llm down", runs[0].Error)
+	digests, err := st.ListDigests(context.Background(), 1, 10)
+	require.NoError(t, err)
+	require.Empty(t, digests)
+}
+
+func TestPipeline_Run_SendErrorsAreJoined(t *testing.T) {
+	from, _ := DigestWindow(time.Now(), time.UTC)
+	ts := from.Unix() + 60
+	client := &fakeTelegramClient{messages: map[int64][]telegram.Message{
+		1: {{ID: 1, ChatID: 1, SenderID: 10, Sender: "Alice", Text: "one", Timestamp: ts}},
+		2: {{ID: 1, ChatID: 2, SenderID: 10, Sender: "Alice", Text: "two", Timestamp: ts}},
+	}}
+	sendErr := errors.New("network down")
+	sender := &recordingSender{sent: make(map[int64]string), err: sendErr}
+	p, sum, _ := newTestPipeline(t, client, sender)
+
+	err := p.Run(context.Background(), []telegram.GroupInfo{
+		{ChatID: 1, Title: "A", Type: telegram.GroupTypeSupergroup},
+		{ChatID: 2, Title: "B", Type: telegram.GroupTypeSupergroup},
+	}, time.Now())
+	require.ErrorIs(t, err, sendErr)
+	require.Len(t, sum.calls, 2, "a failing chat does not stop the others")
+}
+
+func TestPipeline_Run_CaughtUpRunCoversPlannedDay(t *testing.T) {
+	now := time.Now()
+	dayBefore, _ := DigestWindow(now.AddDate(0, 0, -1), time.UTC)
+	yesterday, _ := DigestWindow(now, time.UTC)
+	client := &fakeTelegramClient{messages: map[int64][]telegram.Message{
+		1: {
+			{ID: 1, ChatID: 1, SenderID: 10, Sender: "Alice", Text: "missed day", Timestamp: dayBefore.Unix() + 60},
+			{ID: 2, ChatID: 1, SenderID: 10, Sender: "Alice", Text: "yesterday", Timestamp: yesterday.Unix() + 60},
+		},
+	}}
+	sender := &recordingSender{sent: make(map[int64]string)}
+	p, sum, _ := newTestPipeline(t, client, sender)
+
+	// The run planned for yesterday morning, executed only now.
+	require.NoError(t, p.Run(context.Background(), []telegram.GroupInfo{
+		{ChatID: 1, Title: "Group", Type: telegram.GroupTypeGroup},
+	}, yesterday.Add(9*time.Hour)))
+	require.Len(t, sum.calls[1], 1)
+	require.Equal(t, "missed day", sum.calls[1][0].Text)
+}
@@ -1,34 +1,280 @@
 package scheduler
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+// Job is a scheduled task. ctx is cancelled when the scheduler stops;
+// at is the planned time of the run, which for a caught-up run lies in the past.
+type Job func(ctx context.Context, at time.Time) error
 
 // Scheduler defines the interface for scheduling tasks.
 type Scheduler interface {
+	// AddJob registers a named job with a cron expression: 5 fields
+	// (minute hour dom month dow), 6 fields with leading seconds, or a
+	// descriptor such as @daily or @every 1h.
+	AddJob(name, spec string, job Job, opts ...JobOption) error
 	Start() error
 	Stop() error
 }
 
-// CronScheduler is the production implementation using a cron library.
+// JobStore persists the planned time of the last successful run of each job.
+// LastJobRun returns the zero time for a job that has never run.
+type JobStore interface {
+	LastJobRun(ctx context.Context, name string) (time.Time, error)
+	SaveJobRun(ctx context.Context, name string, at time.Time) error
+}
+
+// CatchUpPolicy decides what happens to runs missed while the service was down.
+type CatchUpPolicy string
+
+const (
+	CatchUpAll    CatchUpPolicy = "all"    // run every missed occurrence, oldest first
+	CatchUpLatest CatchUpPolicy = "latest" // run only the most recent missed occurrence
+	CatchUpSkip   CatchUpPolicy = "skip"   // drop missed runs
+)
+
+// ParseCatchUpPolicy validates a policy name.
+func ParseCatchUpPolicy(s string) (CatchUpPolicy, error) {
+	switch p := CatchUpPolicy(s); p {
+	case CatchUpAll, CatchUpLatest, CatchUpSkip:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown catch-up policy %q (want all, latest or skip)", s)
+}
+
+// maxCatchUpRuns bounds the backlog replayed for one job, e.g. after a long
+// outage of a job scheduled every minute.
+const maxCatchUpRuns = 100
+
+// JobOption configures a job registered with AddJob.
+type JobOption func(*jobOptions)
+
+type jobOptions struct {
+	location *time.Location
+}
+
+// WithLocation evaluates the schedule in loc instead of the process timezone,
+// so "0 9 * * *" fires at 09:00 local time of the recipient, DST included.
+func WithLocation(loc *time.Location) JobOption {
+	return func(o *jobOptions) { o.location = loc }
+}
+
+// ErrDuplicateJob is returned when a job with the same name is already registered.
+var ErrDuplicateJob = errors.New("job already registered")
+
+// cronParser accepts standard 5-field expressions, an optional seconds field and descriptors.
+var cronParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// CronScheduler is the production implementation using robfig/cron.
+// Each run gets its own goroutine; a run is skipped while the previous one
+// of the same job is still in flight. With a JobStore, successful runs are
+// recorded and runs missed while the service was down are caught up on Start.
 type CronScheduler struct {
-	// Add fields needed for cron library, e.g., cron *cron.Cron, jobs []cron.Job
+	cron   *cron.Cron
+	log    applog.Logger
+	store  JobStore
+	policy CatchUpPolicy
+	now    func() time.Time
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	catchUp sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs map[string]*jobEntry
+}
+
+type jobEntry struct {
+	name     string
+	id       cron.EntryID
+	schedule cron.Schedule
+	job      Job
+	running  atomic.Bool
 }
 
 // NewCronScheduler creates a new instance of CronScheduler.
-func NewCronScheduler() *CronScheduler {
-	// TODO: Initialize cron library and add jobs here
-	return &CronScheduler{}
+// store may be nil: then runs are not persisted and nothing is caught up.
+func NewCronScheduler(logger applog.Logger, store JobStore, policy CatchUpPolicy) *CronScheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &CronScheduler{
+		cron:   cron.New(cron.WithParser(cronParser)),
+		log:    logger,
+		store:  store,
+		policy: policy,
+		now:    time.Now,
+		ctx:    ctx,
+		cancel: cancel,
+		jobs:   make(map[string]*jobEntry),
+	}
+}
+
+// AddJob implements the Scheduler interface.
+func (s *CronScheduler) AddJob(name, spec string, job Job, opts ...JobOption) error {
+	var o jobOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateJob, name)
+	}
+	if o.location != nil {
+		if strings.HasPrefix(spec, "TZ=") || strings.HasPrefix(spec, "CRON_TZ=") {
+			return fmt.Errorf("job %s: schedule %q already sets a timezone", name, spec)
+		}
+		spec = "CRON_TZ=" + o.location.String() + " " + spec
+	}
+	schedule, err := cronParser.Parse(spec)
+	if err != nil {
+		return fmt.Errorf("job %s: invalid schedule %q: %w", name, spec, err)
+	}
+	e := &jobEntry{name: name, schedule: schedule, job: job}
+	e.id = s.cron.Schedule(schedule, cron.FuncJob(func() { s.fire(e) }))
+	s.jobs[name] = e
+	s.log.Info("Job registered", zap.String("job", name), zap.String("schedule", spec))
+	return nil
+}
+
+// NextRun returns the next planned run of a job, or false if the job is unknown
+// or the scheduler is not started.
+func (s *CronScheduler) NextRun(name string) (time.Time, bool) {
+	s.mu.Lock()
+	e, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return time.Time{}, false
+	}
+	next := s.cron.Entry(e.id).Next
+	return next, !next.IsZero()
+}
+
+// fire is called by cron; the entry's Prev is the planned time of this run.
+func (s *CronScheduler) fire(e *jobEntry) {
+	at := s.cron.Entry(e.id).Prev
+	if at.IsZero() {
+		at = s.now()
+	}
+	s.run(e, at)
+}
+
+// run executes one run with logging and overlap protection and records it on success.
+// It reports whether the job ran and succeeded.
+func (s *CronScheduler) run(e *jobEntry, at time.Time) bool {
+	if s.ctx.Err() != nil {
+		return false
+	}
+	if !e.running.CompareAndSwap(false, true) {
+		s.log.Warn("Previous run is still in progress, skipping", zap.String("job", e.name), zap.Time("planned", at))
+		return false
+	}
+	defer e.running.Store(false)
+
+	start := time.Now()
+	s.log.Info("Job started", zap.String("job", e.name), zap.Time("planned", at))
+	if err := e.job(s.ctx, at); err != nil {
+		s.log.Error("Job failed", zap.String("job", e.name), zap.Duration("duration", time.Since(start)), zap.Error(err))
+		return false
+	}
+	s.log.Info("Job finished", zap.String("job", e.name), zap.Duration("duration", time.Since(start)))
+	if s.store != nil {
+		if err := s.store.SaveJobRun(s.ctx, e.name, at); err != nil {
+			s.log.Error("Failed to record job run", zap.String("job", e.name), zap.Error(err))
+		}
+	}
+	return true
 }
 
 // Start implements the Scheduler interface.
 func (s *CronScheduler) Start() error {
-	// TODO: Start the cron scheduler
-	fmt.Println("CronScheduler: Start called (not implemented)")
+	if s.ctx.Err() != nil {
+		return errors.New("scheduler already stopped")
+	}
+	if s.store != nil {
+		s.mu.Lock()
+		for _, e := range s.jobs {
+			s.catchUp.Add(1)
+			go s.runCatchUp(e)
+		}
+		s.mu.Unlock()
+	}
+	s.cron.Start()
+	s.log.Info("Scheduler started")
 	return nil
 }
 
-// Stop implements the Scheduler interface.
+// runCatchUp replays runs of e missed since its last recorded run. A job seen for
+// the first time starts counting from now. The backlog is re-read after each pass,
+// so regular runs skipped while catching up are replayed as well.
+func (s *CronScheduler) runCatchUp(e *jobEntry) {
+	defer s.catchUp.Done()
+	last, err := s.store.LastJobRun(s.ctx, e.name)
+	if err != nil {
+		s.log.Error("Failed to read last job run", zap.String("job", e.name), zap.Error(err))
+		return
+	}
+	if last.IsZero() {
+		if err := s.store.SaveJobRun(s.ctx, e.name, s.now()); err != nil {
+			s.log.Error("Failed to record job run", zap.String("job", e.name), zap.Error(err))
+		}
+		return
+	}
+
+	for {
+		missed := missedRuns(e.schedule, last, s.now())
+		if len(missed) == 0 {
+			return
+		}
+		switch s.policy {
+		case CatchUpSkip:
+			s.log.Info("Skipping missed runs", zap.String("job", e.name), zap.Int("missed", len(missed)))
+			return
+		case CatchUpLatest:
+			missed = missed[len(missed)-1:]
+		}
+		for _, at := range missed {
+			s.log.Info("Catching up missed run", zap.String("job", e.name), zap.Time("planned", at))
+			if !s.run(e, at) {
+				return // retried on the next start
+			}
+			last = at
+		}
+	}
+}
+
+// missedRuns lists planned times in (last, now), oldest first, at most maxCatchUpRuns
+// of the most recent ones.
+func missedRuns(schedule cron.Schedule, last, now time.Time) []time.Time {
+	var runs []time.Time
+	for t := schedule.Next(last); !t.IsZero() && t.Before(now); t = schedule.Next(t) {
+		runs = append(runs, t)
+		if len(runs) > maxCatchUpRuns {
+			runs = runs[1:]
+		}
+	}
+	return runs
+}
+
+// Stop implements the Scheduler interface: no new runs are started,
+// in-flight jobs get their context cancelled and are waited for.
 func (s *CronScheduler) Stop() error {
-	// TODO: Stop the cron scheduler
-	fmt.Println("CronScheduler: Stop called (not implemented)")
+	stopped := s.cron.Stop() // done once all running jobs have returned
+	s.cancel()
+	<-stopped.Done()
+	s.catchUp.Wait()
+	s.log.Info("Scheduler stopped")
 	return nil
 }
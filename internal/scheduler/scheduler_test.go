@@ -1,22 +1,37 @@
 package scheduler
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/stretchr/testify/require"
 )
 
 // MockScheduler is a mock implementation of Scheduler for testing
 type MockScheduler struct {
 	// Add fields to control mock behavior if needed
-	Started bool
-	Stopped bool
+	Started    bool
+	Stopped    bool
 	StartError error
 	StopError  error
+	Jobs       map[string]Job
 }
 
 // NewMockScheduler creates a new instance of MockScheduler
 func NewMockScheduler() *MockScheduler {
-	return &MockScheduler{}
+	return &MockScheduler{Jobs: make(map[string]Job)}
+}
+
+// AddJob implements the Scheduler interface for the mock
+func (m *MockScheduler) AddJob(name, spec string, job Job, opts ...JobOption) error {
+	m.Jobs[name] = job
+	return nil
 }
 
 // Start implements the Scheduler interface for the mock
@@ -58,4 +73,203 @@ func TestSchedulerMock(t *testing.T) {
 	if !mockScheduler.Stopped {
 		t.Errorf("Scheduler was not stopped")
 	}
-}
\ No newline at end of file
+}
+
+func newTestScheduler(t *testing.T) *CronScheduler {
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+	return NewCronScheduler(logger, nil, CatchUpSkip)
+}
+
+func TestCronScheduler_Specs(t *testing.T) {
+	s := newTestScheduler(t)
+	noop := func(ctx context.Context, at time.Time) error { return nil }
+
+	require.NoError(t, s.AddJob("five", "0 9 * * 1-5", noop))
+	require.NoError(t, s.AddJob("six", "30 0 9 * * *", noop))
+	require.NoError(t, s.AddJob("daily", "@daily", noop))
+	require.ErrorIs(t, s.AddJob("daily", "@hourly", noop), ErrDuplicateJob)
+	require.Error(t, s.AddJob("bad", "61 * * * *", noop))
+
+	require.NoError(t, s.Start())
+	defer s.Stop()
+	next, ok := s.NextRun("six")
+	require.True(t, ok)
+	require.Equal(t, 30, next.Second())
+	_, ok = s.NextRun("missing")
+	require.False(t, ok)
+}
+
+func TestCronScheduler_WithLocation(t *testing.T) {
+	s := newTestScheduler(t)
+	noop := func(ctx context.Context, at time.Time) error { return nil }
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	require.NoError(t, err)
+
+	require.NoError(t, s.AddJob("berlin", "0 9 * * *", noop, WithLocation(berlin)))
+	require.NoError(t, s.AddJob("shanghai", "0 9 * * *", noop, WithLocation(shanghai)))
+	require.Error(t, s.AddJob("double", "CRON_TZ=UTC 0 9 * * *", noop, WithLocation(berlin)))
+
+	require.NoError(t, s.Start())
+	defer s.Stop()
+	for name, loc := range map[string]*time.Location{"berlin": berlin, "shanghai": shanghai} {
+		next, ok := s.NextRun(name)
+		require.True(t, ok)
+		local := next.In(loc)
+		require.Equal(t, 9, local.Hour(), name)
+		require.Equal(t, 0, local.Minute(), name)
+	}
+}
+
+func TestCronScheduler_RunsAndStopsGracefully(t *testing.T) {
+	s := newTestScheduler(t)
+	var runs atomic.Int32
+	started := make(chan struct{}, 1)
+	var cancelled atomic.Bool
+
+	require.NoError(t, s.AddJob("blocking", "* * * * * *", func(ctx context.Context, at time.Time) error {
+		runs.Add(1)
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+		cancelled.Store(true)
+		return errors.New("interrupted")
+	}))
+	require.NoError(t, s.Start())
+
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		t.Fatal("job did not start")
+	}
+	// The next ticks are skipped while the first run is still in flight.
+	time.Sleep(1200 * time.Millisecond)
+	require.NoError(t, s.Stop())
+	require.True(t, cancelled.Load())
+	require.Equal(t, int32(1), runs.Load())
+	require.Error(t, s.Start())
+}
+
+// memoryJobStore is an in-memory JobStore.
+type memoryJobStore struct {
+	mu   sync.Mutex
+	runs map[string]time.Time
+}
+
+func (m *memoryJobStore) LastJobRun(ctx context.Context, name string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.runs[name], nil
+}
+
+func (m *memoryJobStore) SaveJobRun(ctx context.Context, name string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if at.After(m.runs[name]) {
+		m.runs[name] = at
+	}
+	return nil
+}
+
+func TestCronScheduler_CatchUp(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	// Down since the run of June 7; restarted at 08:00 on June 10, before that day's run.
+	last := time.Date(2026, 6, 7, 9, 0, 0, 0, berlin)
+	now := time.Date(2026, 6, 10, 8, 0, 0, 0, berlin)
+	june := func(day int) time.Time { return time.Date(2026, 6, day, 9, 0, 0, 0, berlin) }
+
+	tests := []struct {
+		policy CatchUpPolicy
+		want   []time.Time
+		mark   time.Time
+	}{
+		{policy: CatchUpAll, want: []time.Time{june(8), june(9)}, mark: june(9)},
+		{policy: CatchUpLatest, want: []time.Time{june(9)}, mark: june(9)},
+		{policy: CatchUpSkip, want: nil, mark: last},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			logger, cleanup, err := applog.NewLogger()
+			require.NoError(t, err)
+			t.Cleanup(cleanup)
+			store := &memoryJobStore{runs: map[string]time.Time{"digest": last}}
+			s := NewCronScheduler(logger, store, tt.policy)
+			s.now = func() time.Time { return now }
+
+			var mu sync.Mutex
+			var got []time.Time
+			require.NoError(t, s.AddJob("digest", "0 9 * * *", func(ctx context.Context, at time.Time) error {
+				mu.Lock()
+				defer mu.Unlock()
+				got = append(got, at)
+				return nil
+			}, WithLocation(berlin)))
+			require.NoError(t, s.Start())
+			s.catchUp.Wait()
+			require.NoError(t, s.Stop())
+
+			require.Len(t, got, len(tt.want))
+			for i := range tt.want {
+				require.True(t, tt.want[i].Equal(got[i]), "run %d at %s", i, got[i])
+			}
+			require.True(t, tt.mark.Equal(store.runs["digest"]))
+		})
+	}
+}
+
+func TestCronScheduler_CatchUpStopsOnFailure(t *testing.T) {
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+	last := time.Date(2026, 6, 7, 9, 0, 0, 0, time.UTC)
+	store := &memoryJobStore{runs: map[string]time.Time{"digest": last}}
+	s := NewCronScheduler(logger, store, CatchUpAll)
+	s.now = func() time.Time { return time.Date(2026, 6, 10, 10, 0, 0, 0, time.UTC) }
+
+	var calls atomic.Int32
+	require.NoError(t, s.AddJob("digest", "0 9 * * *", func(ctx context.Context, at time.Time) error {
+		calls.Add(1)
+		return errors.New("llm is down")
+	}, WithLocation(time.UTC)))
+	require.NoError(t, s.Start())
+	s.catchUp.Wait()
+	require.NoError(t, s.Stop())
+
+	require.Equal(t, int32(1), calls.Load(), "remaining runs wait for the next start")
+	require.True(t, last.Equal(store.runs["digest"]))
+}
+
+func TestCronScheduler_FirstStartRecordsBaseline(t *testing.T) {
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+	now := time.Date(2026, 6, 10, 10, 0, 0, 0, time.UTC)
+	store := &memoryJobStore{runs: map[string]time.Time{}}
+	s := NewCronScheduler(logger, store, CatchUpAll)
+	s.now = func() time.Time { return now }
+
+	require.NoError(t, s.AddJob("digest", "0 9 * * *", func(ctx context.Context, at time.Time) error {
+		t.Error("nothing to catch up on first start")
+		return nil
+	}))
+	require.NoError(t, s.Start())
+	s.catchUp.Wait()
+	require.NoError(t, s.Stop())
+	require.True(t, now.Equal(store.runs["digest"]))
+}
+
+func TestParseCatchUpPolicy(t *testing.T) {
+	for _, v := range []string{"all", "latest", "skip"} {
+		p, err := ParseCatchUpPolicy(v)
+		require.NoError(t, err)
+		require.Equal(t, CatchUpPolicy(v), p)
+	}
+	_, err := ParseCatchUpPolicy("sometimes")
+	require.Error(t, err)
+}
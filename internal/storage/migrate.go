@@ -0,0 +1,272 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Миграции схемы — SQL-файлы NNNN_name.up.sql / NNNN_name.down.sql, вшитые в бинарник,
+// в своём каталоге для каждого диалекта (migrations/sqlite, migrations/postgres).
+// Применённые версии с контрольными суммами хранятся в schema_migrations.
+// Уже применённый файл менять нельзя: любое изменение схемы — новая миграция.
+//
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+var (
+	// ErrChecksumMismatch — применённая миграция была изменена после применения.
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+	// ErrUnknownMigration — база мигрирована более новой версией программы.
+	ErrUnknownMigration = errors.New("database has migrations unknown to this build")
+	// ErrIrreversibleMigration — у миграции нет down-файла.
+	ErrIrreversibleMigration = errors.New("migration has no down script")
+)
+
+var migrationFile = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration — одна версия схемы
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // hex SHA-256 от Up
+}
+
+// SchemaMigration — запись о применённой миграции
+type SchemaMigration struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"not null"`
+	Checksum  string `gorm:"not null"`
+	AppliedAt int64  `gorm:"not null"` // unix-секунды
+}
+
+func (SchemaMigration) TableName() string { return "schema_migrations" }
+
+// MigrationStatus — миграция и время её применения (нулевое, если не применена)
+type MigrationStatus struct {
+	Migration
+	AppliedAt time.Time
+}
+
+// Applied сообщает, применена ли миграция.
+func (s MigrationStatus) Applied() bool { return !s.AppliedAt.IsZero() }
+
+// Migrator применяет и откатывает миграции по порядку, каждую в своей транзакции.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+	now        func() time.Time
+}
+
+// Migrator возвращает мигратор базы.
+func (s *GormStorage) Migrator() (*Migrator, error) {
+	dir, err := fs.Sub(migrationFiles, "migrations/"+s.db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return newMigrator(s.db, dir)
+}
+
+func newMigrator(db *gorm.DB, dir fs.FS) (*Migrator, error) {
+	migrations, err := loadMigrations(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, now: time.Now}, nil
+}
+
+// loadMigrations читает миграции из dir. Версии должны идти подряд с 1.
+func loadMigrations(dir fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(dir, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		m := migrationFile.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected file in migrations: %s", e.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		body, err := fs.ReadFile(dir, path.Clean(e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+			sum := sha256.Sum256(body)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mig.Down = string(body)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration versions must be sequential from 1, got %d at position %d", m.Version, i+1)
+		}
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+	}
+	return migrations, nil
+}
+
+// Latest возвращает номер последней известной миграции.
+func (m *Migrator) Latest() int {
+	return len(m.migrations)
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	return m.db.WithContext(ctx).Exec("CREATE TABLE IF NOT EXISTS schema_migrations (" +
+		"version integer PRIMARY KEY, name text NOT NULL, checksum text NOT NULL, applied_at bigint NOT NULL)").Error
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]SchemaMigration, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	var rows []SchemaMigration
+	if err := m.db.WithContext(ctx).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]SchemaMigration, len(rows))
+	for _, r := range rows {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// Verify проверяет, что все применённые миграции известны и не изменены.
+func (m *Migrator) Verify(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	return m.verify(applied)
+}
+
+func (m *Migrator) verify(applied map[int]SchemaMigration) error {
+	var errs []error
+	for version, row := range applied {
+		if version < 1 || version > len(m.migrations) {
+			errs = append(errs, fmt.Errorf("%w: %d_%s", ErrUnknownMigration, version, row.Name))
+			continue
+		}
+		if mig := m.migrations[version-1]; mig.Checksum != row.Checksum {
+			errs = append(errs, fmt.Errorf("%w: %d_%s", ErrChecksumMismatch, version, mig.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Status возвращает все известные миграции с отметкой о применении.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		st := MigrationStatus{Migration: mig}
+		if row, ok := applied[mig.Version]; ok {
+			st.AppliedAt = time.Unix(row.AppliedAt, 0)
+		}
+		res = append(res, st)
+	}
+	return res, nil
+}
+
+// Up применяет неприменённые миграции до версии target включительно (0 — все).
+// Перед применением проверяются контрольные суммы уже применённых.
+func (m *Migrator) Up(ctx context.Context, target int) ([]Migration, error) {
+	if target <= 0 || target > len(m.migrations) {
+		target = len(m.migrations)
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.verify(applied); err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, mig := range m.migrations[:target] {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(mig.Up).Error; err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   mig.Version,
+				Name:      mig.Name,
+				Checksum:  mig.Checksum,
+				AppliedAt: m.now().Unix(),
+			}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("apply migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Down откатывает steps последних применённых миграций в обратном порядке.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.verify(applied); err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for version := len(m.migrations); version >= 1 && len(done) < steps; version-- {
+		if _, ok := applied[version]; !ok {
+			continue
+		}
+		mig := m.migrations[version-1]
+		if mig.Down == "" {
+			return done, fmt.Errorf("%w: %d_%s", ErrIrreversibleMigration, mig.Version, mig.Name)
+		}
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(mig.Down).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, mig.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("revert migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
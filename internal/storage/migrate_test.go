@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMigrations_MatchModels(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *GormStorage) {
+		// Миграции — источник схемы: всё, что описано в моделях, должно в ней быть.
+		migrator := st.db.Migrator()
+		for _, model := range []any{&Chat{}, &User{}, &Message{}, &MessageRevision{}, &MessageMedia{}, &MessageReaction{}, &JobRun{}, &OutboxItem{}, &SummaryRun{}, &Digest{}} {
+			stmt := &gorm.Statement{DB: st.db}
+			require.NoError(t, stmt.Parse(model))
+			table := stmt.Schema.Table
+			require.True(t, migrator.HasTable(model), "table %s", table)
+			for _, field := range stmt.Schema.Fields {
+				if field.DBName != "" {
+					require.True(t, migrator.HasColumn(model, field.DBName), "column %s.%s", table, field.DBName)
+				}
+			}
+			for _, idx := range stmt.Schema.ParseIndexes() {
+				require.True(t, migrator.HasIndex(model, idx.Name), "index %s.%s", table, idx.Name)
+			}
+		}
+	})
+}
+
+func TestMigrator_UpgradesLegacyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	require.NoError(t, err)
+	// Схема первых версий: AutoMigrate без schema_migrations и с уникальностью только по message_id.
+	require.NoError(t, db.Exec("CREATE TABLE `messages` (`id` integer PRIMARY KEY AUTOINCREMENT, "+
+		"`chat_id` integer NOT NULL, `message_id` integer NOT NULL, `author_id` integer, `text` text, "+
+		"`timestamp` integer NOT NULL, `reply_to_message_id` integer)").Error)
+	require.NoError(t, db.Exec("CREATE UNIQUE INDEX `idx_chat_message` ON `messages`(`message_id`)").Error)
+	require.NoError(t, db.Exec("INSERT INTO `messages` (`chat_id`, `message_id`, `text`, `timestamp`) VALUES (1, 7, 'old', 100)").Error)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+
+	st, err := NewGormStorage(path)
+	require.NoError(t, err)
+	defer st.Close()
+	ctx := context.Background()
+	require.NoError(t, st.Init(ctx))
+
+	msgs, err := st.GetMessagesAfter(ctx, 1, 0)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, "old", msgs[0].Text)
+	// Тот же ID сообщения в другом чате больше не конфликтует
+	require.NoError(t, st.SaveMessage(ctx, &Message{ChatID: 2, MessageID: 7, Text: "new", Timestamp: 200}))
+	msgs, err = st.GetMessagesAfter(ctx, 2, 0)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+
+	m, err := st.Migrator()
+	require.NoError(t, err)
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	for _, s := range statuses {
+		require.True(t, s.Applied(), "migration %d", s.Version)
+	}
+}
+
+func TestMigrator_DownUp(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *GormStorage) {
+		ctx := context.Background()
+		m, err := st.Migrator()
+		require.NoError(t, err)
+
+		done, err := m.Down(ctx, m.Latest())
+		require.NoError(t, err)
+		require.Len(t, done, m.Latest())
+		require.False(t, st.db.Migrator().HasTable(&Message{}))
+
+		done, err = m.Up(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, done, 1)
+		statuses, err := m.Status(ctx)
+		require.NoError(t, err)
+		require.True(t, statuses[0].Applied())
+		require.False(t, statuses[len(statuses)-1].Applied())
+
+		require.NoError(t, st.Init(ctx))
+		done, err = m.Up(ctx, 0)
+		require.NoError(t, err)
+		require.Empty(t, done, "nothing left to apply")
+	})
+}
+
+func TestMigrator_Verify(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *GormStorage) {
+		ctx := context.Background()
+		m, err := st.Migrator()
+		require.NoError(t, err)
+		require.NoError(t, m.Verify(ctx))
+
+		require.NoError(t, st.db.Model(&SchemaMigration{}).Where("version = ?", 1).Update("checksum", "edited").Error)
+		require.ErrorIs(t, m.Verify(ctx), ErrChecksumMismatch)
+		_, err = m.Up(ctx, 0)
+		require.ErrorIs(t, err, ErrChecksumMismatch)
+		require.NoError(t, st.db.Model(&SchemaMigration{}).Where("version = ?", 1).Update("checksum", m.migrations[0].Checksum).Error)
+
+		// База, мигрированная более новой версией программы
+		require.NoError(t, st.db.Create(&SchemaMigration{Version: m.Latest() + 1, Name: "future", Checksum: "x"}).Error)
+		require.ErrorIs(t, st.Init(ctx), ErrUnknownMigration)
+	})
+}
+
+func TestLoadMigrations(t *testing.T) {
+	file := func(s string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(s)} }
+
+	migrations, err := loadMigrations(fstest.MapFS{
+		"0002_add_b.up.sql":   file("B"),
+		"0001_init.up.sql":    file("A"),
+		"0001_init.down.sql":  file("-A"),
+		"0002_add_b.down.sql": file("-B"),
+	})
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	require.Equal(t, Migration{Version: 1, Name: "init", Up: "A", Down: "-A",
+		Checksum: "559aead08264d5795d3909718cdd05abd49572e84fe55590eef31a88a08fdffd"}, migrations[0])
+	require.Equal(t, "add_b", migrations[1].Name)
+
+	for name, dir := range map[string]fstest.MapFS{
+		"gap":        {"0001_a.up.sql": file("A"), "0003_c.up.sql": file("C")},
+		"bad name":   {"0001_a.up.sql": file("A"), "0002-b.sql": file("B")},
+		"no up":      {"0001_a.down.sql": file("A")},
+		"two names":  {"0001_a.up.sql": file("A"), "0001_b.down.sql": file("B")},
+		"not from 1": {"0002_b.up.sql": file("B")},
+	} {
+		_, err := loadMigrations(dir)
+		require.Error(t, err, name)
+	}
+}
@@ -0,0 +1,83 @@
+-- Схема PostgreSQL, эквивалентная SQLite-миграциям 0001–0002.
+-- Внешних ключей у messages нет: SQLite их не проверяет, и сообщения без
+-- сохранённого автора (author_id = 0) или чата должны записываться так же.
+CREATE TABLE "chats" (
+    "id" bigint PRIMARY KEY,
+    "title" text NOT NULL,
+    "type" text NOT NULL
+);
+
+CREATE TABLE "users" (
+    "id" bigint PRIMARY KEY,
+    "username" text,
+    "display_name" text
+);
+
+CREATE TABLE "messages" (
+    "id" bigserial PRIMARY KEY,
+    "chat_id" bigint NOT NULL,
+    "message_id" bigint NOT NULL,
+    "author_id" bigint,
+    "text" text,
+    "timestamp" bigint NOT NULL,
+    "reply_to_message_id" bigint
+);
+CREATE INDEX "idx_messages_reply_to_message_id" ON "messages"("reply_to_message_id");
+CREATE INDEX "idx_messages_author_id" ON "messages"("author_id");
+CREATE UNIQUE INDEX "idx_chat_message" ON "messages"("chat_id", "message_id");
+CREATE INDEX "idx_messages_chat_time" ON "messages"("chat_id", "timestamp");
+
+CREATE TABLE "job_runs" (
+    "name" text PRIMARY KEY,
+    "last_run_at" bigint NOT NULL
+);
+
+CREATE TABLE "outbox" (
+    "id" bigserial PRIMARY KEY,
+    "digest_key" text NOT NULL,
+    "channel" text NOT NULL,
+    "chat_id" bigint NOT NULL,
+    "payload" text NOT NULL,
+    "status" text NOT NULL,
+    "attempts" bigint NOT NULL,
+    "next_attempt_at" bigint NOT NULL,
+    "last_error" text,
+    "created_at" bigint NOT NULL,
+    "updated_at" bigint NOT NULL
+);
+CREATE INDEX "idx_outbox_due" ON "outbox"("status", "next_attempt_at");
+CREATE UNIQUE INDEX "idx_outbox_digest_channel" ON "outbox"("digest_key", "channel");
+
+CREATE TABLE "summary_runs" (
+    "id" bigserial PRIMARY KEY,
+    "chat_id" bigint NOT NULL,
+    "window_start" bigint NOT NULL,
+    "window_end" bigint NOT NULL,
+    "backend" text,
+    "model" text,
+    "prompt_version" text,
+    "message_count" bigint NOT NULL,
+    "prompt_tokens" bigint NOT NULL,
+    "completion_tokens" bigint NOT NULL,
+    "latency_ms" bigint NOT NULL,
+    "status" text NOT NULL,
+    "error" text,
+    "started_at" bigint NOT NULL
+);
+CREATE INDEX "idx_summary_runs_started_at" ON "summary_runs"("started_at");
+CREATE INDEX "idx_summary_runs_chat_window" ON "summary_runs"("chat_id", "window_start");
+
+CREATE TABLE "digests" (
+    "id" bigserial PRIMARY KEY,
+    "summary_run_id" bigint NOT NULL,
+    "chat_id" bigint NOT NULL,
+    "window_start" bigint NOT NULL,
+    "window_end" bigint NOT NULL,
+    "timezone" text,
+    "text" text NOT NULL,
+    "content" text NOT NULL,
+    "created_at" bigint NOT NULL,
+    CONSTRAINT "fk_digests_run" FOREIGN KEY ("summary_run_id") REFERENCES "summary_runs"("id")
+);
+CREATE INDEX "idx_digests_chat_window" ON "digests"("chat_id", "window_start");
+CREATE UNIQUE INDEX "idx_digests_summary_run_id" ON "digests"("summary_run_id");
@@ -0,0 +1,4 @@
+-- Полнотекстовый поиск (аналог messages_fts в SQLite): конфигурация english
+-- приводит английские слова к основе, кириллицу оставляет как есть.
+-- Выражение должно совпадать с запросом в search.go, иначе индекс не используется.
+CREATE INDEX "idx_messages_search" ON "messages" USING GIN (to_tsvector('english', coalesce("text", '')));
@@ -0,0 +1,13 @@
+-- Правки и удаления сообщений: прежние версии текста — в message_revisions,
+-- удалённое в Telegram сообщение остаётся в базе с отметкой deleted_at.
+ALTER TABLE "messages" ADD COLUMN "edited_at" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "messages" ADD COLUMN "deleted_at" bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE "message_revisions" (
+    "id" bigserial PRIMARY KEY,
+    "chat_id" bigint NOT NULL,
+    "message_id" bigint NOT NULL,
+    "text" text,
+    "replaced_at" bigint NOT NULL
+);
+CREATE INDEX "idx_message_revisions_message" ON "message_revisions"("chat_id", "message_id");
@@ -0,0 +1,17 @@
+-- Вложения сообщений: тип, файл, превью ссылки, опрос с результатами.
+CREATE TABLE "message_media" (
+    "id" bigserial PRIMARY KEY,
+    "chat_id" bigint NOT NULL,
+    "message_id" bigint NOT NULL,
+    "type" text NOT NULL,
+    "file_name" text,
+    "mime_type" text,
+    "size" bigint NOT NULL DEFAULT 0,
+    "url" text,
+    "title" text,
+    "poll_question" text,
+    "poll_options" text,
+    "poll_voters" bigint NOT NULL DEFAULT 0,
+    "poll_closed" boolean NOT NULL DEFAULT false
+);
+CREATE UNIQUE INDEX "idx_message_media_message" ON "message_media"("chat_id", "message_id");
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "message_reactions";
+ALTER TABLE "messages" DROP COLUMN "forwards";
+ALTER TABLE "messages" DROP COLUMN "views";
@@ -0,0 +1,13 @@
+-- Вовлечённость: просмотры и пересылки постов каналов, реакции по видам.
+ALTER TABLE "messages" ADD COLUMN "views" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "messages" ADD COLUMN "forwards" bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE "message_reactions" (
+    "id" bigserial PRIMARY KEY,
+    "chat_id" bigint NOT NULL,
+    "message_id" bigint NOT NULL,
+    "emoji" text NOT NULL,
+    "count" bigint NOT NULL,
+    "reactor_ids" text
+);
+CREATE UNIQUE INDEX "idx_message_reactions_message" ON "message_reactions"("chat_id", "message_id", "emoji");
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS "idx_messages_search";
+CREATE INDEX "idx_messages_search" ON "messages" USING GIN (to_tsvector('english', coalesce("text", '')));
@@ -0,0 +1,4 @@
+-- Поиск не различает «ё» и «е»: индекс строится по тексту с заменой «ё» на «е».
+-- Выражение должно совпадать с запросом в search.go, иначе индекс не используется.
+DROP INDEX IF EXISTS "idx_messages_search";
+CREATE INDEX "idx_messages_search" ON "messages" USING GIN (to_tsvector('english', translate(coalesce("text", ''), 'ёЁ', 'еЕ')));
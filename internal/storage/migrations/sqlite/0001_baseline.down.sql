@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS `digests`;
+DROP TABLE IF EXISTS `summary_runs`;
+DROP TABLE IF EXISTS `outbox`;
+DROP TABLE IF EXISTS `job_runs`;
+DROP TABLE IF EXISTS `messages`;
+DROP TABLE IF EXISTS `users`;
+DROP TABLE IF EXISTS `chats`;
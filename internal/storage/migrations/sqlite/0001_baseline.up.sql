@@ -0,0 +1,85 @@
+-- Схема на момент перехода с AutoMigrate на миграции.
+-- IF NOT EXISTS: базы, созданные AutoMigrate, принимаются как есть.
+CREATE TABLE IF NOT EXISTS `chats` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `title` text NOT NULL,
+    `type` text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS `users` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `username` text,
+    `display_name` text
+);
+
+CREATE TABLE IF NOT EXISTS `messages` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `chat_id` integer NOT NULL,
+    `message_id` integer NOT NULL,
+    `author_id` integer,
+    `text` text,
+    `timestamp` integer NOT NULL,
+    `reply_to_message_id` integer,
+    CONSTRAINT `fk_messages_chat` FOREIGN KEY (`chat_id`) REFERENCES `chats`(`id`),
+    CONSTRAINT `fk_messages_author` FOREIGN KEY (`author_id`) REFERENCES `users`(`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_messages_reply_to_message_id` ON `messages`(`reply_to_message_id`);
+CREATE INDEX IF NOT EXISTS `idx_messages_author_id` ON `messages`(`author_id`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_chat_message` ON `messages`(`chat_id`, `message_id`);
+CREATE INDEX IF NOT EXISTS `idx_messages_chat_time` ON `messages`(`chat_id`, `timestamp`);
+
+CREATE TABLE IF NOT EXISTS `job_runs` (
+    `name` text,
+    `last_run_at` integer NOT NULL,
+    PRIMARY KEY (`name`)
+);
+
+CREATE TABLE IF NOT EXISTS `outbox` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `digest_key` text NOT NULL,
+    `channel` text NOT NULL,
+    `chat_id` integer NOT NULL,
+    `payload` text NOT NULL,
+    `status` text NOT NULL,
+    `attempts` integer NOT NULL,
+    `next_attempt_at` integer NOT NULL,
+    `last_error` text,
+    `created_at` integer NOT NULL,
+    `updated_at` integer NOT NULL
+);
+CREATE INDEX IF NOT EXISTS `idx_outbox_due` ON `outbox`(`status`, `next_attempt_at`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_outbox_digest_channel` ON `outbox`(`digest_key`, `channel`);
+
+CREATE TABLE IF NOT EXISTS `summary_runs` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `chat_id` integer NOT NULL,
+    `window_start` integer NOT NULL,
+    `window_end` integer NOT NULL,
+    `backend` text,
+    `model` text,
+    `prompt_version` text,
+    `message_count` integer NOT NULL,
+    `prompt_tokens` integer NOT NULL,
+    `completion_tokens` integer NOT NULL,
+    `latency_ms` integer NOT NULL,
+    `status` text NOT NULL,
+    `error` text,
+    `started_at` integer NOT NULL
+);
+CREATE INDEX IF NOT EXISTS `idx_summary_runs_started_at` ON `summary_runs`(`started_at`);
+CREATE INDEX IF NOT EXISTS `idx_summary_runs_chat_window` ON `summary_runs`(`chat_id`, `window_start`);
+
+CREATE TABLE IF NOT EXISTS `digests` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `summary_run_id` integer NOT NULL,
+    `chat_id` integer NOT NULL,
+    `window_start` integer NOT NULL,
+    `window_end` integer NOT NULL,
+    `timezone` text,
+    `text` text NOT NULL,
+    `content` text NOT NULL,
+    `created_at` integer NOT NULL,
+    CONSTRAINT `fk_digests_run` FOREIGN KEY (`summary_run_id`) REFERENCES `summary_runs`(`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_digests_chat_window` ON `digests`(`chat_id`, `window_start`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_digests_summary_run_id` ON `digests`(`summary_run_id`);
@@ -0,0 +1,3 @@
+-- Откат невозможен, если в разных чатах уже есть сообщения с одинаковым ID.
+DROP INDEX IF EXISTS `idx_chat_message`;
+CREATE UNIQUE INDEX `idx_chat_message` ON `messages`(`message_id`);
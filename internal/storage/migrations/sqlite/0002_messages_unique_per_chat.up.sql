@@ -0,0 +1,5 @@
+-- Базы первых версий создавали idx_chat_message только по message_id, а AutoMigrate
+-- не пересоздаёт изменившиеся индексы: сообщения с одинаковым ID из разных чатов
+-- конфликтовали. Новое ограничение слабее старого, поэтому пересоздание не падает.
+DROP INDEX IF EXISTS `idx_chat_message`;
+CREATE UNIQUE INDEX `idx_chat_message` ON `messages`(`chat_id`, `message_id`);
@@ -0,0 +1,13 @@
+-- Правки и удаления сообщений: прежние версии текста — в message_revisions,
+-- удалённое в Telegram сообщение остаётся в базе с отметкой deleted_at.
+ALTER TABLE `messages` ADD COLUMN `edited_at` integer NOT NULL DEFAULT 0;
+ALTER TABLE `messages` ADD COLUMN `deleted_at` integer NOT NULL DEFAULT 0;
+
+CREATE TABLE `message_revisions` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `chat_id` integer NOT NULL,
+    `message_id` integer NOT NULL,
+    `text` text,
+    `replaced_at` integer NOT NULL
+);
+CREATE INDEX `idx_message_revisions_message` ON `message_revisions`(`chat_id`, `message_id`);
@@ -0,0 +1,13 @@
+-- Вовлечённость: просмотры и пересылки постов каналов, реакции по видам.
+ALTER TABLE `messages` ADD COLUMN `views` integer NOT NULL DEFAULT 0;
+ALTER TABLE `messages` ADD COLUMN `forwards` integer NOT NULL DEFAULT 0;
+
+CREATE TABLE `message_reactions` (
+    `id` integer PRIMARY KEY AUTOINCREMENT,
+    `chat_id` integer NOT NULL,
+    `message_id` integer NOT NULL,
+    `emoji` text NOT NULL,
+    `count` integer NOT NULL,
+    `reactor_ids` text
+);
+CREATE UNIQUE INDEX `idx_message_reactions_message` ON `message_reactions`(`chat_id`, `message_id`, `emoji`);
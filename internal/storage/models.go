@@ -19,18 +19,141 @@ type User struct {
 
 // Message — сообщение, ссылающееся на чат и пользователя
 type Message struct {
-	ID                int64      `gorm:"primaryKey;autoIncrement"`
-	ChatID            int64      `gorm:"not null;index:idx_messages_chat_time,priority:1"`
-	MessageID         int64      `gorm:"not null;uniqueIndex:idx_chat_message"`
-	AuthorID          int64      `gorm:"index"`
+	ID                int64             `gorm:"primaryKey;autoIncrement"`
+	ChatID            int64             `gorm:"not null;index:idx_messages_chat_time,priority:1;uniqueIndex:idx_chat_message,priority:1"`
+	MessageID         int64             `gorm:"not null;uniqueIndex:idx_chat_message,priority:2"`
+	AuthorID          int64             `gorm:"index"`
 	Text              string
-	Timestamp         int64      `gorm:"not null;index:idx_messages_chat_time,priority:2"`
-	ReplyToMessageID  *int64     `gorm:"index"`
-	Chat              Chat       `gorm:"foreignKey:ChatID;references:ID"`
-	Author            User       `gorm:"foreignKey:AuthorID;references:ID"`
+	Timestamp         int64             `gorm:"not null;index:idx_messages_chat_time,priority:2"`
+	ReplyToMessageID  *int64            `gorm:"index"`
+	EditedAt          int64             `gorm:"not null;default:0"` // unix-секунды последней правки, 0 — не правилось
+	DeletedAt         int64             `gorm:"not null;default:0"` // unix-секунды удаления в Telegram, 0 — не удалено
+	Chat              Chat              `gorm:"foreignKey:ChatID;references:ID"`
+	Author            User              `gorm:"foreignKey:AuthorID;references:ID"`
+	Views             int               `gorm:"not null;default:0"` // просмотры, только у постов каналов
+	Forwards          int               `gorm:"not null;default:0"`
+	Media             *MessageMedia     `gorm:"foreignKey:ChatID,MessageID;references:ChatID,MessageID"` // nil — только текст
+	Reactions         []MessageReaction `gorm:"foreignKey:ChatID,MessageID;references:ChatID,MessageID"` // по убыванию числа
+}
+
+// MessageRevision — прежний текст сообщения, заменённый правкой в ReplacedAt
+type MessageRevision struct {
+	ID         int64  `gorm:"primaryKey;autoIncrement"`
+	ChatID     int64  `gorm:"not null;index:idx_message_revisions_message,priority:1"`
+	MessageID  int64  `gorm:"not null;index:idx_message_revisions_message,priority:2"`
+	Text       string
+	ReplacedAt int64 `gorm:"not null"` // unix-секунды
+}
+
+// MessageMedia — вложение сообщения (не больше одного на сообщение); подпись — в Message.Text
+type MessageMedia struct {
+	ID           int64  `gorm:"primaryKey;autoIncrement"`
+	ChatID       int64  `gorm:"not null;uniqueIndex:idx_message_media_message,priority:1"`
+	MessageID    int64  `gorm:"not null;uniqueIndex:idx_message_media_message,priority:2"`
+	Type         string `gorm:"not null"` // photo, video, document, audio, voice, sticker, webpage, poll, other
+	FileName     string
+	MIMEType     string `gorm:"column:mime_type"`
+	Size         int64  `gorm:"not null;default:0"` // байты, 0 — неизвестно
+	URL          string // ссылка превью
+	Title        string // заголовок превью
+	PollQuestion string
+	PollOptions  []PollOption `gorm:"serializer:json"` // варианты с числом голосов
+	PollVoters   int          `gorm:"not null;default:0"`
+	PollClosed   bool         `gorm:"not null;default:false"`
+}
+
+// PollOption — вариант ответа опроса
+type PollOption struct {
+	Text   string `json:"text"`
+	Voters int    `json:"voters"`
+}
+
+// MessageReaction — реакция одного вида на сообщение и сколько раз её поставили
+type MessageReaction struct {
+	ID         int64   `gorm:"primaryKey;autoIncrement"`
+	ChatID     int64   `gorm:"not null;uniqueIndex:idx_message_reactions_message,priority:1"`
+	MessageID  int64   `gorm:"not null;uniqueIndex:idx_message_reactions_message,priority:2"`
+	Emoji      string  `gorm:"not null;uniqueIndex:idx_message_reactions_message,priority:3"` // эмодзи, custom:<id> или paid
+	Count      int     `gorm:"not null"`
+	ReactorIDs []int64 `gorm:"serializer:json"` // кто поставил, если Telegram это показывает
+}
+
+// JobRun — отметка последнего успешного запуска задания планировщика
+type JobRun struct {
+	Name      string `gorm:"primaryKey"`
+	LastRunAt int64  `gorm:"not null"` // плановое время запуска, unix-секунды
+}
+
+// Статусы элемента outbox
+const (
+	OutboxPending = "pending" // ждёт (повторной) отправки
+	OutboxSent    = "sent"    // доставлен
+	OutboxDead    = "dead"    // попытки исчерпаны, нужен ручной повтор
+)
+
+// OutboxItem — дайджест, ожидающий доставки в один канал.
+// Пара (digest_key, channel) уникальна: повторный запуск задания не дублирует доставку.
+type OutboxItem struct {
+	ID            int64  `gorm:"primaryKey;autoIncrement"`
+	DigestKey     string `gorm:"not null;uniqueIndex:idx_outbox_digest_channel,priority:1"` // чат и окно дайджеста
+	Channel       string `gorm:"not null;uniqueIndex:idx_outbox_digest_channel,priority:2"`
+	ChatID        int64  `gorm:"not null"`
+	Payload       string `gorm:"not null"` // дайджест в JSON
+	Status        string `gorm:"not null;index:idx_outbox_due,priority:1"`
+	Attempts      int    `gorm:"not null"`
+	NextAttemptAt int64  `gorm:"not null;index:idx_outbox_due,priority:2"` // unix-секунды
+	LastError     string
+	CreatedAt     int64 `gorm:"not null"` // unix-секунды
+	UpdatedAt     int64 `gorm:"not null"` // unix-секунды
+}
+
+// Статусы запуска суммаризации
+const (
+	SummaryRunOK     = "ok"     // дайджест построен
+	SummaryRunEmpty  = "empty"  // сообщений за окно нет, дайджест не строился
+	SummaryRunFailed = "failed" // ошибка бэкенда
+)
+
+// SummaryRun — один запуск суммаризации чата за окно: чем, сколько токенов и времени
+type SummaryRun struct {
+	ID               int64  `gorm:"primaryKey;autoIncrement"`
+	ChatID           int64  `gorm:"not null;index:idx_summary_runs_chat_window,priority:1"`
+	WindowStart      int64  `gorm:"not null;index:idx_summary_runs_chat_window,priority:2"` // unix-секунды
+	WindowEnd        int64  `gorm:"not null"`                                               // unix-секунды, не включительно
+	Backend          string // openai, ollama, extractive
+	Model            string
+	PromptVersion    string
+	MessageCount     int    `gorm:"not null"`
+	PromptTokens     int    `gorm:"not null"`
+	CompletionTokens int    `gorm:"not null"`
+	LatencyMS        int64  `gorm:"not null"`
+	Status           string `gorm:"not null"`
+	Error            string
+	StartedAt        int64 `gorm:"not null;index"` // unix-секунды
+}
+
+// Digest — сгенерированный дайджест; построивший его запуск — в Run
+type Digest struct {
+	ID           int64      `gorm:"primaryKey;autoIncrement"`
+	SummaryRunID int64      `gorm:"not null;uniqueIndex"`
+	ChatID       int64      `gorm:"not null;index:idx_digests_chat_window,priority:1"`
+	WindowStart  int64      `gorm:"not null;index:idx_digests_chat_window,priority:2"` // unix-секунды
+	WindowEnd    int64      `gorm:"not null"`                                          // unix-секунды, не включительно
+	Timezone     string     // IANA-зона окна
+	Text         string     `gorm:"not null"` // текст, как он отправляется (Markdown)
+	Content      string     `gorm:"not null"` // JSON: overview, topics, action_items
+	CreatedAt    int64      `gorm:"not null"` // unix-секунды
+	Run          SummaryRun `gorm:"foreignKey:SummaryRunID;references:ID"`
 }
 
 // TableName overrides for GORM pluralization
-func (Chat) TableName() string    { return "chats" }
-func (User) TableName() string    { return "users" }
-func (Message) TableName() string { return "messages" }
\ No newline at end of file
+func (Chat) TableName() string            { return "chats" }
+func (User) TableName() string            { return "users" }
+func (Message) TableName() string         { return "messages" }
+func (MessageRevision) TableName() string { return "message_revisions" }
+func (MessageMedia) TableName() string    { return "message_media" }
+func (MessageReaction) TableName() string { return "message_reactions" }
+func (JobRun) TableName() string          { return "job_runs" }
+func (OutboxItem) TableName() string      { return "outbox" }
+func (SummaryRun) TableName() string      { return "summary_runs" }
+func (Digest) TableName() string          { return "digests" }
\ No newline at end of file
@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// Полнотекстовый поиск.
+//
+// SQLite — внешняя FTS5-таблица messages_fts поверх messages, синхронизируемая
+// триггерами (вставка, правка текста, удаление). FTS5 есть в go-sqlite3 только
+// при сборке с тегом sqlite_fts5, поэтому индекс не входит в миграции: Init
+// создаёт его, если модуль доступен, и убирает триггеры, если нет — иначе запись
+// в messages падала бы с «no such module». Индекс производный: когда триггеров
+// не было, он перестраивается целиком.
+//
+// PostgreSQL — GIN-индекс по to_tsvector из миграции 0006_messages_search_yo, доступен всегда.
+//
+// «ё» в индексе и в запросе заменяется на «е»: «еще» находит «ещё» и наоборот.
+// Фрагменты показывают исходный текст.
+
+// ErrSearchUnavailable возвращается, если SQLite собран без FTS5.
+var ErrSearchUnavailable = errors.New("full-text search is unavailable: build with -tags sqlite_fts5")
+
+const (
+	// Границы совпадений в SearchResult.Snippet
+	HighlightStart = "**"
+	HighlightEnd   = "**"
+
+	defaultSearchLimit = 20
+	snippetTokens      = 16
+	minRussianStem     = 4 // букв, меньше которых окончание не отрезается
+)
+
+// unicode61 складывает регистр и для кириллицы (но «ё» и «е» различает, поэтому
+// триггеры пишут в индекс текст с заменой «ё» на «е»), porter приводит английские
+// слова к основе (кириллицу не трогает). Содержимое — исходный messages.text:
+// snippet берёт из индекса только номера совпавших токенов, а замена «ё» границ
+// токенов не сдвигает.
+const createSearchIndex = "CREATE VIRTUAL TABLE `messages_fts` USING fts5(" +
+	"`text`, content='messages', content_rowid='id', tokenize='porter unicode61 remove_diacritics 2')"
+
+// fillSearchIndex заполняет индекс вместо 'rebuild', который прочитал бы текст без замены «ё».
+var fillSearchIndex = "INSERT INTO `messages_fts`(rowid, `text`) SELECT `id`, " + sqliteFoldYo("`text`") + " FROM `messages`"
+
+var searchTriggers = map[string]string{
+	"messages_fts_insert": "CREATE TRIGGER `messages_fts_insert` AFTER INSERT ON `messages` BEGIN " +
+		"INSERT INTO `messages_fts`(rowid, `text`) VALUES (new.`id`, " + sqliteFoldYo("new.`text`") + "); END",
+	"messages_fts_delete": "CREATE TRIGGER `messages_fts_delete` AFTER DELETE ON `messages` BEGIN " +
+		"INSERT INTO `messages_fts`(`messages_fts`, rowid, `text`) VALUES ('delete', old.`id`, " + sqliteFoldYo("old.`text`") + "); END",
+	"messages_fts_update": "CREATE TRIGGER `messages_fts_update` AFTER UPDATE OF `text` ON `messages` BEGIN " +
+		"INSERT INTO `messages_fts`(`messages_fts`, rowid, `text`) VALUES ('delete', old.`id`, " + sqliteFoldYo("old.`text`") + "); " +
+		"INSERT INTO `messages_fts`(rowid, `text`) VALUES (new.`id`, " + sqliteFoldYo("new.`text`") + "); END",
+}
+
+// sqliteFoldYo заменяет «ё» на «е» в SQL-выражении SQLite, как foldYo в запросе.
+func sqliteFoldYo(expr string) string {
+	return "replace(replace(" + expr + ", 'ё', 'е'), 'Ё', 'Е')"
+}
+
+// SearchFilter ограничивает поиск. Нулевые поля не фильтруют.
+type SearchFilter struct {
+	ChatID   int64
+	AuthorID int64
+	From     time.Time // включительно
+	To       time.Time // не включительно
+	Limit    int       // по умолчанию 20
+}
+
+// SearchResult — найденное сообщение с фрагментом текста, совпадения выделены
+// HighlightStart/HighlightEnd. Rank — bm25, меньше — релевантнее.
+type SearchResult struct {
+	Message `gorm:"embedded"`
+	Snippet string
+	Rank    float64
+}
+
+// Конфигурация текстового поиска PostgreSQL; совпадает с индексом из миграции.
+const (
+	postgresFoldedText   = "translate(coalesce(messages.text, ''), 'ёЁ', 'еЕ')"
+	postgresSearchVector = "to_tsvector('english', " + postgresFoldedText + ")"
+	postgresSearchQuery  = "to_tsquery('english', ?)"
+	postgresHeadline     = "StartSel=" + HighlightStart + ", StopSel=" + HighlightEnd +
+		", MaxFragments=1, MaxWords=16, MinWords=8, FragmentDelimiter=…"
+)
+
+// initSearch создаёт или отключает поисковый индекс SQLite в зависимости от сборки.
+func (s *GormStorage) initSearch(ctx context.Context) error {
+	if s.db.Dialector.Name() == "postgres" {
+		s.search = true
+		return nil
+	}
+	db := s.db.WithContext(ctx)
+	var enabled bool
+	if err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled).Error; err != nil {
+		return err
+	}
+	s.search = enabled
+	return db.Transaction(func(tx *gorm.DB) error {
+		var existing []struct{ Name, SQL string }
+		err := tx.Raw("SELECT name, sql FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'messages_fts_%'").
+			Scan(&existing).Error
+		if err != nil {
+			return err
+		}
+		if !enabled {
+			for _, trigger := range existing {
+				if err := tx.Exec("DROP TRIGGER IF EXISTS `" + trigger.Name + "`").Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		current := len(existing) == len(searchTriggers)
+		for _, trigger := range existing {
+			current = current && trigger.SQL == searchTriggers[trigger.Name]
+		}
+		if current {
+			return nil
+		}
+		// Триггеров не было или они прежней версии: индекс строится заново
+		for _, ddl := range []string{
+			"DROP TABLE IF EXISTS `messages_fts`",
+			"DROP VIEW IF EXISTS `messages_fts_content`",
+			createSearchIndex,
+		} {
+			if err := tx.Exec(ddl).Error; err != nil {
+				return err
+			}
+		}
+		for name, ddl := range searchTriggers {
+			if err := tx.Exec("DROP TRIGGER IF EXISTS `" + name + "`").Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(ddl).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Exec(fillSearchIndex).Error
+	})
+}
+
+// SearchMessages ищет сообщения, содержащие все слова запроса. Английские слова
+// сравниваются по основе, русские — по префиксу без окончания («заморозка деплоя»
+// находит «заморозку деплой»). Результаты упорядочены по релевантности, затем от новых к старым.
+func (s *GormStorage) SearchMessages(ctx context.Context, query string, filter SearchFilter) ([]SearchResult, error) {
+	if !s.search {
+		return nil, ErrSearchUnavailable
+	}
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultSearchLimit
+	}
+	q := s.db.WithContext(ctx)
+	if s.db.Dialector.Name() == "postgres" {
+		// ts_rank растёт с релевантностью, поэтому Rank — со знаком минус, как у bm25
+		match := strings.Join(terms, ":* & ") + ":*"
+		q = q.Table("messages").
+			Select("messages.*, ts_headline('english', "+postgresFoldedText+", "+postgresSearchQuery+", ?) AS snippet, "+
+				"-ts_rank("+postgresSearchVector+", "+postgresSearchQuery+") AS rank",
+				match, postgresHeadline, match).
+			Where(postgresSearchVector+" @@ "+postgresSearchQuery, match)
+	} else {
+		match := `"` + strings.Join(terms, `"* "`) + `"*`
+		q = q.Table("messages_fts").
+			Select("messages.*, snippet(messages_fts, 0, ?, ?, '…', ?) AS snippet, bm25(messages_fts) AS rank",
+				HighlightStart, HighlightEnd, snippetTokens).
+			Joins("JOIN messages ON messages.id = messages_fts.rowid").
+			Where("messages_fts MATCH ?", match)
+	}
+	q = q.Where("messages.deleted_at = 0")
+	if filter.ChatID != 0 {
+		q = q.Where("messages.chat_id = ?", filter.ChatID)
+	}
+	if filter.AuthorID != 0 {
+		q = q.Where("messages.author_id = ?", filter.AuthorID)
+	}
+	if !filter.From.IsZero() {
+		q = q.Where("messages.timestamp >= ?", filter.From.Unix())
+	}
+	if !filter.To.IsZero() {
+		q = q.Where("messages.timestamp < ?", filter.To.Unix())
+	}
+	var res []SearchResult
+	if err := q.Order("rank, messages.timestamp DESC").Limit(filter.Limit).Scan(&res).Error; err != nil {
+		return nil, err
+	}
+	if s.db.Dialector.Name() == "postgres" {
+		for i := range res {
+			res[i].Snippet = restoreYo(res[i].Snippet, res[i].Text)
+		}
+	}
+	return res, s.loadAuthors(ctx, res)
+}
+
+func (s *GormStorage) loadAuthors(ctx context.Context, res []SearchResult) error {
+	ids := make([]int64, 0, len(res))
+	for _, r := range res {
+		ids = append(ids, r.AuthorID)
+	}
+	var users []User
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return err
+	}
+	byID := make(map[int64]User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+	for i := range res {
+		res[i].Author = byID[res[i].AuthorID]
+	}
+	return nil
+}
+
+// searchTerms разбивает ввод пользователя на слова для запроса: в нём остаются только
+// буквы и цифры, так что операторы и знаки препинания из ввода не ломают синтаксис.
+// «ё» заменяется на «е», как в индексе. Каждое слово затем ищется по префиксу.
+func searchTerms(input string) []string {
+	words := strings.FieldsFunc(foldYo.Replace(input), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		words[i] = trimRussianEnding(w)
+	}
+	return words
+}
+
+var foldYo = strings.NewReplacer("ё", "е", "Ё", "Е")
+
+// restoreYo возвращает «ё» во фрагмент ts_headline, построенный по тексту с заменой:
+// куски фрагмента между выделениями и «…» ищутся в тексте с заменой по порядку
+// и берутся из исходного. Смещения совпадают — «ё» и «е» занимают по два байта.
+func restoreYo(snippet, text string) string {
+	if !strings.ContainsAny(text, "ёЁ") {
+		return snippet
+	}
+	folded := foldYo.Replace(text)
+	var b strings.Builder
+	pos := 0
+	for snippet != "" {
+		if sep := snippetSeparator(snippet); sep != "" {
+			b.WriteString(sep)
+			snippet = snippet[len(sep):]
+			continue
+		}
+		end := len(snippet)
+		for i := range snippet {
+			if snippetSeparator(snippet[i:]) != "" {
+				end = i
+				break
+			}
+		}
+		part := snippet[:end]
+		snippet = snippet[end:]
+		if i := strings.Index(folded[pos:], part); i >= 0 {
+			pos += i
+			part = text[pos : pos+len(part)]
+			pos += len(part)
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// snippetSeparator возвращает разметку фрагмента в начале s: выделение или «…».
+func snippetSeparator(s string) string {
+	for _, sep := range []string{HighlightStart, HighlightEnd, "…"} {
+		if strings.HasPrefix(s, sep) {
+			return sep
+		}
+	}
+	return ""
+}
+
+// trimRussianEnding отрезает гласные и «ь»/«й» в конце русского слова — грубая
+// замена стеммингу, которого в FTS5 для русского нет. Короткие слова не трогаются.
+func trimRussianEnding(word string) string {
+	r := []rune(word)
+	if !unicode.Is(unicode.Cyrillic, r[len(r)-1]) {
+		return word
+	}
+	n := len(r)
+	for n > minRussianStem && strings.ContainsRune("аеиийоуыьэюяАЕИЙОУЫЬЭЮЯ", r[n-1]) {
+		n--
+	}
+	return string(r[:n])
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// skipWithoutSearch пропускает тест, если SQLite собран без FTS5: go test -tags sqlite_fts5
+func skipWithoutSearch(t *testing.T, st *GormStorage) {
+	if _, err := st.SearchMessages(context.Background(), "x", SearchFilter{}); errors.Is(err, ErrSearchUnavailable) {
+		t.Skip("SQLite built without FTS5, run with -tags sqlite_fts5")
+	}
+}
+
+func TestGormStorage_SearchMessages(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *GormStorage) {
+		skipWithoutSearch(t, st)
+		ctx := context.Background()
+		day := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+		require.NoError(t, st.SaveUser(ctx, &User{ID: 1, DisplayName: "Anna"}))
+		require.NoError(t, st.SaveUser(ctx, &User{ID: 2, DisplayName: "Boris"}))
+		for _, m := range []Message{
+			{ChatID: 10, MessageID: 1, AuthorID: 1, Timestamp: day.Unix(), Text: "Объявляем заморозку деплоя до понедельника"},
+			{ChatID: 10, MessageID: 2, AuthorID: 2, Timestamp: day.Add(time.Hour).Unix(), Text: "Deploy freeze starts Friday"},
+			{ChatID: 20, MessageID: 1, AuthorID: 2, Timestamp: day.Add(48 * time.Hour).Unix(), Text: "Ещё одна ЗАМОРОЗКА: деплой запрещён"},
+			{ChatID: 20, MessageID: 2, AuthorID: 1, Timestamp: day.Unix(), Text: "Обед в час"},
+		} {
+			require.NoError(t, st.SaveMessage(ctx, &m))
+		}
+
+		// Регистр и окончания не мешают
+		res, err := st.SearchMessages(ctx, "заморозка деплоя", SearchFilter{})
+		require.NoError(t, err)
+		require.Len(t, res, 2)
+		res, err = st.SearchMessages(ctx, "ЕЩЁ", SearchFilter{})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		// «ё» и «е» не различаются
+		res, err = st.SearchMessages(ctx, "еще запрещен", SearchFilter{})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Contains(t, res[0].Snippet, "**Ещё**", "фрагмент — по исходному тексту")
+
+		res, err = st.SearchMessages(ctx, "deploying freezes", SearchFilter{})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Contains(t, res[0].Snippet, "**Deploy** **freeze**")
+		require.Equal(t, "Boris", res[0].Author.DisplayName)
+
+		res, err = st.SearchMessages(ctx, "деплой", SearchFilter{ChatID: 20})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Equal(t, int64(20), res[0].ChatID)
+		res, err = st.SearchMessages(ctx, "деплой", SearchFilter{AuthorID: 1})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Equal(t, "Anna", res[0].Author.DisplayName)
+		res, err = st.SearchMessages(ctx, "деплой", SearchFilter{From: day.Add(time.Hour), To: day.Add(72 * time.Hour)})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Equal(t, int64(20), res[0].ChatID)
+
+		// Операторы FTS5 во вводе — просто слова
+		res, err = st.SearchMessages(ctx, `deploy" OR NEAR(`, SearchFilter{})
+		require.NoError(t, err)
+		require.Empty(t, res)
+	})
+}
+
+func TestGormStorage_SearchFollowsEditsAndDeletes(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, st *GormStorage) {
+		skipWithoutSearch(t, st)
+		ctx := context.Background()
+		require.NoError(t, st.SaveMessage(ctx, &Message{ChatID: 10, MessageID: 1, Text: "release on monday", Timestamp: 1}))
+
+		require.NoError(t, st.UpdateMessageText(ctx, 10, 1, "release on tuesday", time.Unix(2, 0)))
+		res, err := st.SearchMessages(ctx, "monday", SearchFilter{})
+		require.NoError(t, err)
+		require.Empty(t, res)
+		res, err = st.SearchMessages(ctx, "tuesday", SearchFilter{})
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+
+		require.NoError(t, st.DeleteMessages(ctx, 10, []int64{1}, time.Unix(3, 0)))
+		res, err = st.SearchMessages(ctx, "tuesday", SearchFilter{})
+		require.NoError(t, err)
+		require.Empty(t, res)
+	})
+}
+
+func TestGormStorage_SearchIndexRebuiltAfterGap(t *testing.T) {
+	st := setupTestStorage(t)
+	defer teardownTestStorage()
+	skipWithoutSearch(t, st)
+	ctx := context.Background()
+	// Сообщение, записанное сборкой без FTS5 (триггеров нет)
+	require.NoError(t, st.db.Exec("DROP TRIGGER `messages_fts_insert`").Error)
+	require.NoError(t, st.SaveMessage(ctx, &Message{ChatID: 10, MessageID: 1, Text: "written without index", Timestamp: 1}))
+
+	require.NoError(t, st.Init(ctx))
+	res, err := st.SearchMessages(ctx, "index", SearchFilter{})
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+}
+
+func TestGormStorage_SearchIndexUpgradedToFoldYo(t *testing.T) {
+	st := setupTestStorage(t)
+	defer teardownTestStorage()
+	skipWithoutSearch(t, st)
+	ctx := context.Background()
+	// Индекс прежней версии: по messages, без замены «ё»
+	for name := range searchTriggers {
+		require.NoError(t, st.db.Exec("DROP TRIGGER `"+name+"`").Error)
+	}
+	require.NoError(t, st.db.Exec("DROP TABLE `messages_fts`").Error)
+	require.NoError(t, st.db.Exec("CREATE VIRTUAL TABLE `messages_fts` USING fts5("+
+		"`text`, content='messages', content_rowid='id', tokenize='porter unicode61 remove_diacritics 2')").Error)
+	require.NoError(t, st.SaveMessage(ctx, &Message{ChatID: 10, MessageID: 1, Text: "всё готово", Timestamp: 1}))
+
+	require.NoError(t, st.Init(ctx))
+	res, err := st.SearchMessages(ctx, "все", SearchFilter{})
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, "**всё** готово", res[0].Snippet)
+
+	// Триггеры прежней версии, без замены «ё», заменяются
+	require.NoError(t, st.db.Exec("DROP TRIGGER `messages_fts_insert`").Error)
+	require.NoError(t, st.db.Exec("CREATE TRIGGER `messages_fts_insert` AFTER INSERT ON `messages` BEGIN "+
+		"INSERT INTO `messages_fts`(rowid, `text`) VALUES (new.`id`, new.`text`); END").Error)
+	require.NoError(t, st.SaveMessage(ctx, &Message{ChatID: 10, MessageID: 2, Text: "ещё не всё", Timestamp: 2}))
+	require.NoError(t, st.Init(ctx))
+	res, err = st.SearchMessages(ctx, "еще", SearchFilter{})
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+}
+
+func TestRestoreYo(t *testing.T) {
+	text := "Ещё одна ЗАМОРОЗКА: деплой запрещён, **всё**"
+	require.Equal(t, "**Ещё** одна ЗАМОРОЗКА: деплой **запрещён**, **всё**",
+		restoreYo("**Еще** одна ЗАМОРОЗКА: деплой **запрещен**, **все**", text))
+	require.Equal(t, "…деплой **запрещён**…", restoreYo("…деплой **запрещен**…", text))
+	require.Equal(t, "**deploy**", restoreYo("**deploy**", "deploy"))
+}
+
+func TestSearchTerms(t *testing.T) {
+	require.Equal(t, []string{"deploy", "freeze"}, searchTerms("deploy, freeze!"))
+	require.Equal(t, []string{"OR", "NEAR", "x"}, searchTerms(`"OR NEAR(x*`))
+	require.Equal(t, []string{"заморозк", "депл", "кода", "еще"}, searchTerms("заморозка деплой кода ещё"))
+	require.Equal(t, []string{"Еще", "зелен"}, searchTerms("Ещё зелёный"))
+	require.Empty(t, searchTerms(" -- "))
+}
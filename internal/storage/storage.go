@@ -3,26 +3,86 @@ package storage
 import (
 	"context"
 	"errors"
+	"strings"
+	"time"
 
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
+// ErrNotFound возвращается, когда запрошенной записи нет.
+var ErrNotFound = errors.New("not found")
+
 // Storage интерфейс для production-уровня
 type Storage interface {
 	Init(ctx context.Context) error
 	SaveChat(ctx context.Context, chat *Chat) error
 	SaveUser(ctx context.Context, user *User) error
 	SaveMessage(ctx context.Context, msg *Message) error
+	UpdateMessageText(ctx context.Context, chatID, messageID int64, text string, editedAt time.Time) error
+	MessageRevisions(ctx context.Context, chatID, messageID int64) ([]MessageRevision, error)
+	DeleteMessages(ctx context.Context, chatID int64, messageIDs []int64, at time.Time) error
+	CountDeletedMessages(ctx context.Context, chatID int64, from, to time.Time) (int, error)
 	GetLastMessageTimestamp(ctx context.Context, chatID int64) (int64, error)
 	GetMessagesAfter(ctx context.Context, chatID int64, afterTimestamp int64) ([]Message, error)
+	SearchMessages(ctx context.Context, query string, filter SearchFilter) ([]SearchResult, error)
+	LastJobRun(ctx context.Context, name string) (time.Time, error)
+	SaveJobRun(ctx context.Context, name string, at time.Time) error
+	EnqueueOutbox(ctx context.Context, items []OutboxItem) error
+	DueOutbox(ctx context.Context, now time.Time, limit int) ([]OutboxItem, error)
+	SaveOutboxItem(ctx context.Context, item *OutboxItem) error
+	ListOutbox(ctx context.Context, status string, limit int) ([]OutboxItem, error)
+	RequeueOutbox(ctx context.Context, id int64, now time.Time) (bool, error)
+	SaveSummaryRun(ctx context.Context, run *SummaryRun, digest *Digest) error
+	ListSummaryRuns(ctx context.Context, chatID int64, since time.Time, limit int) ([]SummaryRun, error)
+	ListDigests(ctx context.Context, chatID int64, limit int) ([]Digest, error)
+	GetDigest(ctx context.Context, id int64) (*Digest, error)
 	Close() error
 }
 
-// GormStorage — production-реализация на GORM
+// GormStorage — production-реализация на GORM: SQLite (NewGormStorage) или PostgreSQL (NewPostgresStorage)
 type GormStorage struct {
-	db *gorm.DB
+	db     *gorm.DB
+	search bool // доступен ли полнотекстовый поиск, см. search.go
+}
+
+// Пул соединений PostgreSQL
+const (
+	postgresMaxOpenConns    = 10
+	postgresMaxIdleConns    = 5
+	postgresConnMaxLifetime = 30 * time.Minute
+	postgresConnMaxIdleTime = 5 * time.Minute
+)
+
+// Open выбирает бэкенд по схеме DSN: postgres:// и postgresql:// — PostgreSQL,
+// иначе — путь к файлу SQLite (допускается префикс sqlite://).
+func Open(dsn string) (*GormStorage, error) {
+	switch {
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		return NewPostgresStorage(dsn)
+	default:
+		return NewGormStorage(strings.TrimPrefix(dsn, "sqlite://"))
+	}
+}
+
+// NewPostgresStorage подключается к PostgreSQL. Схема та же, что у SQLite,
+// её создают миграции из migrations/postgres.
+func NewPostgresStorage(dsn string) (*GormStorage, error) {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(postgresMaxOpenConns)
+	sqlDB.SetMaxIdleConns(postgresMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(postgresConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(postgresConnMaxIdleTime)
+	return &GormStorage{db: db}, nil
 }
 
 func NewGormStorage(dsn string) (*GormStorage, error) {
@@ -30,11 +90,27 @@ func NewGormStorage(dsn string) (*GormStorage, error) {
 	if err != nil {
 		return nil, err
 	}
+	// SQLite допускает одного писателя: сбор истории и real-time обновления
+	// пишут конкурентно, поэтому сериализуем доступ через одно соединение.
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
 	return &GormStorage{db: db}, nil
 }
 
+// Init применяет миграции схемы (см. migrate.go). Если база мигрирована более
+// новой версией или применённая миграция изменена, возвращает ошибку и ничего не меняет.
 func (s *GormStorage) Init(ctx context.Context) error {
-	return s.db.WithContext(ctx).AutoMigrate(&Chat{}, &User{}, &Message{})
+	m, err := s.Migrator()
+	if err != nil {
+		return err
+	}
+	if _, err := m.Up(ctx, 0); err != nil {
+		return err
+	}
+	return s.initSearch(ctx)
 }
 
 func (s *GormStorage) SaveChat(ctx context.Context, chat *Chat) error {
@@ -49,10 +125,127 @@ func (s *GormStorage) SaveUser(ctx context.Context, user *User) error {
 	).Create(user).Error
 }
 
+// SaveMessage сохраняет сообщение. Если оно уже есть и msg.EditedAt новее
+// сохранённой правки, применяет правку (см. UpdateMessageText). Вложение,
+// просмотры, пересылки и реакции перезаписываются при каждом сохранении:
+// так повторная выборка недавних сообщений обновляет опросы и вовлечённость.
 func (s *GormStorage) SaveMessage(ctx context.Context, msg *Message) error {
-	return s.db.WithContext(ctx).Clauses(
-		clause.OnConflict{DoNothing: true},
-	).Create(msg).Error
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Omit("Media", "Reactions").Clauses(
+			clause.OnConflict{DoNothing: true},
+		).Create(msg)
+		if res.Error != nil {
+			return res.Error
+		}
+		if msg.Media != nil {
+			if err := saveMedia(tx, msg); err != nil {
+				return err
+			}
+		}
+		if res.RowsAffected == 0 {
+			if err := saveEngagement(tx, msg); err != nil {
+				return err
+			}
+		}
+		if len(msg.Reactions) > 0 {
+			for i := range msg.Reactions {
+				msg.Reactions[i].ChatID, msg.Reactions[i].MessageID = msg.ChatID, msg.MessageID
+			}
+			if err := tx.Create(&msg.Reactions).Error; err != nil {
+				return err
+			}
+		}
+		if res.RowsAffected > 0 || msg.EditedAt == 0 {
+			return nil
+		}
+		return applyEdit(tx, msg.ChatID, msg.MessageID, msg.Text, msg.EditedAt)
+	})
+}
+
+func saveMedia(tx *gorm.DB, msg *Message) error {
+	media := msg.Media
+	media.ChatID, media.MessageID = msg.ChatID, msg.MessageID
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chat_id"}, {Name: "message_id"}},
+		UpdateAll: true,
+	}).Create(media).Error
+}
+
+// saveEngagement обновляет счётчики уже сохранённого сообщения и удаляет его
+// реакции: актуальный набор вставляется следом.
+func saveEngagement(tx *gorm.DB, msg *Message) error {
+	err := tx.Model(&Message{}).
+		Where("chat_id = ? AND message_id = ?", msg.ChatID, msg.MessageID).
+		Updates(map[string]any{"views": msg.Views, "forwards": msg.Forwards}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Where("chat_id = ? AND message_id = ?", msg.ChatID, msg.MessageID).
+		Delete(&MessageReaction{}).Error
+}
+
+// UpdateMessageText заменяет текст уже сохранённого сообщения (правка в Telegram),
+// сохраняя прежний текст в message_revisions. Правка старее уже применённой
+// игнорируется. Если сообщения нет в базе, ничего не делает.
+func (s *GormStorage) UpdateMessageText(ctx context.Context, chatID, messageID int64, text string, editedAt time.Time) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return applyEdit(tx, chatID, messageID, text, editedAt.Unix())
+	})
+}
+
+func applyEdit(tx *gorm.DB, chatID, messageID int64, text string, editedAt int64) error {
+	var cur Message
+	err := tx.Where("chat_id = ? AND message_id = ?", chatID, messageID).Take(&cur).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if cur.Text == text || editedAt < cur.EditedAt {
+		return nil
+	}
+	rev := &MessageRevision{ChatID: chatID, MessageID: messageID, Text: cur.Text, ReplacedAt: editedAt}
+	if err := tx.Create(rev).Error; err != nil {
+		return err
+	}
+	return tx.Model(&Message{}).Where("id = ?", cur.ID).
+		Updates(map[string]any{"text": text, "edited_at": editedAt}).Error
+}
+
+// MessageRevisions возвращает прежние версии текста сообщения, от старых к новым.
+func (s *GormStorage) MessageRevisions(ctx context.Context, chatID, messageID int64) ([]MessageRevision, error) {
+	var revs []MessageRevision
+	err := s.db.WithContext(ctx).
+		Where("chat_id = ? AND message_id = ?", chatID, messageID).
+		Order("id ASC").
+		Find(&revs).Error
+	return revs, err
+}
+
+// DeleteMessages помечает сообщения чата удалёнными в момент at; повторная пометка
+// время не меняет. chatID == 0 означает «обычная группа»: для них Telegram не
+// сообщает чат, а ID сообщений уникальны в рамках аккаунта.
+func (s *GormStorage) DeleteMessages(ctx context.Context, chatID int64, messageIDs []int64, at time.Time) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+	q := s.db.WithContext(ctx).Model(&Message{}).Where("message_id IN ? AND deleted_at = 0", messageIDs)
+	if chatID != 0 {
+		q = q.Where("chat_id = ?", chatID)
+	} else {
+		q = q.Where("chat_id IN (?)", s.db.Model(&Chat{}).Select("id").Where("type = ?", "group"))
+	}
+	return q.Update("deleted_at", at.Unix()).Error
+}
+
+// CountDeletedMessages считает сообщения чата, отправленные в [from, to) и затем удалённые.
+func (s *GormStorage) CountDeletedMessages(ctx context.Context, chatID int64, from, to time.Time) (int, error) {
+	var n int64
+	err := s.db.WithContext(ctx).Model(&Message{}).
+		Where("chat_id = ? AND timestamp >= ? AND timestamp < ? AND deleted_at <> 0", chatID, from.Unix(), to.Unix()).
+		Count(&n).Error
+	return int(n), err
 }
 
 func (s *GormStorage) GetLastMessageTimestamp(ctx context.Context, chatID int64) (int64, error) {
@@ -73,12 +266,141 @@ func (s *GormStorage) GetLastMessageTimestamp(ctx context.Context, chatID int64)
 func (s *GormStorage) GetMessagesAfter(ctx context.Context, chatID int64, afterTimestamp int64) ([]Message, error) {
 	var msgs []Message
 	err := s.db.WithContext(ctx).
-		Where("chat_id = ? AND timestamp > ?", chatID, afterTimestamp).
+		Preload("Author").
+		Preload("Media").
+		Preload("Reactions", func(db *gorm.DB) *gorm.DB {
+			return db.Order("count DESC, emoji")
+		}).
+		Where("chat_id = ? AND timestamp > ? AND deleted_at = 0", chatID, afterTimestamp).
 		Order("timestamp ASC").
 		Find(&msgs).Error
 	return msgs, err
 }
 
+// LastJobRun возвращает отметку последнего запуска задания; нулевое время, если запусков не было.
+func (s *GormStorage) LastJobRun(ctx context.Context, name string) (time.Time, error) {
+	var run JobRun
+	err := s.db.WithContext(ctx).Where("name = ?", name).First(&run).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(run.LastRunAt, 0), nil
+}
+
+// SaveJobRun сохраняет отметку запуска задания. Отметка только растёт:
+// более ранний запуск (например, догоняющий) не откатывает её назад.
+func (s *GormStorage) SaveJobRun(ctx context.Context, name string, at time.Time) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "name"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			// MAX(a, b) есть только в SQLite, GREATEST — только в PostgreSQL
+			"last_run_at": gorm.Expr("CASE WHEN excluded.last_run_at > job_runs.last_run_at " +
+				"THEN excluded.last_run_at ELSE job_runs.last_run_at END"),
+		}),
+	}).Create(&JobRun{Name: name, LastRunAt: at.Unix()}).Error
+}
+
+// EnqueueOutbox добавляет элементы в outbox; уже поставленные (digest_key, channel) пропускаются.
+func (s *GormStorage) EnqueueOutbox(ctx context.Context, items []OutboxItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&items).Error
+}
+
+// DueOutbox возвращает ожидающие элементы, время попытки которых наступило, в порядке постановки.
+func (s *GormStorage) DueOutbox(ctx context.Context, now time.Time, limit int) ([]OutboxItem, error) {
+	var items []OutboxItem
+	err := s.db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", OutboxPending, now.Unix()).
+		Order("id ASC").
+		Limit(limit).
+		Find(&items).Error
+	return items, err
+}
+
+// SaveOutboxItem сохраняет результат попытки доставки.
+func (s *GormStorage) SaveOutboxItem(ctx context.Context, item *OutboxItem) error {
+	return s.db.WithContext(ctx).Save(item).Error
+}
+
+// ListOutbox возвращает элементы с заданным статусом (все, если статус пуст), новые первыми.
+func (s *GormStorage) ListOutbox(ctx context.Context, status string, limit int) ([]OutboxItem, error) {
+	q := s.db.WithContext(ctx).Order("id DESC").Limit(limit)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	var items []OutboxItem
+	err := q.Find(&items).Error
+	return items, err
+}
+
+// RequeueOutbox возвращает элемент из dead-letter в очередь с обнулённым счётчиком попыток.
+// false — элемента нет или он не в статусе dead.
+func (s *GormStorage) RequeueOutbox(ctx context.Context, id int64, now time.Time) (bool, error) {
+	res := s.db.WithContext(ctx).Model(&OutboxItem{}).
+		Where("id = ? AND status = ?", id, OutboxDead).
+		Updates(map[string]any{
+			"status":          OutboxPending,
+			"attempts":        0,
+			"next_attempt_at": now.Unix(),
+			"updated_at":      now.Unix(),
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+// SaveSummaryRun сохраняет запуск суммаризации и, если он дал результат, дайджест — в одной транзакции.
+func (s *GormStorage) SaveSummaryRun(ctx context.Context, run *SummaryRun, digest *Digest) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(run).Error; err != nil {
+			return err
+		}
+		if digest == nil {
+			return nil
+		}
+		digest.SummaryRunID = run.ID
+		return tx.Omit("Run").Create(digest).Error
+	})
+}
+
+// ListSummaryRuns возвращает запуски начиная с since, новые первыми; chatID 0 — по всем чатам.
+func (s *GormStorage) ListSummaryRuns(ctx context.Context, chatID int64, since time.Time, limit int) ([]SummaryRun, error) {
+	q := s.db.WithContext(ctx).Where("started_at >= ?", since.Unix())
+	if chatID != 0 {
+		q = q.Where("chat_id = ?", chatID)
+	}
+	var runs []SummaryRun
+	err := q.Order("started_at DESC, id DESC").Limit(limit).Find(&runs).Error
+	return runs, err
+}
+
+// ListDigests возвращает дайджесты с их запусками, новые окна первыми; chatID 0 — по всем чатам.
+func (s *GormStorage) ListDigests(ctx context.Context, chatID int64, limit int) ([]Digest, error) {
+	q := s.db.WithContext(ctx).Preload("Run")
+	if chatID != 0 {
+		q = q.Where("chat_id = ?", chatID)
+	}
+	var digests []Digest
+	err := q.Order("window_start DESC, id DESC").Limit(limit).Find(&digests).Error
+	return digests, err
+}
+
+// GetDigest возвращает дайджест с его запуском; ErrNotFound, если его нет.
+func (s *GormStorage) GetDigest(ctx context.Context, id int64) (*Digest, error) {
+	var digest Digest
+	err := s.db.WithContext(ctx).Preload("Run").First(&digest, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &digest, nil
+}
+
 func (s *GormStorage) Close() error {
 	sqlDB, err := s.db.DB()
 	if err != nil {
@@ -0,0 +1,235 @@
+package summarizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ChatInfo describes the chat and time window being summarized.
+// From and To carry the recipient's timezone; message times are rendered in it.
+type ChatInfo struct {
+	ID    int64
+	Title string
+	Type  string // group, supergroup
+	From  time.Time
+	To    time.Time
+}
+
+// Location returns the timezone of the window, UTC when the window is not set.
+func (c ChatInfo) Location() *time.Location {
+	if c.From.IsZero() {
+		return time.UTC
+	}
+	return c.From.Location()
+}
+
+// MessageLink returns a t.me link to a message, or "" when the chat has no public
+// message links (basic groups).
+func (c ChatInfo) MessageLink(messageID int64) string {
+	if c.Type != "supergroup" || messageID == 0 {
+		return ""
+	}
+	return fmt.Sprintf("https://t.me/c/%d/%d", c.ID, messageID)
+}
+
+// Style controls the shape of the generated digest.
+type Style string
+
+const (
+	StyleBrief    Style = "brief"    // a few sentences per topic
+	StyleDetailed Style = "detailed" // full context, quotes key messages
+	StyleBullets  Style = "bullets"  // terse bullet points only
+)
+
+// Options tune a single summarization request. Zero values mean backend defaults.
+type Options struct {
+	Language  string // e.g. "ru", "en"; empty — language of the chat
+	MaxLength int    // approximate upper bound of the digest in characters, 0 — no limit
+	Style     Style
+}
+
+// Topic is one discussion thread of the digest.
+type Topic struct {
+	Title      string  `json:"title"`
+	Summary    string  `json:"summary"`
+	MessageIDs []int64 `json:"message_ids"`
+}
+
+// ActionItem is a decision or task extracted from the chat.
+type ActionItem struct {
+	Text       string  `json:"text"`
+	Owner      string  `json:"owner"`
+	MessageIDs []int64 `json:"message_ids"`
+}
+
+// Usage reports LLM token consumption; zero for backends without tokens.
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Digest is the structured summarization result.
+type Digest struct {
+	Chat        ChatInfo     `json:"-"`
+	Overview    string       `json:"overview"`
+	Topics      []Topic      `json:"topics"`
+	ActionItems []ActionItem `json:"action_items"`
+	Usage       Usage        `json:"-"`
+	Source      Source       `json:"-"`
+
+	// Changes after posting, filled in by the pipeline when DIGEST_NOTE_CHANGES is on.
+	EditedIDs    []int64 `json:"edited_message_ids,omitempty"` // source messages edited after posting
+	DeletedCount int     `json:"deleted_messages,omitempty"`   // messages of the window deleted since
+}
+
+// Source identifies what produced a digest, for history and cost tracking.
+type Source struct {
+	Backend       string // openai, ollama, extractive
+	Model         string // empty for extractive
+	PromptVersion string // PromptVersion for LLM backends, empty for extractive
+}
+
+// Empty reports whether the digest has no content.
+func (d *Digest) Empty() bool {
+	return d.Overview == "" && len(d.Topics) == 0 && len(d.ActionItems) == 0
+}
+
+// Period renders the digest window, e.g. "17.10.2026 00:00 – 18.10.2026 00:00";
+// empty when the window is not set.
+func (d *Digest) Period() string {
+	if d.Chat.From.IsZero() || d.Chat.To.IsZero() {
+		return ""
+	}
+	return d.Chat.From.Format("02.01.2006 15:04") + " – " + d.Chat.To.Format("02.01.2006 15:04")
+}
+
+// Ref is a link to a source message.
+type Ref struct {
+	ID     int64
+	URL    string
+	Edited bool // the message was edited after posting
+}
+
+// EditedMark follows the label of a reference to an edited message.
+const EditedMark = "✎"
+
+// Label renders the reference text: "#12", or "#12 ✎" for an edited message.
+func (r Ref) Label() string {
+	if r.Edited {
+		return fmt.Sprintf("#%d %s", r.ID, EditedMark)
+	}
+	return fmt.Sprintf("#%d", r.ID)
+}
+
+// Refs returns links to the given source messages; messages without a public link are skipped.
+func (d *Digest) Refs(ids []int64) []Ref {
+	var refs []Ref
+	for _, id := range ids {
+		if link := d.Chat.MessageLink(id); link != "" {
+			refs = append(refs, Ref{ID: id, URL: link, Edited: slices.Contains(d.EditedIDs, id)})
+		}
+	}
+	return refs
+}
+
+// ChangesNote explains the edit marks and counts deleted messages, e.g.
+// "✎ marks messages edited after posting. 2 messages deleted after posting are not included.";
+// empty when nothing changed.
+func (d *Digest) ChangesNote() string {
+	var parts []string
+	if d.citesEdited() {
+		parts = append(parts, EditedMark+" marks messages edited after posting.")
+	}
+	switch {
+	case d.DeletedCount == 1:
+		parts = append(parts, "1 message deleted after posting is not included.")
+	case d.DeletedCount > 1:
+		parts = append(parts, fmt.Sprintf("%d messages deleted after posting are not included.", d.DeletedCount))
+	}
+	return strings.Join(parts, " ")
+}
+
+// citesEdited reports whether a rendered reference carries the edit mark.
+func (d *Digest) citesEdited() bool {
+	var ids []int64
+	for _, t := range d.Topics {
+		ids = append(ids, t.MessageIDs...)
+	}
+	for _, a := range d.ActionItems {
+		ids = append(ids, a.MessageIDs...)
+	}
+	for _, r := range d.Refs(ids) {
+		if r.Edited {
+			return true
+		}
+	}
+	return false
+}
+
+// Text renders the digest as Markdown-ish text for plain-text channels:
+// **bold** headers, bullet lists, [#id](link) references to source messages
+// and an _italic_ note on edited and deleted messages.
+func (d *Digest) Text() string {
+	var b strings.Builder
+	if d.Chat.Title != "" {
+		fmt.Fprintf(&b, "**%s**", d.Chat.Title)
+		if period := d.Period(); period != "" {
+			fmt.Fprintf(&b, " — %s", period)
+		}
+		b.WriteString("\n\n")
+	}
+	if d.Overview != "" {
+		b.WriteString(d.Overview)
+		b.WriteString("\n\n")
+	}
+	for _, t := range d.Topics {
+		fmt.Fprintf(&b, "• **%s** — %s%s\n", t.Title, t.Summary, d.refs(t.MessageIDs))
+	}
+	if len(d.ActionItems) > 0 {
+		if len(d.Topics) > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("**Action items**\n")
+		for _, a := range d.ActionItems {
+			b.WriteString("• ")
+			if a.Owner != "" {
+				fmt.Fprintf(&b, "_%s_: ", a.Owner)
+			}
+			fmt.Fprintf(&b, "%s%s\n", a.Text, d.refs(a.MessageIDs))
+		}
+	}
+	if note := d.ChangesNote(); note != "" {
+		fmt.Fprintf(&b, "\n_%s_\n", note)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func (d *Digest) refs(ids []int64) string {
+	var parts []string
+	for _, r := range d.Refs(ids) {
+		parts = append(parts, fmt.Sprintf("[%s](%s)", r.Label(), r.URL))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// parseDigest decodes the JSON answer requested by digestInstructions.
+// Models sometimes wrap JSON in code fences or answer in prose; prose becomes the overview.
+func parseDigest(content string) *Digest {
+	content = strings.TrimSpace(content)
+	trimmed := strings.TrimPrefix(content, "```json")
+	trimmed = strings.TrimPrefix(trimmed, "```")
+	trimmed = strings.TrimSuffix(strings.TrimSpace(trimmed), "```")
+	if start, end := strings.Index(trimmed, "{"), strings.LastIndex(trimmed, "}"); start >= 0 && end > start {
+		var d Digest
+		if err := json.Unmarshal([]byte(trimmed[start:end+1]), &d); err == nil && !d.Empty() {
+			return &d
+		}
+	}
+	return &Digest{Overview: content}
+}
@@ -0,0 +1,57 @@
+package summarizer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigest_Text(t *testing.T) {
+	d := &Digest{
+		Chat: ChatInfo{
+			ID:    100,
+			Title: "Ops",
+			Type:  "supergroup",
+			From:  time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			To:    time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC),
+		},
+		Overview:    "Quiet day.",
+		Topics:      []Topic{{Title: "Release", Summary: "Shipped v2", MessageIDs: []int64{5}}},
+		ActionItems: []ActionItem{{Text: "Write notes", Owner: "Olga"}},
+	}
+	require.Equal(t, "**Ops** — 01.05.2024 00:00 – 02.05.2024 00:00\n\n"+
+		"Quiet day.\n\n"+
+		"• **Release** — Shipped v2 ([#5](https://t.me/c/100/5))\n\n"+
+		"**Action items**\n"+
+		"• _Olga_: Write notes", d.Text())
+}
+
+func TestDigest_ChangesNote(t *testing.T) {
+	d := &Digest{
+		Chat:   ChatInfo{ID: 100, Type: "supergroup"},
+		Topics: []Topic{{Title: "Release", Summary: "Shipped v2", MessageIDs: []int64{5, 6}}},
+	}
+	require.Empty(t, d.ChangesNote())
+
+	// Only cited messages get the mark; the note explains it.
+	d.EditedIDs = []int64{6, 7}
+	require.Equal(t, []Ref{
+		{ID: 5, URL: "https://t.me/c/100/5"},
+		{ID: 6, URL: "https://t.me/c/100/6", Edited: true},
+	}, d.Refs([]int64{5, 6}))
+	require.Equal(t, "• **Release** — Shipped v2 ([#5](https://t.me/c/100/5), [#6 ✎](https://t.me/c/100/6))\n\n"+
+		"_✎ marks messages edited after posting._", d.Text())
+
+	d.DeletedCount = 1
+	require.Equal(t, "✎ marks messages edited after posting. 1 message deleted after posting is not included.", d.ChangesNote())
+	// Without links there is no mark to explain.
+	d.Chat.Type = "group"
+	d.DeletedCount = 3
+	require.Equal(t, "3 messages deleted after posting are not included.", d.ChangesNote())
+}
+
+func TestChatInfo_MessageLinkOnlyForSupergroups(t *testing.T) {
+	require.Empty(t, ChatInfo{ID: 1, Type: "group"}.MessageLink(5))
+	require.Equal(t, "https://t.me/c/1/5", ChatInfo{ID: 1, Type: "supergroup"}.MessageLink(5))
+}
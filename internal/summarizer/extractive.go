@@ -0,0 +1,417 @@
+package summarizer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/azalio/tg-summary/internal/telegram"
+)
+
+const (
+	textRankDamping    = 0.85
+	textRankIterations = 50
+	textRankEpsilon    = 1e-6
+	// stemLength truncates words to a common prefix: a crude stemmer that
+	// merges Russian and English inflections well enough for sentence similarity.
+	stemLength       = 6
+	minSentenceRunes = 15
+	// maxAttachmentRefs caps the message links of the attachments topic.
+	maxAttachmentRefs = 5
+)
+
+var _ Summarizer = (*ExtractiveSummarizer)(nil)
+
+// ExtractiveSummarizer builds a digest without an LLM: sentences are ranked with
+// TextRank, boosted by how many replies, reactions and forwards their message
+// received, and the top ones are quoted verbatim; shared files and poll results
+// are summed up as extra topics.
+// It is deterministic and works fully offline.
+type ExtractiveSummarizer struct {
+	maxSentences int
+}
+
+// NewExtractiveSummarizer creates a new instance of ExtractiveSummarizer.
+func NewExtractiveSummarizer() *ExtractiveSummarizer {
+	return &ExtractiveSummarizer{maxSentences: 10}
+}
+
+type sentence struct {
+	text       string
+	msg        telegram.Message
+	index      int // position in the transcript, for chronological output
+	stems      map[string]int
+	nStems     int
+	score      float64
+	replies    int
+	engagement int // reactions plus forwards of the message
+}
+
+// Summarize implements the Summarizer interface.
+func (s *ExtractiveSummarizer) Summarize(ctx context.Context, chat ChatInfo, messages []telegram.Message, opts Options) (*Digest, error) {
+	digest := &Digest{Chat: chat, Source: Source{Backend: "extractive"}}
+	sentences := splitSentences(messages)
+	attachments := attachmentTopics(messages, opts.Language)
+	if len(sentences) == 0 && len(attachments) == 0 {
+		return digest, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rankSentences(sentences)
+	picked := s.pick(sentences, opts)
+
+	authors := make(map[string]bool)
+	for _, m := range messages {
+		authors[m.Sender] = true
+	}
+	digest.Overview = overviewLine(len(messages), len(authors), opts.Language)
+	for _, sn := range picked {
+		if isActionItem(sn.text) {
+			digest.ActionItems = append(digest.ActionItems, ActionItem{
+				Text:       sn.text,
+				Owner:      sn.msg.Sender,
+				MessageIDs: []int64{sn.msg.ID},
+			})
+			continue
+		}
+		digest.Topics = append(digest.Topics, Topic{
+			Title:      sn.msg.Sender,
+			Summary:    sn.text,
+			MessageIDs: []int64{sn.msg.ID},
+		})
+	}
+	digest.Topics = append(digest.Topics, attachments...)
+	return digest, nil
+}
+
+// pick selects the best sentences, one per message, within the length budget,
+// and returns them in chronological order.
+func (s *ExtractiveSummarizer) pick(sentences []*sentence, opts Options) []*sentence {
+	limit := s.maxSentences
+	if opts.Style == StyleBullets {
+		limit = limit / 2
+	} else if opts.Style == StyleDetailed {
+		limit = limit * 2
+	}
+
+	ranked := append([]*sentence(nil), sentences...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].index < ranked[j].index
+	})
+
+	var picked []*sentence
+	usedMsg := make(map[int64]bool)
+	length := 0
+	for _, sn := range ranked {
+		if len(picked) >= limit {
+			break
+		}
+		if usedMsg[sn.msg.ID] {
+			continue
+		}
+		n := utf8.RuneCountInString(sn.text)
+		if opts.MaxLength > 0 && length+n > opts.MaxLength && len(picked) > 0 {
+			continue
+		}
+		picked = append(picked, sn)
+		usedMsg[sn.msg.ID] = true
+		length += n
+	}
+	sort.Slice(picked, func(i, j int) bool { return picked[i].index < picked[j].index })
+	return picked
+}
+
+// rankSentences runs weighted PageRank over the sentence similarity graph
+// and boosts sentences whose messages started discussions.
+func rankSentences(sentences []*sentence) {
+	type edge struct {
+		to int
+		w  float64
+	}
+	n := len(sentences)
+	// Sparse adjacency: most sentence pairs share no stems.
+	edges := make([][]edge, n)
+	outSum := make([]float64, n)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if w := similarity(sentences[i], sentences[j]); w > 0 {
+				edges[i] = append(edges[i], edge{to: j, w: w})
+				edges[j] = append(edges[j], edge{to: i, w: w})
+				outSum[i] += w
+				outSum[j] += w
+			}
+		}
+	}
+
+	scores := make([]float64, n)
+	for i := range scores {
+		scores[i] = 1
+	}
+	next := make([]float64, n)
+	for iter := 0; iter < textRankIterations; iter++ {
+		delta := 0.0
+		for i := 0; i < n; i++ {
+			sum := 0.0
+			for _, e := range edges[i] {
+				sum += e.w / outSum[e.to] * scores[e.to]
+			}
+			next[i] = (1 - textRankDamping) + textRankDamping*sum
+			delta += math.Abs(next[i] - scores[i])
+		}
+		scores, next = next, scores
+		if delta < textRankEpsilon {
+			break
+		}
+	}
+
+	// Reactions weigh more than replies: they are the readers' own verdict.
+	for i, sn := range sentences {
+		sn.score = scores[i] * (1 + 0.5*math.Log1p(float64(sn.replies)) + math.Log1p(float64(sn.engagement)))
+	}
+}
+
+// similarity is the TextRank overlap measure: shared stems normalized by sentence lengths.
+func similarity(a, b *sentence) float64 {
+	if a.nStems < 2 || b.nStems < 2 {
+		return 0
+	}
+	common := 0
+	for stem := range a.stems {
+		if _, ok := b.stems[stem]; ok {
+			common++
+		}
+	}
+	if common == 0 {
+		return 0
+	}
+	return float64(common) / (math.Log(float64(a.nStems)) + math.Log(float64(b.nStems)))
+}
+
+// splitSentences breaks messages into sentences and counts replies and engagement
+// (reactions plus forwards) per message.
+func splitSentences(messages []telegram.Message) []*sentence {
+	replies := make(map[int64]int)
+	for _, m := range messages {
+		if m.ReplyToID != 0 {
+			replies[m.ReplyToID]++
+		}
+	}
+
+	var result []*sentence
+	for _, m := range messages {
+		for _, text := range sentenceTexts(m.Text) {
+			if utf8.RuneCountInString(text) < minSentenceRunes {
+				continue
+			}
+			stems := stemWords(text)
+			result = append(result, &sentence{
+				text:       text,
+				msg:        m,
+				index:      len(result),
+				stems:      stems,
+				nStems:     len(stems),
+				replies:    replies[m.ID],
+				engagement: m.TotalReactions() + m.Forwards,
+			})
+		}
+	}
+	return result
+}
+
+// sentenceTexts splits text on line breaks and sentence-final punctuation.
+func sentenceTexts(text string) []string {
+	var out []string
+	var b strings.Builder
+	runes := []rune(text)
+	for i, r := range runes {
+		if r == '\n' {
+			out = appendSentence(out, b.String())
+			b.Reset()
+			continue
+		}
+		b.WriteRune(r)
+		if (r == '.' || r == '!' || r == '?') && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])) {
+			out = appendSentence(out, b.String())
+			b.Reset()
+		}
+	}
+	return appendSentence(out, b.String())
+}
+
+func appendSentence(out []string, s string) []string {
+	if s = strings.TrimSpace(s); s != "" {
+		out = append(out, s)
+	}
+	return out
+}
+
+func stemWords(text string) map[string]int {
+	stems := make(map[string]int)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		if utf8.RuneCountInString(w) < 3 || stopWords[w] {
+			continue
+		}
+		if r := []rune(w); len(r) > stemLength {
+			w = string(r[:stemLength])
+		}
+		stems[w]++
+	}
+	return stems
+}
+
+// isActionItem spots sentences that assign or request work.
+func isActionItem(text string) bool {
+	lower := strings.ToLower(text)
+	for _, marker := range actionMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func overviewLine(messages, authors int, language string) string {
+	if language == "ru" {
+		return fmt.Sprintf("Сообщений: %d, участников: %d. Ключевые сообщения:", messages, authors)
+	}
+	return fmt.Sprintf("%d messages from %d participants. Key messages:", messages, authors)
+}
+
+// attachmentTopics sums up shared files by kind ("PDF: 3, photos: 2") and adds
+// one topic per poll with its results.
+func attachmentTopics(messages []telegram.Message, language string) []Topic {
+	counts := make(map[string]int)
+	var ids []int64
+	var polls []Topic
+	for _, m := range messages {
+		if m.Media == nil {
+			continue
+		}
+		if p := m.Media.Poll; p != nil {
+			polls = append(polls, Topic{Title: p.Question, Summary: pollOutcome(p, language), MessageIDs: []int64{m.ID}})
+			continue
+		}
+		kind := attachmentKind(m.Media, language)
+		if kind == "" {
+			continue
+		}
+		counts[kind]++
+		if len(ids) < maxAttachmentRefs {
+			ids = append(ids, m.ID)
+		}
+	}
+	if len(counts) == 0 {
+		return polls
+	}
+	kinds := make([]string, 0, len(counts))
+	for k := range counts {
+		kinds = append(kinds, k)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		if counts[kinds[i]] != counts[kinds[j]] {
+			return counts[kinds[i]] > counts[kinds[j]]
+		}
+		return kinds[i] < kinds[j]
+	})
+	parts := make([]string, 0, len(kinds))
+	for _, k := range kinds {
+		parts = append(parts, fmt.Sprintf("%s: %d", k, counts[k]))
+	}
+	title := "Attachments"
+	if language == "ru" {
+		title = "Вложения"
+	}
+	files := Topic{Title: title, Summary: strings.Join(parts, ", "), MessageIDs: ids}
+	return append([]Topic{files}, polls...)
+}
+
+var attachmentLabels = map[string]map[telegram.MediaType]string{
+	"en": {
+		telegram.MediaPhoto:    "photos",
+		telegram.MediaVideo:    "videos",
+		telegram.MediaVoice:    "voice messages",
+		telegram.MediaAudio:    "audio",
+		telegram.MediaWebPage:  "links",
+		telegram.MediaDocument: "documents",
+	},
+	"ru": {
+		telegram.MediaPhoto:    "фото",
+		telegram.MediaVideo:    "видео",
+		telegram.MediaVoice:    "голосовые",
+		telegram.MediaAudio:    "аудио",
+		telegram.MediaWebPage:  "ссылки",
+		telegram.MediaDocument: "документы",
+	},
+}
+
+// attachmentKind names the group an attachment is counted in: the file extension
+// for documents ("PDF"), otherwise the media kind. Stickers and the like are not counted.
+func attachmentKind(m *telegram.Media, language string) string {
+	if m.Type == telegram.MediaDocument {
+		ext := strings.TrimPrefix(path.Ext(m.FileName), ".")
+		if n := len(ext); n > 0 && n <= 5 && strings.IndexFunc(ext, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) < 0 {
+			return strings.ToUpper(ext)
+		}
+	}
+	labels := attachmentLabels["en"]
+	if language == "ru" {
+		labels = attachmentLabels["ru"]
+	}
+	return labels[m.Type]
+}
+
+// pollOutcome renders poll results: "Yes — 2, No — 5 (7 votes, closed)".
+func pollOutcome(p *telegram.Poll, language string) string {
+	opts := make([]string, 0, len(p.Options))
+	for _, o := range p.Options {
+		opts = append(opts, fmt.Sprintf("%s — %d", o.Text, o.Voters))
+	}
+	var state string
+	switch {
+	case language == "ru" && p.Closed:
+		state = fmt.Sprintf("голосов: %d, опрос завершён", p.TotalVoters)
+	case language == "ru":
+		state = fmt.Sprintf("голосов: %d, опрос идёт", p.TotalVoters)
+	case p.Closed:
+		state = fmt.Sprintf("%d votes, closed", p.TotalVoters)
+	default:
+		state = fmt.Sprintf("%d votes, still open", p.TotalVoters)
+	}
+	return strings.Join(opts, ", ") + " (" + state + ")"
+}
+
+var actionMarkers = []string{
+	"todo", "need to", "needs to", "must ", "please ", "action item", "deadline",
+	"нужно", "надо", "необходимо", "сделай", "сделать до", "прошу", "дедлайн",
+}
+
+var stopWords = func() map[string]bool {
+	words := strings.Fields(`
+		the and for are but not you all any can had her was one our out has have
+		this that with from they will would there their what when which who how
+		your been were also into just than then them these some such only very
+		что это как так его она они вот все еще уже для или при без над под
+		про был была были быть если тоже только когда где чем там тут его ему
+		мне меня нас вас вам нам них это этот эта эти тот того там здесь
+	`)
+	m := make(map[string]bool, len(words))
+	for _, w := range words {
+		m[w] = true
+	}
+	return m
+}()
@@ -0,0 +1,130 @@
+package summarizer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/telegram"
+	"github.com/stretchr/testify/require"
+)
+
+var extractiveMessages = []telegram.Message{
+	{ID: 1, Sender: "Ivan", Text: "The deploy freeze starts on Friday for the release branch.", Timestamp: 100},
+	{ID: 2, Sender: "Olga", Text: "Is the deploy freeze also covering hotfixes on the release branch?", Timestamp: 110, ReplyToID: 1},
+	{ID: 3, Sender: "Petr", Text: "Lunch?", Timestamp: 120},
+	{ID: 4, Sender: "Ivan", Text: "Hotfixes are allowed during the deploy freeze with approval.", Timestamp: 130, ReplyToID: 2},
+	{ID: 5, Sender: "Anna", Text: "Random cat picture from my weekend trip to the mountains.", Timestamp: 140},
+	{ID: 6, Sender: "Olga", Text: "Нужно обновить документацию по релизу до пятницы.", Timestamp: 150, ReplyToID: 1},
+}
+
+func TestExtractiveSummarizer_RanksCentralAndRepliedMessages(t *testing.T) {
+	s := &ExtractiveSummarizer{maxSentences: 3}
+	digest, err := s.Summarize(context.Background(), ChatInfo{Title: "Ops"}, extractiveMessages, Options{})
+	require.NoError(t, err)
+
+	require.Equal(t, "6 messages from 4 participants. Key messages:", digest.Overview)
+	var ids []int64
+	for _, topic := range digest.Topics {
+		ids = append(ids, topic.MessageIDs[0])
+	}
+	// The freeze thread wins over unrelated chatter; output is chronological.
+	require.Equal(t, []int64{1, 2, 4}, ids)
+
+	// Deterministic across runs.
+	again, err := s.Summarize(context.Background(), ChatInfo{Title: "Ops"}, extractiveMessages, Options{})
+	require.NoError(t, err)
+	require.Equal(t, digest, again)
+}
+
+func TestExtractiveSummarizer_BoostsReactedMessages(t *testing.T) {
+	messages := append([]telegram.Message(nil), extractiveMessages...)
+	messages[3].Reactions = []telegram.Reaction{{Emoji: "👍", Count: 4}}
+	messages[3].Forwards = 1
+	s := &ExtractiveSummarizer{maxSentences: 2}
+	digest, err := s.Summarize(context.Background(), ChatInfo{Title: "Ops"}, messages, Options{})
+	require.NoError(t, err)
+
+	var ids []int64
+	for _, topic := range digest.Topics {
+		ids = append(ids, topic.MessageIDs[0])
+	}
+	// Without reactions the answer about hotfixes ranks third.
+	require.Contains(t, ids, int64(4))
+}
+
+func TestExtractiveSummarizer_EmptyAndMaxLength(t *testing.T) {
+	s := NewExtractiveSummarizer()
+	digest, err := s.Summarize(context.Background(), ChatInfo{}, []telegram.Message{{ID: 1, Text: "ok"}}, Options{})
+	require.NoError(t, err)
+	require.True(t, digest.Empty())
+
+	digest, err = s.Summarize(context.Background(), ChatInfo{}, extractiveMessages, Options{MaxLength: 70, Language: "ru"})
+	require.NoError(t, err)
+	require.Len(t, digest.Topics, 1)
+	require.Contains(t, digest.Overview, "Сообщений: 6")
+}
+
+func TestExtractiveSummarizer_ActionItems(t *testing.T) {
+	s := NewExtractiveSummarizer()
+	digest, err := s.Summarize(context.Background(), ChatInfo{}, extractiveMessages[5:], Options{})
+	require.NoError(t, err)
+	require.Empty(t, digest.Topics)
+	require.Len(t, digest.ActionItems, 1)
+	require.Equal(t, "Olga", digest.ActionItems[0].Owner)
+	require.Equal(t, []int64{6}, digest.ActionItems[0].MessageIDs)
+}
+
+func TestExtractiveSummarizer_Attachments(t *testing.T) {
+	doc := func(id int64, name string) telegram.Message {
+		return telegram.Message{ID: id, Sender: "Ivan", Media: &telegram.Media{Type: telegram.MediaDocument, FileName: name}}
+	}
+	messages := []telegram.Message{
+		doc(1, "q1.pdf"), doc(2, "q2.PDF"), doc(3, "notes"), doc(4, "q3.pdf"),
+		{ID: 5, Sender: "Olga", Media: &telegram.Media{Type: telegram.MediaPhoto}},
+		{ID: 6, Sender: "Olga", Media: &telegram.Media{Type: telegram.MediaSticker}},
+		{ID: 7, Sender: "Olga", Media: &telegram.Media{Type: telegram.MediaPoll, Poll: &telegram.Poll{
+			Question: "Deploy on Friday?", Options: []telegram.PollOption{{Text: "Yes", Voters: 2}, {Text: "No", Voters: 5}},
+			TotalVoters: 7, Closed: true,
+		}}},
+	}
+	digest, err := NewExtractiveSummarizer().Summarize(context.Background(), ChatInfo{}, messages, Options{})
+	require.NoError(t, err)
+	require.Equal(t, []Topic{
+		{Title: "Attachments", Summary: "PDF: 3, documents: 1, photos: 1", MessageIDs: []int64{1, 2, 3, 4, 5}},
+		{Title: "Deploy on Friday?", Summary: "Yes — 2, No — 5 (7 votes, closed)", MessageIDs: []int64{7}},
+	}, digest.Topics)
+
+	digest, err = NewExtractiveSummarizer().Summarize(context.Background(), ChatInfo{}, messages[4:], Options{Language: "ru"})
+	require.NoError(t, err)
+	require.Equal(t, "фото: 1", digest.Topics[0].Summary)
+	require.Equal(t, "Yes — 2, No — 5 (голосов: 7, опрос завершён)", digest.Topics[1].Summary)
+}
+
+func TestSentenceTexts(t *testing.T) {
+	require.Equal(t, []string{"First one.", "Second v1.2 here!", "Third"},
+		sentenceTexts("First one. Second v1.2 here!\nThird"))
+}
+
+type failingSummarizer struct{ err error }
+
+func (f failingSummarizer) Summarize(ctx context.Context, chat ChatInfo, messages []telegram.Message, opts Options) (*Digest, error) {
+	return nil, f.err
+}
+
+func TestFallbackSummarizer(t *testing.T) {
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	defer cleanup()
+
+	s := NewFallbackSummarizer(logger, failingSummarizer{err: errors.New("api down")}, NewExtractiveSummarizer())
+	digest, err := s.Summarize(context.Background(), ChatInfo{}, extractiveMessages, Options{})
+	require.NoError(t, err)
+	require.False(t, digest.Empty())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.Summarize(ctx, ChatInfo{}, extractiveMessages, Options{})
+	require.Error(t, err)
+}
@@ -0,0 +1,35 @@
+package summarizer
+
+import (
+	"context"
+
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/telegram"
+	"go.uber.org/zap"
+)
+
+var _ Summarizer = (*FallbackSummarizer)(nil)
+
+// FallbackSummarizer uses the primary backend and switches to the fallback
+// when it fails, e.g. an extractive digest while the LLM API is down.
+// Cancellation of ctx is never masked by the fallback.
+type FallbackSummarizer struct {
+	primary  Summarizer
+	fallback Summarizer
+	log      applog.Logger
+}
+
+// NewFallbackSummarizer creates a new instance of FallbackSummarizer.
+func NewFallbackSummarizer(logger applog.Logger, primary, fallback Summarizer) *FallbackSummarizer {
+	return &FallbackSummarizer{primary: primary, fallback: fallback, log: logger}
+}
+
+// Summarize implements the Summarizer interface.
+func (s *FallbackSummarizer) Summarize(ctx context.Context, chat ChatInfo, messages []telegram.Message, opts Options) (*Digest, error) {
+	digest, err := s.primary.Summarize(ctx, chat, messages, opts)
+	if err == nil || ctx.Err() != nil {
+		return digest, err
+	}
+	s.log.Warn("Primary summarizer failed, using fallback", zap.Int64("chat_id", chat.ID), zap.Error(err))
+	return s.fallback.Summarize(ctx, chat, messages, opts)
+}
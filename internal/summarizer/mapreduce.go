@@ -0,0 +1,280 @@
+package summarizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/telegram"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// segmentGap splits conversations: a pause this long starts a new segment
+	// unless the message replies into the current one.
+	segmentGap = 30 * time.Minute
+	// maxReduceRounds bounds hierarchical reduction of partial digests.
+	maxReduceRounds = 4
+)
+
+var _ Summarizer = (*MapReduceSummarizer)(nil)
+
+// MapReduceSummarizer wraps any Summarizer for chats that do not fit one prompt:
+// messages are split into token-budgeted chunks along conversation boundaries,
+// chunks are summarized concurrently, and partial digests are reduced into one.
+type MapReduceSummarizer struct {
+	inner       Summarizer
+	chunkTokens int
+	concurrency int
+	log         applog.Logger
+}
+
+// NewMapReduceSummarizer creates a new instance of MapReduceSummarizer around inner.
+func NewMapReduceSummarizer(logger applog.Logger, inner Summarizer, cfg *config.Config) *MapReduceSummarizer {
+	concurrency := cfg.SummaryConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &MapReduceSummarizer{
+		inner:       inner,
+		chunkTokens: cfg.SummaryChunkTokens,
+		concurrency: concurrency,
+		log:         logger,
+	}
+}
+
+// Summarize implements the Summarizer interface.
+// Input that fits into a single chunk is passed to the inner summarizer as is.
+func (s *MapReduceSummarizer) Summarize(ctx context.Context, chat ChatInfo, messages []telegram.Message, opts Options) (*Digest, error) {
+	chunks := splitChunks(messages, s.chunkTokens)
+	if len(chunks) <= 1 {
+		return s.inner.Summarize(ctx, chat, messages, opts)
+	}
+
+	var usage Usage
+	for round := 1; ; round++ {
+		s.log.Info("Map-reduce round",
+			zap.Int64("chat_id", chat.ID),
+			zap.Int("round", round),
+			zap.Int("chunks", len(chunks)),
+		)
+		partials, err := s.mapChunks(ctx, chat, chunks, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range partials {
+			usage.PromptTokens += p.Usage.PromptTokens
+			usage.CompletionTokens += p.Usage.CompletionTokens
+		}
+
+		reduced := partialsAsMessages(partials, chunks)
+		chunks = splitChunks(reduced, s.chunkTokens)
+		if len(chunks) <= 1 || round >= maxReduceRounds {
+			final, err := s.summarizeChunk(ctx, chat, reduced, opts)
+			if err != nil {
+				return nil, fmt.Errorf("reduce: %w", err)
+			}
+			final.Chat = chat
+			final.Usage.PromptTokens += usage.PromptTokens
+			final.Usage.CompletionTokens += usage.CompletionTokens
+			return final, nil
+		}
+	}
+}
+
+// mapChunks summarizes chunks with bounded concurrency, preserving order.
+func (s *MapReduceSummarizer) mapChunks(ctx context.Context, chat ChatInfo, chunks [][]telegram.Message, opts Options) ([]*Digest, error) {
+	partials := make([]*Digest, len(chunks))
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(s.concurrency)
+	for i, chunk := range chunks {
+		g.Go(func() error {
+			d, err := s.summarizeChunk(ctx, chat, chunk, opts)
+			if err != nil {
+				return fmt.Errorf("chunk %d/%d: %w", i+1, len(chunks), err)
+			}
+			partials[i] = d
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return partials, nil
+}
+
+// summarizeChunk halves a chunk that still overflows the model context.
+func (s *MapReduceSummarizer) summarizeChunk(ctx context.Context, chat ChatInfo, chunk []telegram.Message, opts Options) (*Digest, error) {
+	d, err := s.inner.Summarize(ctx, chat, chunk, opts)
+	if err == nil || !errors.Is(err, ErrContextLengthExceeded) || len(chunk) < 2 {
+		return d, err
+	}
+	s.log.Warn("Chunk exceeds model context, splitting", zap.Int("messages", len(chunk)))
+	mid := len(chunk) / 2
+	left, err := s.summarizeChunk(ctx, chat, chunk[:mid], opts)
+	if err != nil {
+		return nil, err
+	}
+	right, err := s.summarizeChunk(ctx, chat, chunk[mid:], opts)
+	if err != nil {
+		return nil, err
+	}
+	return mergeDigests(left, right), nil
+}
+
+func mergeDigests(a, b *Digest) *Digest {
+	return &Digest{
+		Chat:        a.Chat,
+		Overview:    strings.TrimSpace(a.Overview + " " + b.Overview),
+		Topics:      append(append([]Topic{}, a.Topics...), b.Topics...),
+		ActionItems: append(append([]ActionItem{}, a.ActionItems...), b.ActionItems...),
+		Usage: Usage{
+			PromptTokens:     a.Usage.PromptTokens + b.Usage.PromptTokens,
+			CompletionTokens: a.Usage.CompletionTokens + b.Usage.CompletionTokens,
+		},
+		Source: a.Source,
+	}
+}
+
+// partialsAsMessages turns partial digests into pseudo-messages for the reduce step.
+// Each topic keeps the ID of its first source message so references survive reduction,
+// and the time of its chunk so the reduce prompt stays chronological.
+func partialsAsMessages(partials []*Digest, chunks [][]telegram.Message) []telegram.Message {
+	var msgs []telegram.Message
+	for i, p := range partials {
+		sender := fmt.Sprintf("Part %d", i+1)
+		ts := chunks[i][0].Timestamp
+		if p.Overview != "" {
+			msgs = append(msgs, telegram.Message{Sender: sender, Text: "Overview: " + p.Overview, Timestamp: ts})
+		}
+		for _, t := range p.Topics {
+			msgs = append(msgs, telegram.Message{
+				ID:        firstID(t.MessageIDs),
+				Sender:    sender,
+				Text:      fmt.Sprintf("Topic %q: %s%s", t.Title, t.Summary, idList(t.MessageIDs)),
+				Timestamp: ts,
+			})
+		}
+		for _, a := range p.ActionItems {
+			text := "Action item: " + a.Text
+			if a.Owner != "" {
+				text += " (owner: " + a.Owner + ")"
+			}
+			msgs = append(msgs, telegram.Message{
+				ID:        firstID(a.MessageIDs),
+				Sender:    sender,
+				Text:      text + idList(a.MessageIDs),
+				Timestamp: ts,
+			})
+		}
+	}
+	return msgs
+}
+
+func firstID(ids []int64) int64 {
+	if len(ids) == 0 {
+		return 0
+	}
+	return ids[0]
+}
+
+func idList(ids []int64) string {
+	if len(ids) == 0 {
+		return ""
+	}
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprintf("#%d", id)
+	}
+	return " [messages " + strings.Join(parts, ", ") + "]"
+}
+
+// estimateTokens is a cheap, tokenizer-free upper estimate:
+// ~3 characters per token covers both Cyrillic and Latin text, plus per-line overhead.
+func estimateTokens(m telegram.Message) int {
+	n := utf8.RuneCountInString(m.Text) + utf8.RuneCountInString(m.Sender)
+	if m.Media != nil {
+		n += utf8.RuneCountInString(describeMedia(m.Media))
+	}
+	n += utf8.RuneCountInString(describeEngagement(m))
+	return n/3 + 12
+}
+
+// splitChunks packs conversation segments into chunks of at most budget tokens.
+// A budget <= 0 disables splitting. Segments larger than the budget are cut by messages.
+func splitChunks(messages []telegram.Message, budget int) [][]telegram.Message {
+	if len(messages) == 0 {
+		return nil
+	}
+	if budget <= 0 {
+		return [][]telegram.Message{messages}
+	}
+
+	var chunks [][]telegram.Message
+	var cur []telegram.Message
+	curTokens := 0
+	flush := func() {
+		if len(cur) > 0 {
+			chunks = append(chunks, cur)
+			cur, curTokens = nil, 0
+		}
+	}
+
+	for _, seg := range splitSegments(messages) {
+		segTokens := 0
+		for _, m := range seg {
+			segTokens += estimateTokens(m)
+		}
+		if curTokens+segTokens <= budget {
+			cur = append(cur, seg...)
+			curTokens += segTokens
+			continue
+		}
+		flush()
+		if segTokens <= budget {
+			cur, curTokens = append(cur, seg...), segTokens
+			continue
+		}
+		// An oversized segment is split by messages; order is preserved.
+		for _, m := range seg {
+			t := estimateTokens(m)
+			if curTokens+t > budget {
+				flush()
+			}
+			cur = append(cur, m)
+			curTokens += t
+		}
+	}
+	flush()
+	return chunks
+}
+
+// splitSegments groups time-ordered messages into conversations: a new segment starts
+// after a pause of segmentGap, unless the message replies into the current segment.
+func splitSegments(messages []telegram.Message) [][]telegram.Message {
+	var segments [][]telegram.Message
+	var cur []telegram.Message
+	inCur := make(map[int64]bool)
+	for _, m := range messages {
+		if len(cur) > 0 {
+			gap := time.Duration(m.Timestamp-cur[len(cur)-1].Timestamp) * time.Second
+			if gap >= segmentGap && !(m.ReplyToID != 0 && inCur[m.ReplyToID]) {
+				segments = append(segments, cur)
+				cur = nil
+				inCur = make(map[int64]bool)
+			}
+		}
+		cur = append(cur, m)
+		inCur[m.ID] = true
+	}
+	if len(cur) > 0 {
+		segments = append(segments, cur)
+	}
+	return segments
+}
@@ -0,0 +1,144 @@
+package summarizer
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/telegram"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSummarizer returns one topic per call and records its inputs.
+type recordingSummarizer struct {
+	mu       sync.Mutex
+	calls    [][]telegram.Message
+	inFlight atomic.Int32
+	maxSeen  atomic.Int32
+	maxInput int // fail with context length above this many messages, 0 — never
+}
+
+func (r *recordingSummarizer) Summarize(ctx context.Context, chat ChatInfo, messages []telegram.Message, opts Options) (*Digest, error) {
+	n := r.inFlight.Add(1)
+	defer r.inFlight.Add(-1)
+	for {
+		seen := r.maxSeen.Load()
+		if n <= seen || r.maxSeen.CompareAndSwap(seen, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	r.mu.Lock()
+	r.calls = append(r.calls, messages)
+	r.mu.Unlock()
+
+	if r.maxInput > 0 && len(messages) > r.maxInput {
+		return nil, &APIError{StatusCode: 400, Code: "context_length_exceeded"}
+	}
+	return &Digest{
+		Overview: "part",
+		Topics:   []Topic{{Title: "t", Summary: "s", MessageIDs: []int64{messages[0].ID}}},
+		Usage:    Usage{PromptTokens: 10, CompletionTokens: 1},
+	}, nil
+}
+
+func newTestMapReduce(t *testing.T, inner Summarizer, chunkTokens, concurrency int) *MapReduceSummarizer {
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+	return NewMapReduceSummarizer(logger, inner, &config.Config{
+		SummaryChunkTokens: chunkTokens,
+		SummaryConcurrency: concurrency,
+	})
+}
+
+func makeMessages(n int, start int64, step time.Duration) []telegram.Message {
+	msgs := make([]telegram.Message, n)
+	for i := range msgs {
+		msgs[i] = telegram.Message{
+			ID:        int64(i + 1),
+			Sender:    "user",
+			Text:      strings.Repeat("x", 60),
+			Timestamp: start + int64(i)*int64(step/time.Second),
+		}
+	}
+	return msgs
+}
+
+func TestSplitChunks_RespectsSegments(t *testing.T) {
+	// Two conversations separated by a long pause; each fits the budget alone.
+	first := makeMessages(3, 0, time.Minute)
+	second := makeMessages(3, 10_000, time.Minute)
+	for i := range second {
+		second[i].ID += 100
+	}
+	msgs := append(first, second...)
+	perMsg := estimateTokens(msgs[0])
+
+	chunks := splitChunks(msgs, perMsg*4)
+	require.Len(t, chunks, 2)
+	require.Len(t, chunks[0], 3)
+	require.Len(t, chunks[1], 3)
+
+	// A reply into the previous conversation keeps it open despite the pause.
+	second[0].ReplyToID = first[2].ID
+	chunks = splitChunks(append(first, second...), perMsg*6)
+	require.Len(t, chunks, 1)
+
+	// Oversized segments are cut by messages.
+	chunks = splitChunks(makeMessages(10, 0, time.Minute), perMsg*3)
+	require.Len(t, chunks, 4)
+	require.Nil(t, splitChunks(nil, 100))
+}
+
+func TestMapReduceSummarizer_SmallInputPassesThrough(t *testing.T) {
+	inner := &recordingSummarizer{}
+	s := newTestMapReduce(t, inner, 10_000, 2)
+	_, err := s.Summarize(context.Background(), ChatInfo{}, makeMessages(5, 0, time.Minute), Options{})
+	require.NoError(t, err)
+	require.Len(t, inner.calls, 1)
+	require.Len(t, inner.calls[0], 5)
+}
+
+func TestMapReduceSummarizer_MapThenReduce(t *testing.T) {
+	inner := &recordingSummarizer{}
+	msgs := makeMessages(40, 0, time.Minute)
+	s := newTestMapReduce(t, inner, estimateTokens(msgs[0])*10, 2)
+
+	chat := ChatInfo{ID: 1, Title: "Busy"}
+	digest, err := s.Summarize(context.Background(), chat, msgs, Options{})
+	require.NoError(t, err)
+
+	// 4 map calls + 1 reduce call over pseudo-messages.
+	require.Len(t, inner.calls, 5)
+	require.LessOrEqual(t, inner.maxSeen.Load(), int32(2))
+	reduceInput := inner.calls[4]
+	require.Len(t, reduceInput, 8) // overview + topic per partial
+	require.Contains(t, reduceInput[1].Text, "[messages #1]")
+	require.Equal(t, chat, digest.Chat)
+	require.Equal(t, Usage{PromptTokens: 50, CompletionTokens: 5}, digest.Usage)
+}
+
+func TestMapReduceSummarizer_SplitsOnContextLength(t *testing.T) {
+	inner := &recordingSummarizer{maxInput: 5}
+	msgs := makeMessages(20, 0, time.Minute)
+	s := newTestMapReduce(t, inner, estimateTokens(msgs[0])*10, 1)
+
+	digest, err := s.Summarize(context.Background(), ChatInfo{}, msgs, Options{})
+	require.NoError(t, err)
+	require.NotEmpty(t, digest.Topics)
+	// Every overflowing input was retried in halves that fit.
+	fitting := 0
+	for _, call := range inner.calls {
+		if len(call) <= 5 {
+			fitting++
+		}
+	}
+	require.Greater(t, fitting, 4)
+}
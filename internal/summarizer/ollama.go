@@ -0,0 +1,175 @@
+package summarizer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/telegram"
+	"go.uber.org/zap"
+)
+
+var _ Summarizer = (*OllamaSummarizer)(nil)
+
+// OllamaSummarizer talks to a local Ollama server (/api/chat with streaming),
+// so chat contents never leave the machine.
+type OllamaSummarizer struct {
+	baseURL     string
+	model       string
+	keepAlive   string
+	numCtx      int // 0 keeps the model default
+	temperature float64
+	timeout     time.Duration
+	httpClient  *http.Client
+	log         applog.Logger
+}
+
+// NewOllamaSummarizer creates a new instance of OllamaSummarizer using injected config and logger.
+func NewOllamaSummarizer(logger applog.Logger, cfg *config.Config) *OllamaSummarizer {
+	return &OllamaSummarizer{
+		baseURL:     strings.TrimRight(cfg.OllamaURL, "/"),
+		model:       cfg.OllamaModel,
+		keepAlive:   cfg.OllamaKeepAlive,
+		numCtx:      cfg.OllamaNumCtx,
+		temperature: cfg.LLMTemperature,
+		timeout:     cfg.LLMTimeout,
+		httpClient:  &http.Client{},
+		log:         logger,
+	}
+}
+
+type ollamaChatRequest struct {
+	Model     string         `json:"model"`
+	Messages  []chatMessage  `json:"messages"`
+	Stream    bool           `json:"stream"`
+	KeepAlive string         `json:"keep_alive,omitempty"`
+	Options   map[string]any `json:"options,omitempty"`
+}
+
+// ollamaChatChunk is one NDJSON line of a streaming /api/chat response.
+type ollamaChatChunk struct {
+	Message         chatMessage `json:"message"`
+	Done            bool        `json:"done"`
+	Error           string      `json:"error"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+}
+
+func (s *OllamaSummarizer) source() Source {
+	return Source{Backend: "ollama", Model: s.model, PromptVersion: PromptVersion}
+}
+
+// Summarize implements the Summarizer interface.
+func (s *OllamaSummarizer) Summarize(ctx context.Context, chat ChatInfo, messages []telegram.Message, opts Options) (*Digest, error) {
+	transcript := buildTranscript(messages, chat.Location())
+	if transcript == "" {
+		return &Digest{Chat: chat, Source: s.source()}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	content, usage, err := s.chat(ctx, []chatMessage{
+		{Role: "system", Content: buildSystemPrompt(opts)},
+		{Role: "user", Content: buildUserPrompt(chat, transcript)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	digest := parseDigest(content)
+	digest.Chat = chat
+	digest.Usage = usage
+	digest.Source = s.source()
+	return digest, nil
+}
+
+// chat streams one /api/chat response and concatenates the content chunks.
+func (s *OllamaSummarizer) chat(ctx context.Context, msgs []chatMessage) (string, Usage, error) {
+	options := map[string]any{"temperature": s.temperature}
+	// Ollama's default context is a few thousand tokens and a longer prompt is cut
+	// without an error, so the window is sized for a whole chunk.
+	if s.numCtx > 0 {
+		options["num_ctx"] = s.numCtx
+	}
+	body, err := json.Marshal(ollamaChatRequest{
+		Model:     s.model,
+		Messages:  msgs,
+		Stream:    true,
+		KeepAlive: s.keepAlive,
+		Options:   options,
+	})
+	if err != nil {
+		return "", Usage{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return "", Usage{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", Usage{}, fmt.Errorf("ollama request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(resp.Body)
+		apiErr := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(raw))}
+		var body struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(raw, &body) == nil && body.Error != "" {
+			apiErr.Message = body.Error
+		}
+		s.log.Error("Ollama request failed", zap.Int("status", resp.StatusCode), zap.Error(apiErr))
+		return "", Usage{}, apiErr
+	}
+
+	var content strings.Builder
+	var usage Usage
+	done := false
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk ollamaChatChunk
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return "", Usage{}, fmt.Errorf("decode ollama stream: %w", err)
+		}
+		if chunk.Error != "" {
+			return "", Usage{}, &APIError{StatusCode: resp.StatusCode, Message: chunk.Error}
+		}
+		content.WriteString(chunk.Message.Content)
+		if chunk.Done {
+			usage = Usage{PromptTokens: chunk.PromptEvalCount, CompletionTokens: chunk.EvalCount}
+			done = true
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", Usage{}, fmt.Errorf("read ollama stream: %w", err)
+	}
+	if !done {
+		return "", Usage{}, fmt.Errorf("ollama stream ended before done: %w", io.ErrUnexpectedEOF)
+	}
+
+	s.log.Info("Ollama completion received",
+		zap.String("model", s.model),
+		zap.Int("prompt_tokens", usage.PromptTokens),
+		zap.Int("completion_tokens", usage.CompletionTokens),
+		zap.Duration("latency", time.Since(start)),
+	)
+	return strings.TrimSpace(content.String()), usage, nil
+}
@@ -0,0 +1,91 @@
+package summarizer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestOllamaSummarizer(t *testing.T, handler http.HandlerFunc) *OllamaSummarizer {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+	return NewOllamaSummarizer(logger, &config.Config{
+		OllamaURL:       srv.URL,
+		OllamaModel:     "llama3.1",
+		OllamaKeepAlive: "10m",
+		OllamaNumCtx:    8192,
+		LLMTemperature:  0.1,
+		LLMTimeout:      5 * time.Second,
+	})
+}
+
+// writeNDJSON mimics Ollama's streaming response: content chunks then a final "done" line.
+func writeNDJSON(w http.ResponseWriter, parts ...string) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher := w.(http.Flusher)
+	for _, p := range parts {
+		chunk, _ := json.Marshal(map[string]any{"message": map[string]string{"role": "assistant", "content": p}, "done": false})
+		fmt.Fprintf(w, "%s\n", chunk)
+		flusher.Flush()
+	}
+	fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":42,"eval_count":7}`)
+}
+
+func TestOllamaSummarizer_Streaming(t *testing.T) {
+	s := newTestOllamaSummarizer(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/chat", r.URL.Path)
+		var req ollamaChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "llama3.1", req.Model)
+		require.True(t, req.Stream)
+		require.Equal(t, "10m", req.KeepAlive)
+		require.Equal(t, 0.1, req.Options["temperature"])
+		require.Equal(t, 8192.0, req.Options["num_ctx"])
+		require.Len(t, req.Messages, 2)
+		require.Contains(t, req.Messages[1].Content, "Deploy freeze starts Friday")
+
+		writeNDJSON(w, `{"overview": "Fre`, `eze agreed", "topics": [`, `{"title":"Freeze","summary":"Friday","message_ids":[1]}]}`)
+	})
+
+	digest, err := s.Summarize(context.Background(), ChatInfo{Title: "Ops"}, testMessages, Options{})
+	require.NoError(t, err)
+	require.Equal(t, "Freeze agreed", digest.Overview)
+	require.Len(t, digest.Topics, 1)
+	require.Equal(t, Usage{PromptTokens: 42, CompletionTokens: 7}, digest.Usage)
+	require.Equal(t, Source{Backend: "ollama", Model: "llama3.1", PromptVersion: PromptVersion}, digest.Source)
+}
+
+func TestOllamaSummarizer_Errors(t *testing.T) {
+	s := newTestOllamaSummarizer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"model \"llama3.1\" not found, try pulling it first"}`))
+	})
+	_, err := s.Summarize(context.Background(), ChatInfo{}, testMessages, Options{})
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	require.Contains(t, apiErr.Message, "not found")
+
+	s = newTestOllamaSummarizer(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"partial"},"done":false}`)
+	})
+	_, err = s.Summarize(context.Background(), ChatInfo{}, testMessages, Options{})
+	require.ErrorContains(t, err, "ended before done")
+
+	s = newTestOllamaSummarizer(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"error":"out of memory"}`)
+	})
+	_, err = s.Summarize(context.Background(), ChatInfo{}, testMessages, Options{})
+	require.ErrorContains(t, err, "out of memory")
+}
@@ -0,0 +1,213 @@
+package summarizer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/telegram"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrRateLimited is matched by APIError for HTTP 429 responses.
+	ErrRateLimited = errors.New("llm api rate limited")
+	// ErrContextLengthExceeded is matched by APIError when the prompt does not fit the model context.
+	ErrContextLengthExceeded = errors.New("llm context length exceeded")
+	// ErrUnauthorized is matched by APIError for HTTP 401/403 responses.
+	ErrUnauthorized = errors.New("llm api unauthorized")
+	// ErrEmptyResponse is returned when the API answers without choices.
+	ErrEmptyResponse = errors.New("llm api returned no choices")
+)
+
+// APIError is an error response of an OpenAI-compatible API.
+// Use errors.Is with ErrRateLimited, ErrContextLengthExceeded or ErrUnauthorized to classify it.
+type APIError struct {
+	StatusCode int
+	Type       string
+	Code       string
+	Message    string
+	RetryAfter time.Duration // from the Retry-After header, 0 if absent
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("llm api error (status %d, code %q): %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Is classifies the error for errors.Is.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrContextLengthExceeded:
+		return e.Code == "context_length_exceeded" ||
+			strings.Contains(strings.ToLower(e.Message), "context length")
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+var _ Summarizer = (*OpenAISummarizer)(nil)
+
+// OpenAISummarizer is the production implementation using an OpenAI-compatible
+// /v1/chat/completions API (OpenAI, vLLM, LM Studio, OpenRouter, ...).
+type OpenAISummarizer struct {
+	baseURL     string
+	apiKey      string
+	model       string
+	temperature float64
+	timeout     time.Duration
+	httpClient  *http.Client
+	log         applog.Logger
+}
+
+// NewOpenAISummarizer creates a new instance of OpenAISummarizer using injected config and logger.
+func NewOpenAISummarizer(logger applog.Logger, cfg *config.Config) *OpenAISummarizer {
+	return &OpenAISummarizer{
+		baseURL:     strings.TrimRight(cfg.LLMBaseURL, "/"),
+		apiKey:      cfg.LLMAPIKey,
+		model:       cfg.LLMModel,
+		temperature: cfg.LLMTemperature,
+		timeout:     cfg.LLMTimeout,
+		httpClient:  &http.Client{},
+		log:         logger,
+	}
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatCompletionRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+}
+
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+type apiErrorBody struct {
+	Error struct {
+		Message string          `json:"message"`
+		Type    string          `json:"type"`
+		Code    json.RawMessage `json:"code"` // string for OpenAI, number for some proxies
+	} `json:"error"`
+}
+
+func (s *OpenAISummarizer) source() Source {
+	return Source{Backend: "openai", Model: s.model, PromptVersion: PromptVersion}
+}
+
+// Summarize implements the Summarizer interface.
+func (s *OpenAISummarizer) Summarize(ctx context.Context, chat ChatInfo, messages []telegram.Message, opts Options) (*Digest, error) {
+	transcript := buildTranscript(messages, chat.Location())
+	if transcript == "" {
+		return &Digest{Chat: chat, Source: s.source()}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	content, usage, err := s.complete(ctx, []chatMessage{
+		{Role: "system", Content: buildSystemPrompt(opts)},
+		{Role: "user", Content: buildUserPrompt(chat, transcript)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	digest := parseDigest(content)
+	digest.Chat = chat
+	digest.Usage = usage
+	digest.Source = s.source()
+	return digest, nil
+}
+
+// complete sends one chat completion request and returns the first choice.
+func (s *OpenAISummarizer) complete(ctx context.Context, msgs []chatMessage) (string, Usage, error) {
+	body, err := json.Marshal(chatCompletionRequest{
+		Model:       s.model,
+		Messages:    msgs,
+		Temperature: s.temperature,
+	})
+	if err != nil {
+		return "", Usage{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", Usage{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	start := time.Now()
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", Usage{}, fmt.Errorf("llm request: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", Usage{}, fmt.Errorf("read llm response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := parseAPIError(resp, raw)
+		s.log.Error("LLM API request failed", zap.Int("status", resp.StatusCode), zap.Error(apiErr))
+		return "", Usage{}, apiErr
+	}
+
+	var out chatCompletionResponse
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return "", Usage{}, fmt.Errorf("decode llm response: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return "", Usage{}, ErrEmptyResponse
+	}
+	s.log.Info("LLM completion received",
+		zap.String("model", s.model),
+		zap.Int("prompt_tokens", out.Usage.PromptTokens),
+		zap.Int("completion_tokens", out.Usage.CompletionTokens),
+		zap.Duration("latency", time.Since(start)),
+	)
+	usage := Usage{PromptTokens: out.Usage.PromptTokens, CompletionTokens: out.Usage.CompletionTokens}
+	return strings.TrimSpace(out.Choices[0].Message.Content), usage, nil
+}
+
+func parseAPIError(resp *http.Response, raw []byte) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	var body apiErrorBody
+	if err := json.Unmarshal(raw, &body); err == nil && body.Error.Message != "" {
+		apiErr.Message = body.Error.Message
+		apiErr.Type = body.Error.Type
+		apiErr.Code = strings.Trim(string(body.Error.Code), `"`)
+		if apiErr.Code == "null" {
+			apiErr.Code = ""
+		}
+	} else {
+		apiErr.Message = strings.TrimSpace(string(raw))
+	}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return apiErr
+}
@@ -0,0 +1,149 @@
+package summarizer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azalio/tg-summary/internal/config"
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/azalio/tg-summary/internal/telegram"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestOpenAISummarizer(t *testing.T, handler http.HandlerFunc) *OpenAISummarizer {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+	return NewOpenAISummarizer(logger, &config.Config{
+		LLMBaseURL:     srv.URL + "/v1/",
+		LLMAPIKey:      "secret",
+		LLMModel:       "test-model",
+		LLMTemperature: 0.3,
+		LLMTimeout:     5 * time.Second,
+	})
+}
+
+var testMessages = []telegram.Message{
+	{ID: 1, Sender: "Ivan", Text: "Deploy freeze starts Friday", Timestamp: 1700000000},
+	{ID: 2, Sender: "Olga", Text: "ok", Timestamp: 1700000060, ReplyToID: 1},
+}
+
+func TestOpenAISummarizer_Summarize(t *testing.T) {
+	s := newTestOpenAISummarizer(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/chat/completions", r.URL.Path)
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		var req chatCompletionRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "test-model", req.Model)
+		require.Equal(t, 0.3, req.Temperature)
+		require.Len(t, req.Messages, 2)
+		require.Equal(t, "system", req.Messages[0].Role)
+		require.Contains(t, req.Messages[0].Content, `language "en"`)
+		require.Contains(t, req.Messages[0].Content, "under 500 characters")
+		require.Contains(t, req.Messages[1].Content, "Chat: Ops (supergroup)")
+		require.Contains(t, req.Messages[1].Content, "Ivan: Deploy freeze starts Friday")
+		require.Contains(t, req.Messages[1].Content, "Olga (reply to #1): ok")
+
+		content, _ := json.Marshal("```json\n" + `{"overview":"Freeze agreed","topics":[{"title":"Deploy freeze","summary":"Starts Friday","message_ids":[1,2]}],"action_items":[{"text":"Announce freeze","owner":"Ivan","message_ids":[1]}]}` + "\n```")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":` + string(content) + `}}],"usage":{"prompt_tokens":10,"completion_tokens":2}}`))
+	})
+
+	chat := ChatInfo{ID: 42, Title: "Ops", Type: "supergroup"}
+	digest, err := s.Summarize(context.Background(), chat, testMessages, Options{Language: "en", MaxLength: 500})
+	require.NoError(t, err)
+	require.Equal(t, "Freeze agreed", digest.Overview)
+	require.Len(t, digest.Topics, 1)
+	require.Equal(t, []int64{1, 2}, digest.Topics[0].MessageIDs)
+	require.Equal(t, "Ivan", digest.ActionItems[0].Owner)
+	require.Equal(t, Usage{PromptTokens: 10, CompletionTokens: 2}, digest.Usage)
+	require.Equal(t, Source{Backend: "openai", Model: "test-model", PromptVersion: PromptVersion}, digest.Source)
+	require.Equal(t, chat, digest.Chat)
+}
+
+func TestOpenAISummarizer_ProseAnswerBecomesOverview(t *testing.T) {
+	s := newTestOpenAISummarizer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"  Just prose  "}}]}`))
+	})
+	digest, err := s.Summarize(context.Background(), ChatInfo{}, testMessages, Options{})
+	require.NoError(t, err)
+	require.Equal(t, "Just prose", digest.Overview)
+}
+
+func TestOpenAISummarizer_ContextCancelled(t *testing.T) {
+	s := newTestOpenAISummarizer(t, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := s.Summarize(ctx, ChatInfo{}, testMessages, Options{})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestOpenAISummarizer_EmptyInputSkipsAPI(t *testing.T) {
+	s := newTestOpenAISummarizer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("API must not be called")
+	})
+	digest, err := s.Summarize(context.Background(), ChatInfo{}, nil, Options{})
+	require.NoError(t, err)
+	require.True(t, digest.Empty())
+}
+
+func TestOpenAISummarizer_TypedErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header map[string]string
+		body   string
+		is     error
+	}{
+		{
+			name:   "rate limit",
+			status: http.StatusTooManyRequests,
+			header: map[string]string{"Retry-After": "7"},
+			body:   `{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`,
+			is:     ErrRateLimited,
+		},
+		{
+			name:   "context length",
+			status: http.StatusBadRequest,
+			body:   `{"error":{"message":"This model's maximum context length is 8192 tokens","type":"invalid_request_error","code":"context_length_exceeded"}}`,
+			is:     ErrContextLengthExceeded,
+		},
+		{
+			name:   "unauthorized plain text",
+			status: http.StatusUnauthorized,
+			body:   "invalid key",
+			is:     ErrUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestOpenAISummarizer(t, func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.header {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			})
+			_, err := s.Summarize(context.Background(), ChatInfo{}, testMessages, Options{})
+			require.ErrorIs(t, err, tt.is)
+
+			var apiErr *APIError
+			require.True(t, errors.As(err, &apiErr))
+			require.Equal(t, tt.status, apiErr.StatusCode)
+			require.False(t, strings.TrimSpace(apiErr.Message) == "")
+			if tt.is == ErrRateLimited {
+				require.Equal(t, 7*time.Second, apiErr.RetryAfter)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package summarizer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/azalio/tg-summary/internal/telegram"
+)
+
+// PromptVersion identifies the prompts below and is stored with every digest.
+// Bump it on any prompt change so digests can be compared across revisions.
+const PromptVersion = "3"
+
+// baseSystemPrompt задаёт роль модели для всех LLM-бэкендов.
+const baseSystemPrompt = `You are an assistant that writes a digest of a Telegram group chat.
+Group the discussion by topic, mention who said what when it matters,
+and list decisions and action items separately. Do not invent facts.`
+
+// digestInstructions describes the JSON answer parsed by parseDigest.
+const digestInstructions = `Answer with a single JSON object and nothing else:
+{"overview": "2-3 sentences about the whole period",
+ "topics": [{"title": "short title", "summary": "what was discussed and decided", "message_ids": [ids of key messages]}],
+ "action_items": [{"text": "what has to be done", "owner": "who, if known", "message_ids": [ids]}]}
+Message ids are the numbers after # in the transcript.
+Attachments are shown in square brackets: mention notable shared files (e.g. "3 PDFs were shared")
+and the outcome of polls.
+Reactions, views and forwards are shown in braces: messages with many reactions or forwards
+mattered most to the chat, so cover them first and never leave them out.`
+
+// buildSystemPrompt combines the base prompt, the requested options and the answer format.
+func buildSystemPrompt(opts Options) string {
+	var b strings.Builder
+	b.WriteString(baseSystemPrompt)
+	b.WriteString("\n")
+	if opts.Language != "" {
+		fmt.Fprintf(&b, "Write the digest in language %q.\n", opts.Language)
+	} else {
+		b.WriteString("Write the digest in the language most messages are written in.\n")
+	}
+	switch opts.Style {
+	case StyleDetailed:
+		b.WriteString("Be detailed: give context for every topic and quote key messages.\n")
+	case StyleBullets:
+		b.WriteString("Be terse: one short line per topic, no prose.\n")
+	default:
+		b.WriteString("Be concise: a few sentences per topic.\n")
+	}
+	if opts.MaxLength > 0 {
+		fmt.Fprintf(&b, "Keep the whole digest under %d characters.\n", opts.MaxLength)
+	}
+	b.WriteString(digestInstructions)
+	return b.String()
+}
+
+// buildUserPrompt renders the chat header followed by the transcript.
+func buildUserPrompt(chat ChatInfo, transcript string) string {
+	var b strings.Builder
+	if chat.Title != "" {
+		fmt.Fprintf(&b, "Chat: %s", chat.Title)
+		if chat.Type != "" {
+			fmt.Fprintf(&b, " (%s)", chat.Type)
+		}
+		b.WriteString("\n")
+	}
+	if !chat.From.IsZero() && !chat.To.IsZero() {
+		fmt.Fprintf(&b, "Period: %s — %s\n", chat.From.Format(time.RFC3339), chat.To.Format(time.RFC3339))
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString(transcript)
+	return b.String()
+}
+
+// buildTranscript renders messages as one line per message:
+// "#id [time] Sender: text [attachment] {reactions}", with times in loc.
+// Replies reference the original message ID so the model can follow threads.
+func buildTranscript(messages []telegram.Message, loc *time.Location) string {
+	var b strings.Builder
+	for _, m := range messages {
+		if strings.TrimSpace(m.Text) == "" && m.Media == nil {
+			continue
+		}
+		fmt.Fprintf(&b, "#%d [%s] %s", m.ID, time.Unix(m.Timestamp, 0).In(loc).Format("2006-01-02 15:04"), m.Sender)
+		if m.ReplyToID != 0 {
+			fmt.Fprintf(&b, " (reply to #%d)", m.ReplyToID)
+		}
+		b.WriteString(": ")
+		b.WriteString(strings.ReplaceAll(m.Text, "\n", " "))
+		if m.Media != nil {
+			if m.Text != "" {
+				b.WriteByte(' ')
+			}
+			b.WriteString("[" + describeMedia(m.Media) + "]")
+		}
+		if e := describeEngagement(m); e != "" {
+			b.WriteString(" {" + e + "}")
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// describeMedia renders an attachment for the transcript, e.g.
+// "document report.pdf, application/pdf, 2.0 KB" or `poll "Friday?" (closed, 7 votes): Yes — 2, No — 5`.
+func describeMedia(m *telegram.Media) string {
+	switch m.Type {
+	case telegram.MediaWebPage:
+		if m.Title == "" {
+			return strings.TrimSpace("link " + m.URL)
+		}
+		return fmt.Sprintf("link %q %s", m.Title, m.URL)
+	case telegram.MediaPoll:
+		if m.Poll == nil {
+			return "poll"
+		}
+		state := "open"
+		if m.Poll.Closed {
+			state = "closed"
+		}
+		var opts []string
+		for _, o := range m.Poll.Options {
+			opts = append(opts, fmt.Sprintf("%s — %d", o.Text, o.Voters))
+		}
+		return fmt.Sprintf("poll %q (%s, %d votes): %s", m.Poll.Question, state, m.Poll.TotalVoters, strings.Join(opts, ", "))
+	case telegram.MediaOther:
+		return "attachment"
+	}
+	var parts []string
+	for _, p := range []string{m.FileName, m.MIMEType, formatSize(m.Size)} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return string(m.Type)
+	}
+	return string(m.Type) + " " + strings.Join(parts, ", ")
+}
+
+// describeEngagement renders reactions and channel counters, e.g.
+// "reactions: 👍 5, 🔥 2; views: 120; forwards: 3"; empty when there are none.
+func describeEngagement(m telegram.Message) string {
+	var parts []string
+	if len(m.Reactions) > 0 {
+		var rs []string
+		for _, r := range m.Reactions {
+			rs = append(rs, fmt.Sprintf("%s %d", r.Display(), r.Count))
+		}
+		parts = append(parts, "reactions: "+strings.Join(rs, ", "))
+	}
+	if m.Views > 0 {
+		parts = append(parts, fmt.Sprintf("views: %d", m.Views))
+	}
+	if m.Forwards > 0 {
+		parts = append(parts, fmt.Sprintf("forwards: %d", m.Forwards))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// formatSize renders a byte count as "512 B", "2.0 KB", "1.5 MB"; empty for 0.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n <= 0 {
+		return ""
+	}
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, suffix := float64(n)/unit, "KB"
+	for _, next := range []string{"MB", "GB"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, next
+	}
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
@@ -0,0 +1,47 @@
+package summarizer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/azalio/tg-summary/internal/telegram"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildTranscript_Media(t *testing.T) {
+	poll := &telegram.Poll{
+		Question:    "Deploy on Friday?",
+		Options:     []telegram.PollOption{{Text: "Yes", Voters: 2}, {Text: "No", Voters: 5}},
+		TotalVoters: 7,
+		Closed:      true,
+	}
+	messages := []telegram.Message{
+		{ID: 1, Sender: "Ivan", Text: "Q3 report", Timestamp: 0,
+			Media: &telegram.Media{Type: telegram.MediaDocument, FileName: "report.pdf", MIMEType: "application/pdf", Size: 1536}},
+		{ID: 2, Sender: "Olga", Timestamp: 60, Media: &telegram.Media{Type: telegram.MediaPoll, Poll: poll}},
+		{ID: 3, Sender: "Petr", Text: "see", Timestamp: 120, Media: &telegram.Media{Type: telegram.MediaWebPage, URL: "https://go.dev", Title: "Go"}},
+		{ID: 4, Sender: "Anna", Timestamp: 180, Media: &telegram.Media{Type: telegram.MediaPhoto}},
+		{ID: 5, Sender: "Anna", Timestamp: 240},
+	}
+	require.Equal(t, "#1 [1970-01-01 00:00] Ivan: Q3 report [document report.pdf, application/pdf, 1.5 KB]\n"+
+		"#2 [1970-01-01 00:01] Olga: [poll \"Deploy on Friday?\" (closed, 7 votes): Yes — 2, No — 5]\n"+
+		"#3 [1970-01-01 00:02] Petr: see [link \"Go\" https://go.dev]\n"+
+		"#4 [1970-01-01 00:03] Anna: [photo]\n", buildTranscript(messages, time.UTC))
+}
+
+func TestBuildTranscript_Engagement(t *testing.T) {
+	messages := []telegram.Message{
+		{ID: 1, Sender: "News", Text: "Release 2.0 is out", Timestamp: 0, Views: 120, Forwards: 3,
+			Reactions: []telegram.Reaction{{Emoji: "👍", Count: 5}, {Emoji: "custom:42", Count: 2}, {Emoji: telegram.ReactionPaid, Count: 1}}},
+		{ID: 2, Sender: "Olga", Text: "nice", Timestamp: 60},
+	}
+	require.Equal(t, "#1 [1970-01-01 00:00] News: Release 2.0 is out {reactions: 👍 5, custom emoji 2, ⭐ 1; views: 120; forwards: 3}\n"+
+		"#2 [1970-01-01 00:01] Olga: nice\n", buildTranscript(messages, time.UTC))
+}
+
+func TestFormatSize(t *testing.T) {
+	require.Equal(t, "", formatSize(0))
+	require.Equal(t, "512 B", formatSize(512))
+	require.Equal(t, "2.0 KB", formatSize(2048))
+	require.Equal(t, "1.5 MB", formatSize(3<<19))
+}
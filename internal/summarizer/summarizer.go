@@ -1,30 +1,15 @@
 package summarizer
 
 import (
-	"fmt"
+	"context"
+
 	// Use the corrected import path
-	"github.com/azalio/tg-summary/internal/telegram" 
+	"github.com/azalio/tg-summary/internal/telegram"
 )
 
 // Summarizer defines the interface for summarizing messages.
+// Implementations must honour ctx cancellation and return an empty Digest
+// (not an error) when there is nothing to summarize.
 type Summarizer interface {
-	Summarize(messages []telegram.Message) (string, error)
-}
-
-// OpenAISummarizer is the production implementation using OpenAI API.
-type OpenAISummarizer struct {
-	// Add fields needed for OpenAI client, e.g., apiKey string, client *openai.Client
-}
-
-// NewOpenAISummarizer creates a new instance of OpenAISummarizer.
-func NewOpenAISummarizer() *OpenAISummarizer {
-	// TODO: Initialize OpenAI client here
-	return &OpenAISummarizer{}
-}
-
-// Summarize implements the Summarizer interface.
-func (s *OpenAISummarizer) Summarize(messages []telegram.Message) (string, error) {
-	// TODO: Implement summarization using OpenAI API
-	fmt.Printf("OpenAISummarizer: Summarize called with %d messages (not implemented)\n", len(messages))
-	return "Summary placeholder", nil
+	Summarize(ctx context.Context, chat ChatInfo, messages []telegram.Message, opts Options) (*Digest, error)
 }
@@ -1,6 +1,7 @@
 package summarizer
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -23,22 +24,22 @@ func NewMockSummarizer() *MockSummarizer {
 }
 
 // Summarize implements the Summarizer interface for the mock
-func (m *MockSummarizer) Summarize(messages []telegram.Message) (string, error) {
+func (m *MockSummarizer) Summarize(ctx context.Context, chat ChatInfo, messages []telegram.Message, opts Options) (*Digest, error) {
 	fmt.Printf("MockSummarizer: Summarize called with %d messages\n", len(messages))
 	if m.ExpectedError != nil {
-		return "", m.ExpectedError
+		return nil, m.ExpectedError
 	}
-	return m.ExpectedSummary, nil
+	return &Digest{Chat: chat, Overview: m.ExpectedSummary}, nil
 }
 
 // Example test using the mock (keep testing import)
 func TestSummarizerMock(t *testing.T) {
 	mockSummarizer := NewMockSummarizer()
-	summary, err := mockSummarizer.Summarize([]telegram.Message{{ID: 1}, {ID: 2}})
+	digest, err := mockSummarizer.Summarize(context.Background(), ChatInfo{}, []telegram.Message{{ID: 1}, {ID: 2}}, Options{})
 	if err != nil {
 		t.Errorf("Summarize failed: %v", err)
 	}
-	if summary != mockSummarizer.ExpectedSummary {
-		t.Errorf("Expected summary '%s', got '%s'", mockSummarizer.ExpectedSummary, summary)
+	if digest.Overview != mockSummarizer.ExpectedSummary {
+		t.Errorf("Expected summary '%s', got '%s'", mockSummarizer.ExpectedSummary, digest.Overview)
 	}
 }
\ No newline at end of file
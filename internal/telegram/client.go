@@ -9,20 +9,31 @@ import (
 	"bufio"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/gotd/td/session"
 	"github.com/gotd/td/telegram"
 	"github.com/gotd/td/telegram/auth"
+	"github.com/gotd/td/telegram/updates"
 	"github.com/gotd/td/tg"
 	"go.uber.org/zap"
 )
 
 type Message struct {
-	ID        int64
-	ChatID    int64
-	Sender    string
-	Text      string
-	Timestamp int64
+	ID             int64
+	ChatID         int64
+	SenderID       int64  // user ID, or chat/channel ID for anonymous and channel posts
+	Sender         string // display name of the author
+	SenderUsername string
+	SenderUnknown  bool // no entity for the sender came with the message; Sender is "id<N>"
+	Text           string
+	Timestamp      int64
+	ReplyToID      int64  // 0 if the message is not a reply
+	EditedAt       int64  // unix time of the last edit, 0 if never edited
+	Media          *Media // attachment; nil for text-only messages
+	Reactions      []Reaction
+	Views          int // channel posts only, 0 otherwise
+	Forwards       int
 }
 
 type GroupType string
@@ -39,12 +50,14 @@ type GroupInfo struct {
 }
 
 type TelegramClient interface {
-	Run(ctx context.Context, fn func(ctx context.Context, api *tg.Client) error) error
+	Run(ctx context.Context, fn func(ctx context.Context, api *telegram.Client) error) error
 	FetchMessages(ctx context.Context, chatID int64, from, to int64) ([]Message, error)
-	ListGroups(ctx context.Context, api *tg.Client) ([]GroupInfo, error)
+	ListGroups(ctx context.Context, api *telegram.Client) ([]GroupInfo, error)
 	SendMessage(ctx context.Context, chatID int64, text string) error
 }
 
+var _ TelegramClient = (*RealTelegramClient)(nil)
+
 // RealTelegramClient is the production implementation of TelegramClient
 type RealTelegramClient struct {
 	client     *telegram.Client
@@ -53,6 +66,11 @@ type RealTelegramClient struct {
 	appHash    string
 	phone      string
 	log        applog.Logger
+
+	sink       UpdateSink // nil disables real-time updates
+
+	mu    sync.Mutex
+	peers map[int64]tg.InputPeerClass // chat ID -> input peer with access hash
 }
 
 // NewRealTelegramClient creates a new instance of RealTelegramClient using injected config and logger.
@@ -64,6 +82,7 @@ func NewRealTelegramClient(logger applog.Logger, cfg *config.Config) (*RealTeleg
 		appHash:    cfg.TelegramAppHash,
 		phone:      cfg.TelegramPhone,
 		sessionDir: cfg.TelegramSessionDir,
+		peers:      make(map[int64]tg.InputPeerClass),
 	}, nil
 }
 
@@ -76,10 +95,16 @@ func (c *RealTelegramClient) Run(ctx context.Context, fn func(ctx context.Contex
 	sessionFile := filepath.Join(c.sessionDir, "session.json")
 	storage := &session.FileStorage{Path: sessionFile}
 
-	client := telegram.NewClient(c.appID, c.appHash, telegram.Options{
+	opts := telegram.Options{
 		SessionStorage: storage,
 		Logger:         zap.NewNop(), // gotd expects zap.Logger, but we use our own for app logs
-	})
+	}
+	var gaps *updates.Manager
+	if c.sink != nil {
+		gaps = c.newUpdatesManager(filepath.Join(c.sessionDir, "updates.json"))
+		opts.UpdateHandler = gaps
+	}
+	client := telegram.NewClient(c.appID, c.appHash, opts)
 
 	// Run client and execute user callback
 	err := client.Run(ctx, func(ctx context.Context) error {
@@ -107,20 +132,63 @@ func (c *RealTelegramClient) Run(ctx context.Context, fn func(ctx context.Contex
 			return err
 		}
 		c.log.Info("Telegram authorization successful")
-		return fn(ctx, client)
+		c.setClient(client)
+		defer c.setClient(nil)
+		if gaps == nil {
+			return fn(ctx, client)
+		}
+		return c.runWithUpdates(ctx, client, gaps, fn)
 	})
 	if err != nil {
+		if ctx.Err() != nil {
+			c.log.Info("Telegram client stopped", zap.Error(err))
+			return nil
+		}
 		c.log.Error("Telegram client run failed", zap.Error(err))
 		return err
 	}
 	return nil
 }
 
-// FetchMessages implements the TelegramClient interface
-func (c *RealTelegramClient) FetchMessages(ctx context.Context, chatID int64, from, to int64) ([]Message, error) {
-	// TODO: Implement real message fetching logic
-	fmt.Printf("RealTelegramClient: FetchMessages called for chat %d (not implemented)\n", chatID)
-	return []Message{}, nil
+// runWithUpdates runs fn while the updates manager is receiving updates and
+// keeps listening after fn returns, until ctx is cancelled.
+func (c *RealTelegramClient) runWithUpdates(ctx context.Context, client *telegram.Client, gaps *updates.Manager, fn func(ctx context.Context, api *telegram.Client) error) error {
+	self, err := client.Self(ctx)
+	if err != nil {
+		return fmt.Errorf("get self: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- gaps.Run(ctx, client.API(), self.ID, updates.AuthOptions{})
+	}()
+
+	if err := fn(ctx, client); err != nil {
+		cancel()
+		<-done
+		return err
+	}
+	c.log.Info("Listening for real-time updates")
+	return <-done
+}
+
+// setClient stores the authorized client so that API methods can be used during Run.
+func (c *RealTelegramClient) setClient(client *telegram.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.client = client
+}
+
+// api returns the raw API of the running client.
+func (c *RealTelegramClient) api() (*tg.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client == nil {
+		return nil, ErrNotRunning
+	}
+	return c.client.API(), nil
 }
 
 // ListGroups implements the TelegramClient interface
@@ -158,6 +226,7 @@ func (c *RealTelegramClient) ListGroups(ctx context.Context, api *telegram.Clien
 				continue
 			}
 			if chat.ID != 0 && chat.Title != "" {
+				c.rememberPeer(chat.ID, &tg.InputPeerChat{ChatID: chat.ID})
 				groups = append(groups, GroupInfo{
 					ChatID: int64(chat.ID),
 					Title:  chat.Title,
@@ -166,6 +235,7 @@ func (c *RealTelegramClient) ListGroups(ctx context.Context, api *telegram.Clien
 			}
 		case *tg.Channel:
 			if chat.Megagroup {
+				c.rememberPeer(chat.ID, chat.AsInputPeer())
 				groups = append(groups, GroupInfo{
 					ChatID: chat.ID,
 					Title:  chat.Title,
@@ -178,9 +248,3 @@ func (c *RealTelegramClient) ListGroups(ctx context.Context, api *telegram.Clien
 	return groups, nil
 }
 
-// SendMessage implements the TelegramClient interface
-func (c *RealTelegramClient) SendMessage(ctx context.Context, chatID int64, text string) error {
-	// TODO: Implement real message sending logic
-	fmt.Printf("RealTelegramClient: SendMessage called for chat %d (not implemented)\n", chatID)
-	return nil
-}
@@ -0,0 +1,345 @@
+package telegram
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/gotd/td/tg"
+)
+
+// MaxMessageLength is the Telegram limit for one text message, in UTF-16 code units.
+const MaxMessageLength = 4096
+
+type entityKind int
+
+const (
+	entityBold entityKind = iota
+	entityItalic
+	entityCode
+	entityPre
+	entityTextURL
+)
+
+// entity is a formatting span over rune offsets [start, end) of the plain text.
+type entity struct {
+	kind  entityKind
+	start int
+	end   int
+	url   string // entityTextURL
+	lang  string // entityPre
+}
+
+// formattedText is plain text with formatting entities, ready for messages.sendMessage.
+type formattedText struct {
+	Text     string
+	Entities []tg.MessageEntityClass
+}
+
+// formatMessage converts Markdown-ish text into Telegram messages: the markup is
+// replaced with entities and the result is split into parts of at most limit
+// UTF-16 code units.
+func formatMessage(text string, limit int) []formattedText {
+	runes, ents := parseMarkdown(text)
+	var parts []formattedText
+	for _, c := range splitFormatted(runes, ents, limit) {
+		parts = append(parts, c.toTG())
+	}
+	return parts
+}
+
+// parseMarkdown recognizes the markup produced by digests: **bold**, _italic_,
+// `code`, ```pre``` blocks and [text](url) links. A backslash escapes a markup
+// character; markers without a closing pair are kept as literal text.
+func parseMarkdown(s string) ([]rune, []entity) {
+	in := []rune(s)
+	out := make([]rune, 0, len(in))
+	var ents []entity
+
+	type open struct {
+		entity
+		closeAt int // index of ']' for links
+		urlEnd  int // index of ')' for links
+	}
+	var stack []open
+	find := func(kind entityKind) int {
+		for k := len(stack) - 1; k >= 0; k-- {
+			if stack[k].kind == kind {
+				return k
+			}
+		}
+		return -1
+	}
+	closeAt := func(k int) {
+		e := stack[k].entity
+		e.end = len(out)
+		if e.end > e.start {
+			ents = append(ents, e)
+		}
+		stack = append(stack[:k], stack[k+1:]...)
+	}
+
+	for i := 0; i < len(in); {
+		r := in[i]
+		switch {
+		case r == '\\' && i+1 < len(in) && strings.ContainsRune("\\*_`[]()", in[i+1]):
+			out = append(out, in[i+1])
+			i += 2
+			continue
+
+		case hasRunesAt(in, i, "```"):
+			if end := indexRunes(in, i+3, "```"); end >= 0 {
+				body := in[i+3 : end]
+				lang := ""
+				if nl := indexRunes(body, 0, "\n"); nl >= 0 && !strings.ContainsFunc(string(body[:nl]), unicode.IsSpace) {
+					lang, body = string(body[:nl]), body[nl+1:]
+				}
+				for len(body) > 0 && body[len(body)-1] == '\n' {
+					body = body[:len(body)-1]
+				}
+				start := len(out)
+				out = append(out, body...)
+				if len(body) > 0 {
+					ents = append(ents, entity{kind: entityPre, start: start, end: len(out), lang: lang})
+				}
+				i = end + 3
+				continue
+			}
+
+		case r == '`':
+			if end := indexRunes(in, i+1, "`"); end > i+1 {
+				start := len(out)
+				out = append(out, in[i+1:end]...)
+				ents = append(ents, entity{kind: entityCode, start: start, end: len(out)})
+				i = end + 1
+				continue
+			}
+
+		case hasRunesAt(in, i, "**"):
+			if k := find(entityBold); k >= 0 {
+				closeAt(k)
+				i += 2
+				continue
+			}
+			if indexRunes(in, i+2, "**") > i+2 {
+				stack = append(stack, open{entity: entity{kind: entityBold, start: len(out)}})
+				i += 2
+				continue
+			}
+
+		case r == '_':
+			// Underscores inside words (snake_case, URLs) are literal.
+			if k := find(entityItalic); k >= 0 && !isWordRune(runeAt(in, i+1)) {
+				closeAt(k)
+				i++
+				continue
+			}
+			if !isWordRune(runeAt(in, i-1)) && italicCloses(in, i+1) {
+				stack = append(stack, open{entity: entity{kind: entityItalic, start: len(out)}})
+				i++
+				continue
+			}
+
+		case r == '[':
+			if textEnd, url, urlEnd, ok := linkAhead(in, i); ok {
+				stack = append(stack, open{
+					entity:  entity{kind: entityTextURL, start: len(out), url: url},
+					closeAt: textEnd,
+					urlEnd:  urlEnd,
+				})
+				i++
+				continue
+			}
+
+		case r == ']':
+			if k := find(entityTextURL); k >= 0 && stack[k].closeAt == i {
+				next := stack[k].urlEnd + 1
+				closeAt(k)
+				i = next
+				continue
+			}
+		}
+		out = append(out, r)
+		i++
+	}
+
+	sort.SliceStable(ents, func(a, b int) bool {
+		if ents[a].start != ents[b].start {
+			return ents[a].start < ents[b].start
+		}
+		return ents[a].end > ents[b].end // outer entity first
+	})
+	return out, ents
+}
+
+// linkAhead checks for "[text](url)" starting at in[i] == '['.
+func linkAhead(in []rune, i int) (textEnd int, url string, urlEnd int, ok bool) {
+	textEnd = indexRunes(in, i+1, "](")
+	if textEnd < 0 || textEnd == i+1 || indexRunes(in[i+1:textEnd], 0, "\n") >= 0 {
+		return 0, "", 0, false
+	}
+	urlEnd = indexRunes(in, textEnd+2, ")")
+	if urlEnd < 0 {
+		return 0, "", 0, false
+	}
+	url = string(in[textEnd+2 : urlEnd])
+	if url == "" || strings.ContainsFunc(url, unicode.IsSpace) {
+		return 0, "", 0, false
+	}
+	return textEnd, url, urlEnd, true
+}
+
+// italicCloses reports whether an italic marker opened before in[from] is closed
+// on the same line by an underscore that ends a word.
+func italicCloses(in []rune, from int) bool {
+	for j := from; j < len(in) && in[j] != '\n'; j++ {
+		if in[j] == '_' && j > from && !isWordRune(runeAt(in, j+1)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isWordRune(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+func runeAt(in []rune, i int) rune {
+	if i < 0 || i >= len(in) {
+		return 0
+	}
+	return in[i]
+}
+
+func hasRunesAt(in []rune, i int, s string) bool {
+	for _, r := range s {
+		if i >= len(in) || in[i] != r {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+func indexRunes(in []rune, from int, s string) int {
+	for i := from; i < len(in); i++ {
+		if hasRunesAt(in, i, s) {
+			return i
+		}
+	}
+	return -1
+}
+
+// chunk is one outgoing message: a slice of the plain text with entities
+// shifted to the slice.
+type chunk struct {
+	text []rune
+	ents []entity
+}
+
+// splitFormatted splits text into chunks of at most limit UTF-16 code units.
+// Cuts prefer paragraph breaks, then line breaks, then spaces, and avoid falling
+// inside an entity; only an entity longer than a whole chunk is cut, and then it
+// continues in the next chunk.
+func splitFormatted(text []rune, ents []entity, limit int) []chunk {
+	var chunks []chunk
+	pos := 0
+	for {
+		pos = skipSpace(text, pos)
+		if pos >= len(text) {
+			return chunks
+		}
+		end := fitUTF16(text, pos, limit)
+		if end < len(text) {
+			end = cutPoint(text, ents, pos, end)
+		}
+		chunks = append(chunks, makeChunk(text, ents, pos, trimSpaceEnd(text, pos, end)))
+		pos = end
+	}
+}
+
+// fitUTF16 returns the largest end such that text[pos:end] fits into limit UTF-16 units.
+func fitUTF16(text []rune, pos, limit int) int {
+	n := 0
+	for i := pos; i < len(text); i++ {
+		n += utf16.RuneLen(text[i])
+		if n > limit {
+			return i
+		}
+	}
+	return len(text)
+}
+
+// cutPoint picks the best place to end a chunk in (pos, end]. Separators in the
+// second half of the chunk are preferred so that chunks do not get too short.
+func cutPoint(text []rune, ents []entity, pos, end int) int {
+	insideEntity := func(cut int) bool {
+		for _, e := range ents {
+			if e.start < cut && cut < e.end {
+				return true
+			}
+		}
+		return false
+	}
+	for _, low := range []int{pos + (end-pos)/2, pos} {
+		for _, sep := range []string{"\n\n", "\n", " "} {
+			for i := end - len([]rune(sep)); i > low; i-- {
+				if hasRunesAt(text, i, sep) && !insideEntity(i) {
+					return i
+				}
+			}
+		}
+	}
+	return end
+}
+
+func makeChunk(text []rune, ents []entity, start, end int) chunk {
+	c := chunk{text: text[start:end]}
+	for _, e := range ents {
+		s, en := max(e.start, start), min(e.end, end)
+		if s >= en {
+			continue
+		}
+		e.start, e.end = s-start, en-start
+		c.ents = append(c.ents, e)
+	}
+	return c
+}
+
+func skipSpace(text []rune, pos int) int {
+	for pos < len(text) && unicode.IsSpace(text[pos]) {
+		pos++
+	}
+	return pos
+}
+
+func trimSpaceEnd(text []rune, start, end int) int {
+	for end > start && unicode.IsSpace(text[end-1]) {
+		end--
+	}
+	return end
+}
+
+// toTG converts rune offsets into the UTF-16 offsets Telegram expects.
+func (c chunk) toTG() formattedText {
+	offsets := make([]int, len(c.text)+1)
+	for i, r := range c.text {
+		offsets[i+1] = offsets[i] + utf16.RuneLen(r)
+	}
+	res := formattedText{Text: string(c.text)}
+	for _, e := range c.ents {
+		off, length := offsets[e.start], offsets[e.end]-offsets[e.start]
+		switch e.kind {
+		case entityBold:
+			res.Entities = append(res.Entities, &tg.MessageEntityBold{Offset: off, Length: length})
+		case entityItalic:
+			res.Entities = append(res.Entities, &tg.MessageEntityItalic{Offset: off, Length: length})
+		case entityCode:
+			res.Entities = append(res.Entities, &tg.MessageEntityCode{Offset: off, Length: length})
+		case entityPre:
+			res.Entities = append(res.Entities, &tg.MessageEntityPre{Offset: off, Length: length, Language: e.lang})
+		case entityTextURL:
+			res.Entities = append(res.Entities, &tg.MessageEntityTextURL{Offset: off, Length: length, URL: e.url})
+		}
+	}
+	return res
+}
@@ -0,0 +1,89 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatMessage_Entities(t *testing.T) {
+	parts := formatMessage("**Чат** — _итоги_ дня\n• см. [#12](https://t.me/c/1/12) и `go test`, snake_case_name", MaxMessageLength)
+	require.Len(t, parts, 1)
+	require.Equal(t, "Чат — итоги дня\n• см. #12 и go test, snake_case_name", parts[0].Text)
+	require.Equal(t, []tg.MessageEntityClass{
+		&tg.MessageEntityBold{Offset: 0, Length: 3},
+		&tg.MessageEntityItalic{Offset: 6, Length: 5},
+		&tg.MessageEntityTextURL{Offset: 22, Length: 3, URL: "https://t.me/c/1/12"},
+		&tg.MessageEntityCode{Offset: 28, Length: 7},
+	}, parts[0].Entities)
+}
+
+func TestFormatMessage_NestedPreAndEscapes(t *testing.T) {
+	parts := formatMessage("**see [docs](https://x.io)** \\*not bold\\* 2*3 **open\n```go\nfmt.Println()\n```", MaxMessageLength)
+	require.Len(t, parts, 1)
+	require.Equal(t, "see docs *not bold* 2*3 **open\nfmt.Println()", parts[0].Text)
+	require.Equal(t, []tg.MessageEntityClass{
+		&tg.MessageEntityBold{Offset: 0, Length: 8},
+		&tg.MessageEntityTextURL{Offset: 4, Length: 4, URL: "https://x.io"},
+		&tg.MessageEntityPre{Offset: 31, Length: 13, Language: "go"},
+	}, parts[0].Entities)
+}
+
+func TestFormatMessage_UTF16Offsets(t *testing.T) {
+	// The emoji takes two UTF-16 code units.
+	parts := formatMessage("🔥 **hot**", MaxMessageLength)
+	require.Equal(t, []tg.MessageEntityClass{&tg.MessageEntityBold{Offset: 3, Length: 3}}, parts[0].Entities)
+}
+
+func TestFormatMessage_SplitsOnParagraphs(t *testing.T) {
+	para := func(n int) string { return "**" + strings.Repeat("a", n) + "** tail" }
+	text := para(40) + "\n\n" + para(40) + "\n\n" + para(40)
+	parts := formatMessage(text, 100)
+	require.Len(t, parts, 2)
+	require.Equal(t, strings.Repeat("a", 40)+" tail\n\n"+strings.Repeat("a", 40)+" tail", parts[0].Text)
+	require.Equal(t, strings.Repeat("a", 40)+" tail", parts[1].Text)
+	require.Equal(t, []tg.MessageEntityClass{
+		&tg.MessageEntityBold{Offset: 0, Length: 40},
+		&tg.MessageEntityBold{Offset: 47, Length: 40},
+	}, parts[0].Entities)
+	require.Equal(t, []tg.MessageEntityClass{&tg.MessageEntityBold{Offset: 0, Length: 40}}, parts[1].Entities)
+}
+
+func TestFormatMessage_DoesNotCutInsideEntity(t *testing.T) {
+	text := "intro " + "[" + strings.Repeat("word ", 6) + "](https://x.io)" + " outro"
+	parts := formatMessage(text, 32)
+	require.Len(t, parts, 3)
+	require.Equal(t, "intro", parts[0].Text)
+	require.Equal(t, strings.TrimSpace(strings.Repeat("word ", 6)), parts[1].Text)
+	require.Equal(t, []tg.MessageEntityClass{&tg.MessageEntityTextURL{Offset: 0, Length: 29, URL: "https://x.io"}}, parts[1].Entities)
+	require.Equal(t, "outro", parts[2].Text)
+}
+
+func TestFormatMessage_LongEntityContinues(t *testing.T) {
+	parts := formatMessage("**"+strings.Repeat("б", 150)+"**", 100)
+	require.Len(t, parts, 2)
+	require.Equal(t, []tg.MessageEntityClass{&tg.MessageEntityBold{Offset: 0, Length: 100}}, parts[0].Entities)
+	require.Equal(t, []tg.MessageEntityClass{&tg.MessageEntityBold{Offset: 0, Length: 50}}, parts[1].Entities)
+}
+
+func TestFormatMessage_RespectsLimit(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 400; i++ {
+		b.WriteString("• **Тема** — обсуждение 😀 деталей [#1](https://t.me/c/1/1)\n")
+		if i%7 == 0 {
+			b.WriteString("\n")
+		}
+	}
+	parts := formatMessage(b.String(), MaxMessageLength)
+	require.Greater(t, len(parts), 1)
+	for _, p := range parts {
+		n := len(utf16.Encode([]rune(p.Text)))
+		require.LessOrEqual(t, n, MaxMessageLength)
+		for _, e := range p.Entities {
+			require.LessOrEqual(t, e.GetOffset()+e.GetLength(), n)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gotd/td/telegram/query/dialogs"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// historyPageSize is the number of messages requested per messages.getHistory call.
+// Telegram caps the limit at 100.
+const historyPageSize = 100
+
+// ErrNotRunning is returned when an API method is called outside of Run.
+var ErrNotRunning = errors.New("telegram client is not running")
+
+// SavedMessagesChatID addresses the account's own "Saved Messages" chat in SendMessage.
+const SavedMessagesChatID int64 = 0
+
+// ErrUnknownChat is returned when a chat ID cannot be resolved to an input peer.
+var ErrUnknownChat = errors.New("chat not found among dialogs")
+
+// FetchMessages implements the TelegramClient interface.
+// It pages through the chat history and returns messages with from <= date < to,
+// ordered from oldest to newest. A zero `to` means "up to now".
+// Must be called from within the Run callback.
+func (c *RealTelegramClient) FetchMessages(ctx context.Context, chatID int64, from, to int64) ([]Message, error) {
+	api, err := c.api()
+	if err != nil {
+		return nil, err
+	}
+	peer, err := c.resolvePeer(ctx, api, chatID)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := fetchHistory(ctx, api, peer, chatID, from, to)
+	if err != nil {
+		c.log.Error("Failed to fetch history", zap.Int64("chat_id", chatID), zap.Error(err))
+		return nil, err
+	}
+	c.log.Debug("Fetched history",
+		zap.Int64("chat_id", chatID),
+		zap.Int64("from", from),
+		zap.Int64("to", to),
+		zap.Int("count", len(msgs)),
+	)
+	return msgs, nil
+}
+
+// fetchHistory walks messages.getHistory from `to` backwards until `from` is reached.
+// Basic groups and supergroups share the same method, only the input peer differs.
+func fetchHistory(ctx context.Context, api *tg.Client, peer tg.InputPeerClass, chatID int64, from, to int64) ([]Message, error) {
+	var result []Message
+	offsetID := 0
+	offsetDate := 0
+	if to > 0 {
+		offsetDate = int(to)
+	}
+
+	for {
+		res, err := api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
+			Peer:       peer,
+			OffsetID:   offsetID,
+			OffsetDate: offsetDate,
+			Limit:      historyPageSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("get history (offset_id=%d): %w", offsetID, err)
+		}
+
+		page, ok := res.(tg.ModifiedMessagesMessages)
+		if !ok {
+			// messages.messagesNotModified: nothing more to read.
+			break
+		}
+		batch := page.GetMessages()
+		if len(batch) == 0 {
+			break
+		}
+		users, chats := indexEntities(page.GetUsers(), page.GetChats())
+
+		reachedFrom := false
+		for _, raw := range batch {
+			// Track the lowest ID to continue from, even for service messages.
+			if id := raw.GetID(); offsetID == 0 || id < offsetID {
+				offsetID = id
+			}
+			m, ok := raw.(*tg.Message)
+			if !ok {
+				continue // service and empty messages carry no user text
+			}
+			if int64(m.Date) < from {
+				reachedFrom = true
+				continue
+			}
+			if to > 0 && int64(m.Date) >= to {
+				continue
+			}
+			result = append(result, convertMessage(m, chatID, users, chats))
+		}
+
+		// Once an offset ID is known the date offset must not be applied again.
+		offsetDate = 0
+		if _, full := res.(*tg.MessagesMessages); full || reachedFrom || len(batch) < historyPageSize {
+			break
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Timestamp != result[j].Timestamp {
+			return result[i].Timestamp < result[j].Timestamp
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+// indexEntities builds ID lookups for the users and chats attached to a history page.
+func indexEntities(users []tg.UserClass, chats []tg.ChatClass) (map[int64]*tg.User, map[int64]tg.ChatClass) {
+	userByID := make(map[int64]*tg.User, len(users))
+	for _, u := range users {
+		if user, ok := u.(*tg.User); ok {
+			userByID[user.ID] = user
+		}
+	}
+	chatByID := make(map[int64]tg.ChatClass, len(chats))
+	for _, ch := range chats {
+		chatByID[ch.GetID()] = ch
+	}
+	return userByID, chatByID
+}
+
+// convertMessage maps a raw Telegram message to our Message, resolving the sender.
+func convertMessage(m *tg.Message, chatID int64, users map[int64]*tg.User, chats map[int64]tg.ChatClass) Message {
+	msg := Message{
+		ID:        int64(m.ID),
+		ChatID:    chatID,
+		Text:      m.Message,
+		Timestamp: int64(m.Date),
+		Media:     convertMedia(m.Media),
+		Reactions: convertReactions(m.Reactions),
+		Views:     m.Views,
+		Forwards:  m.Forwards,
+	}
+	if date, ok := m.GetEditDate(); ok {
+		msg.EditedAt = int64(date)
+	}
+	if reply, ok := m.ReplyTo.(*tg.MessageReplyHeader); ok {
+		if id, ok := reply.GetReplyToMsgID(); ok {
+			msg.ReplyToID = int64(id)
+		}
+	}
+
+	from, ok := m.GetFromID()
+	if !ok {
+		// Channel posts and anonymous admins have no from_id: the chat itself is the author.
+		from = m.PeerID
+	}
+	switch p := from.(type) {
+	case *tg.PeerUser:
+		msg.SenderID = p.UserID
+		if u, ok := users[p.UserID]; ok {
+			msg.Sender = displayName(u)
+			msg.SenderUsername = u.Username
+		}
+	case *tg.PeerChat:
+		msg.SenderID = p.ChatID
+		msg.Sender = chatTitle(chats[p.ChatID])
+	case *tg.PeerChannel:
+		msg.SenderID = p.ChannelID
+		msg.Sender = chatTitle(chats[p.ChannelID])
+	}
+	if msg.Sender == "" {
+		msg.Sender = fmt.Sprintf("id%d", msg.SenderID)
+		msg.SenderUnknown = true
+	}
+	return msg
+}
+
+// displayName returns "First Last", falling back to the username.
+func displayName(u *tg.User) string {
+	name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+	if name == "" {
+		name = u.Username
+	}
+	return name
+}
+
+func chatTitle(ch tg.ChatClass) string {
+	switch c := ch.(type) {
+	case *tg.Chat:
+		return c.Title
+	case *tg.Channel:
+		return c.Title
+	case *tg.ChatForbidden:
+		return c.Title
+	case *tg.ChannelForbidden:
+		return c.Title
+	}
+	return ""
+}
+
+// resolvePeer returns the input peer for a chat ID, loading dialogs on a cache miss.
+// SavedMessagesChatID resolves to the account itself.
+func (c *RealTelegramClient) resolvePeer(ctx context.Context, api *tg.Client, chatID int64) (tg.InputPeerClass, error) {
+	if chatID == SavedMessagesChatID {
+		return &tg.InputPeerSelf{}, nil
+	}
+	if peer, ok := c.cachedPeer(chatID); ok {
+		return peer, nil
+	}
+	err := dialogs.NewQueryBuilder(api).GetDialogs().BatchSize(100).ForEach(ctx, func(ctx context.Context, e dialogs.Elem) error {
+		switch p := e.Peer.(type) {
+		case *tg.InputPeerChat:
+			c.rememberPeer(p.ChatID, p)
+		case *tg.InputPeerChannel:
+			c.rememberPeer(p.ChannelID, p)
+		case *tg.InputPeerUser:
+			c.rememberPeer(p.UserID, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load dialogs: %w", err)
+	}
+	if peer, ok := c.cachedPeer(chatID); ok {
+		return peer, nil
+	}
+	return nil, fmt.Errorf("%w: %d", ErrUnknownChat, chatID)
+}
+
+func (c *RealTelegramClient) cachedPeer(chatID int64) (tg.InputPeerClass, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	peer, ok := c.peers[chatID]
+	return peer, ok
+}
+
+func (c *RealTelegramClient) rememberPeer(chatID int64, peer tg.InputPeerClass) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.peers[chatID] = peer
+}
@@ -0,0 +1,97 @@
+package telegram
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgmock"
+	"github.com/stretchr/testify/require"
+)
+
+func textMessage(id, date int, fromUser int64, text string) *tg.Message {
+	m := &tg.Message{ID: id, Date: date, Message: text, PeerID: &tg.PeerChannel{ChannelID: 10}}
+	m.SetFromID(&tg.PeerUser{UserID: fromUser})
+	return m
+}
+
+func TestFetchHistory_PaginatesUntilFrom(t *testing.T) {
+	mock := tgmock.New(t)
+	api := tg.NewClient(mock)
+	peer := &tg.InputPeerChannel{ChannelID: 10, AccessHash: 42}
+	users := []tg.UserClass{&tg.User{ID: 7, FirstName: "Ivan", LastName: "Petrov", Username: "ivan"}}
+
+	// Build a full first page so the client asks for the next one.
+	first := make([]tg.MessageClass, 0, historyPageSize)
+	for i := 0; i < historyPageSize; i++ {
+		id := 300 - i
+		first = append(first, textMessage(id, 2000+id, 7, "msg"))
+	}
+	reply := textMessage(150, 2150, 7, "reply")
+	reply.SetReplyTo(&tg.MessageReplyHeader{ReplyToMsgID: 149})
+	reply.SetEditDate(2160)
+	reply.SetViews(40)
+	reply.SetReactions(tg.MessageReactions{Results: []tg.ReactionCount{{Reaction: &tg.ReactionEmoji{Emoticon: "🔥"}, Count: 2}}})
+
+	mock.ExpectFunc(func(b bin.Encoder) {
+		req := b.(*tg.MessagesGetHistoryRequest)
+		require.Equal(t, 3000, req.OffsetDate)
+		require.Zero(t, req.OffsetID)
+	}).ThenResult(&tg.MessagesChannelMessages{Messages: first, Users: users, Count: 300})
+	mock.ExpectFunc(func(b bin.Encoder) {
+		req := b.(*tg.MessagesGetHistoryRequest)
+		require.Zero(t, req.OffsetDate)
+		require.Equal(t, 201, req.OffsetID)
+	}).ThenResult(&tg.MessagesChannelMessages{
+		Messages: []tg.MessageClass{
+			textMessage(200, 2200, 7, "in range"),
+			&tg.MessageService{ID: 199, Date: 2199, PeerID: &tg.PeerChannel{ChannelID: 10}, Action: &tg.MessageActionPinMessage{}},
+			reply,
+			textMessage(100, 1000, 7, "too old"),
+		},
+		Users: users,
+		Count: 300,
+	})
+
+	msgs, err := fetchHistory(context.Background(), api, peer, 10, 2100, 3000)
+	require.NoError(t, err)
+	require.True(t, mock.AllWereMet())
+
+	// 100 from the first page (dates 2201..2300), "in range" and the reply.
+	require.Len(t, msgs, historyPageSize+2)
+	require.Equal(t, int64(150), msgs[0].ID)
+	require.Equal(t, int64(149), msgs[0].ReplyToID)
+	require.Equal(t, int64(2160), msgs[0].EditedAt)
+	require.Equal(t, 40, msgs[0].Views)
+	require.Equal(t, []Reaction{{Emoji: "🔥", Count: 2}}, msgs[0].Reactions)
+	require.Zero(t, msgs[len(msgs)-1].EditedAt)
+	require.Equal(t, int64(300), msgs[len(msgs)-1].ID)
+	require.Equal(t, "Ivan Petrov", msgs[0].Sender)
+	require.Equal(t, "ivan", msgs[0].SenderUsername)
+	require.Equal(t, int64(7), msgs[0].SenderID)
+	require.Equal(t, int64(10), msgs[0].ChatID)
+}
+
+func TestFetchHistory_ChannelPostSender(t *testing.T) {
+	mock := tgmock.New(t)
+	api := tg.NewClient(mock)
+
+	post := &tg.Message{ID: 5, Date: 100, Message: "announcement", PeerID: &tg.PeerChannel{ChannelID: 10}}
+	mock.Expect().ThenResult(&tg.MessagesMessages{
+		Messages: []tg.MessageClass{post},
+		Chats:    []tg.ChatClass{&tg.Channel{ID: 10, Title: "News", Photo: &tg.ChatPhotoEmpty{}}},
+	})
+
+	msgs, err := fetchHistory(context.Background(), api, &tg.InputPeerChat{ChatID: 10}, 10, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, "News", msgs[0].Sender)
+	require.Equal(t, int64(10), msgs[0].SenderID)
+}
+
+func TestFetchMessages_NotRunning(t *testing.T) {
+	c := &RealTelegramClient{peers: make(map[int64]tg.InputPeerClass)}
+	_, err := c.FetchMessages(context.Background(), 1, 0, 0)
+	require.ErrorIs(t, err, ErrNotRunning)
+}
@@ -0,0 +1,145 @@
+package telegram
+
+import (
+	"bytes"
+
+	"github.com/gotd/td/tg"
+)
+
+// MediaType is the kind of attachment of a message.
+type MediaType string
+
+const (
+	MediaPhoto    MediaType = "photo"
+	MediaVideo    MediaType = "video"
+	MediaDocument MediaType = "document"
+	MediaAudio    MediaType = "audio"
+	MediaVoice    MediaType = "voice"
+	MediaSticker  MediaType = "sticker"
+	MediaWebPage  MediaType = "webpage" // link preview
+	MediaPoll     MediaType = "poll"
+	MediaOther    MediaType = "other" // geo, contact, dice, games and the like
+)
+
+// Media describes the attachment of a message. Its caption is the message Text.
+type Media struct {
+	Type     MediaType
+	FileName string
+	MIMEType string
+	Size     int64  // bytes, 0 if unknown
+	URL      string // link preview URL
+	Title    string // link preview title
+	Poll     *Poll  // MediaPoll only
+}
+
+// Poll is a poll with its current results.
+type Poll struct {
+	Question    string
+	Options     []PollOption
+	TotalVoters int
+	Closed      bool
+}
+
+// PollOption is one answer of a poll. Voters is 0 until results are visible.
+type PollOption struct {
+	Text   string
+	Voters int
+}
+
+// convertMedia maps the attachment of a raw message; nil for text-only messages.
+func convertMedia(media tg.MessageMediaClass) *Media {
+	switch m := media.(type) {
+	case nil, *tg.MessageMediaEmpty:
+		return nil
+	case *tg.MessageMediaPhoto:
+		res := &Media{Type: MediaPhoto, MIMEType: "image/jpeg"}
+		if p, ok := m.Photo.(*tg.Photo); ok {
+			res.Size = largestPhotoSize(p.Sizes)
+		}
+		return res
+	case *tg.MessageMediaDocument:
+		return convertDocument(m)
+	case *tg.MessageMediaWebPage:
+		res := &Media{Type: MediaWebPage}
+		if page, ok := m.Webpage.(*tg.WebPage); ok {
+			res.URL = page.URL
+			res.Title = page.Title
+		}
+		return res
+	case *tg.MessageMediaPoll:
+		return &Media{Type: MediaPoll, Poll: convertPoll(m)}
+	default:
+		return &Media{Type: MediaOther}
+	}
+}
+
+func convertDocument(m *tg.MessageMediaDocument) *Media {
+	res := &Media{Type: MediaDocument}
+	switch {
+	case m.Voice:
+		res.Type = MediaVoice
+	case m.Video, m.Round:
+		res.Type = MediaVideo
+	}
+	doc, ok := m.Document.(*tg.Document)
+	if !ok {
+		return res
+	}
+	res.MIMEType = doc.MimeType
+	res.Size = doc.Size
+	for _, attr := range doc.Attributes {
+		switch a := attr.(type) {
+		case *tg.DocumentAttributeFilename:
+			res.FileName = a.FileName
+		case *tg.DocumentAttributeSticker:
+			res.Type = MediaSticker
+		case *tg.DocumentAttributeAudio:
+			if res.Type == MediaDocument {
+				res.Type = MediaAudio
+			}
+			if a.Voice {
+				res.Type = MediaVoice
+			}
+		case *tg.DocumentAttributeVideo:
+			if res.Type == MediaDocument {
+				res.Type = MediaVideo
+			}
+		}
+	}
+	return res
+}
+
+// convertPoll pairs answers with their vote counts by option bytes.
+func convertPoll(m *tg.MessageMediaPoll) *Poll {
+	poll := &Poll{
+		Question:    m.Poll.Question.Text,
+		TotalVoters: m.Results.TotalVoters,
+		Closed:      m.Poll.Closed,
+	}
+	for _, a := range m.Poll.Answers {
+		opt := PollOption{Text: a.Text.Text}
+		for _, r := range m.Results.Results {
+			if bytes.Equal(r.Option, a.Option) {
+				opt.Voters = r.Voters
+			}
+		}
+		poll.Options = append(poll.Options, opt)
+	}
+	return poll
+}
+
+// largestPhotoSize returns the byte size of the biggest rendition of a photo.
+func largestPhotoSize(sizes []tg.PhotoSizeClass) int64 {
+	var largest int
+	for _, s := range sizes {
+		switch p := s.(type) {
+		case *tg.PhotoSize:
+			largest = max(largest, p.Size)
+		case *tg.PhotoSizeProgressive:
+			for _, n := range p.Sizes {
+				largest = max(largest, n)
+			}
+		}
+	}
+	return int64(largest)
+}
@@ -0,0 +1,59 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertMedia(t *testing.T) {
+	pdf := &tg.Document{MimeType: "application/pdf", Size: 2048, Attributes: []tg.DocumentAttributeClass{
+		&tg.DocumentAttributeFilename{FileName: "report.pdf"},
+	}}
+	voice := &tg.Document{MimeType: "audio/ogg", Size: 300, Attributes: []tg.DocumentAttributeClass{
+		&tg.DocumentAttributeAudio{Voice: true, Duration: 3},
+	}}
+	photo := &tg.Photo{Sizes: []tg.PhotoSizeClass{
+		&tg.PhotoSize{Type: "m", Size: 100},
+		&tg.PhotoSizeProgressive{Type: "y", Sizes: []int{500, 1500}},
+	}}
+	poll := &tg.MessageMediaPoll{
+		Poll: tg.Poll{
+			Question: tg.TextWithEntities{Text: "Deploy on Friday?"},
+			Answers: []tg.PollAnswer{
+				{Text: tg.TextWithEntities{Text: "Yes"}, Option: []byte("0")},
+				{Text: tg.TextWithEntities{Text: "No"}, Option: []byte("1")},
+			},
+			Closed: true,
+		},
+		Results: tg.PollResults{
+			Results:     []tg.PollAnswerVoters{{Option: []byte("1"), Voters: 5}, {Option: []byte("0"), Voters: 2}},
+			TotalVoters: 7,
+		},
+	}
+
+	for name, tc := range map[string]struct {
+		in   tg.MessageMediaClass
+		want *Media
+	}{
+		"text only": {nil, nil},
+		"empty":     {&tg.MessageMediaEmpty{}, nil},
+		"photo":     {&tg.MessageMediaPhoto{Photo: photo}, &Media{Type: MediaPhoto, MIMEType: "image/jpeg", Size: 1500}},
+		"document": {&tg.MessageMediaDocument{Document: pdf},
+			&Media{Type: MediaDocument, FileName: "report.pdf", MIMEType: "application/pdf", Size: 2048}},
+		"voice": {&tg.MessageMediaDocument{Document: voice},
+			&Media{Type: MediaVoice, MIMEType: "audio/ogg", Size: 300}},
+		"web page": {&tg.MessageMediaWebPage{Webpage: &tg.WebPage{URL: "https://go.dev", Title: "Go"}},
+			&Media{Type: MediaWebPage, URL: "https://go.dev", Title: "Go"}},
+		"poll": {poll, &Media{Type: MediaPoll, Poll: &Poll{
+			Question:    "Deploy on Friday?",
+			Options:     []PollOption{{Text: "Yes", Voters: 2}, {Text: "No", Voters: 5}},
+			TotalVoters: 7,
+			Closed:      true,
+		}}},
+		"other": {&tg.MessageMediaGeo{Geo: &tg.GeoPoint{}}, &Media{Type: MediaOther}},
+	} {
+		require.Equal(t, tc.want, convertMedia(tc.in), name)
+	}
+}
@@ -0,0 +1,80 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gotd/td/tg"
+)
+
+// Reaction keys for reactions that are not a plain emoji.
+const (
+	ReactionPaid         = "paid"
+	reactionCustomPrefix = "custom:" // followed by the custom emoji document ID
+)
+
+// Reaction is one kind of reaction on a message with its count.
+type Reaction struct {
+	Emoji   string // the emoji, "custom:<id>" for custom emoji or ReactionPaid
+	Count   int
+	UserIDs []int64 // who reacted, when Telegram shows it (recent reactors of small groups)
+}
+
+// convertReactions maps message reactions, most frequent first as Telegram sends them.
+func convertReactions(r tg.MessageReactions) []Reaction {
+	var res []Reaction
+	for _, c := range r.Results {
+		key := reactionKey(c.Reaction)
+		if key == "" {
+			continue
+		}
+		res = append(res, Reaction{Emoji: key, Count: c.Count})
+	}
+	for _, recent := range r.RecentReactions {
+		user, ok := recent.PeerID.(*tg.PeerUser)
+		if !ok {
+			continue
+		}
+		key := reactionKey(recent.Reaction)
+		for i := range res {
+			if res[i].Emoji == key {
+				res[i].UserIDs = append(res[i].UserIDs, user.UserID)
+			}
+		}
+	}
+	return res
+}
+
+func reactionKey(r tg.ReactionClass) string {
+	switch v := r.(type) {
+	case *tg.ReactionEmoji:
+		return v.Emoticon
+	case *tg.ReactionCustomEmoji:
+		return fmt.Sprintf("%s%d", reactionCustomPrefix, v.DocumentID)
+	case *tg.ReactionPaid:
+		return ReactionPaid
+	default:
+		return ""
+	}
+}
+
+// Display renders the reaction for a reader: the emoji itself, "custom emoji" or "⭐" for paid stars.
+func (r Reaction) Display() string {
+	switch {
+	case r.Emoji == ReactionPaid:
+		return "⭐"
+	case strings.HasPrefix(r.Emoji, reactionCustomPrefix):
+		return "custom emoji"
+	default:
+		return r.Emoji
+	}
+}
+
+// TotalReactions sums the counts of all reactions on the message.
+func (m Message) TotalReactions() int {
+	total := 0
+	for _, r := range m.Reactions {
+		total += r.Count
+	}
+	return total
+}
@@ -0,0 +1,32 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertReactions(t *testing.T) {
+	thumbs := &tg.ReactionEmoji{Emoticon: "👍"}
+	got := convertReactions(tg.MessageReactions{
+		Results: []tg.ReactionCount{
+			{Reaction: thumbs, Count: 3},
+			{Reaction: &tg.ReactionCustomEmoji{DocumentID: 42}, Count: 1},
+			{Reaction: &tg.ReactionPaid{}, Count: 2},
+			{Reaction: &tg.ReactionEmpty{}, Count: 9},
+		},
+		RecentReactions: []tg.MessagePeerReaction{
+			{PeerID: &tg.PeerUser{UserID: 7}, Reaction: thumbs},
+			{PeerID: &tg.PeerChannel{ChannelID: 10}, Reaction: thumbs},
+			{PeerID: &tg.PeerUser{UserID: 8}, Reaction: thumbs},
+		},
+	})
+	require.Equal(t, []Reaction{
+		{Emoji: "👍", Count: 3, UserIDs: []int64{7, 8}},
+		{Emoji: "custom:42", Count: 1},
+		{Emoji: ReactionPaid, Count: 2},
+	}, got)
+	require.Equal(t, 6, Message{Reactions: got}.TotalReactions())
+	require.Nil(t, convertReactions(tg.MessageReactions{}))
+}
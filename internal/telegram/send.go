@@ -0,0 +1,64 @@
+package telegram
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// SendMessage implements the TelegramClient interface.
+// text may use the digest markup (**bold**, _italic_, `code`, ```pre```, [text](url)),
+// which is sent as Telegram entities; texts over MaxMessageLength are sent as several
+// messages split on paragraph boundaries. Must be called from within the Run callback.
+func (c *RealTelegramClient) SendMessage(ctx context.Context, chatID int64, text string) error {
+	api, err := c.api()
+	if err != nil {
+		return err
+	}
+	peer, err := c.resolvePeer(ctx, api, chatID)
+	if err != nil {
+		return err
+	}
+	parts, err := sendText(ctx, api, peer, text)
+	if err != nil {
+		c.log.Error("Failed to send message", zap.Int64("chat_id", chatID), zap.Int("sent_parts", parts), zap.Error(err))
+		return err
+	}
+	c.log.Debug("Message sent", zap.Int64("chat_id", chatID), zap.Int("parts", parts))
+	return nil
+}
+
+// sendText sends formatted text as one or more messages and returns how many were sent.
+func sendText(ctx context.Context, api *tg.Client, peer tg.InputPeerClass, text string) (int, error) {
+	parts := formatMessage(text, MaxMessageLength)
+	for i, part := range parts {
+		randomID, err := newRandomID()
+		if err != nil {
+			return i, err
+		}
+		_, err = api.MessagesSendMessage(ctx, &tg.MessagesSendMessageRequest{
+			Peer:      peer,
+			Message:   part.Text,
+			Entities:  part.Entities,
+			NoWebpage: true,
+			RandomID:  randomID,
+		})
+		if err != nil {
+			return i, fmt.Errorf("send part %d/%d: %w", i+1, len(parts), err)
+		}
+	}
+	return len(parts), nil
+}
+
+// newRandomID returns the client-side deduplication ID required by messages.sendMessage.
+func newRandomID() (int64, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint64(b[:])), nil
+}
@@ -0,0 +1,61 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendText_SplitsLongMessages(t *testing.T) {
+	mock := tgmock.New(t)
+	api := tg.NewClient(mock)
+	peer := &tg.InputPeerChannel{ChannelID: 10, AccessHash: 42}
+
+	text := "**" + strings.Repeat("x", 3000) + "**\n\n" + strings.Repeat("y", 3000)
+	var sent []string
+	var randomIDs []int64
+	for i := 0; i < 2; i++ {
+		mock.ExpectFunc(func(b bin.Encoder) {
+			req := b.(*tg.MessagesSendMessageRequest)
+			require.Equal(t, peer, req.Peer)
+			require.True(t, req.NoWebpage)
+			sent = append(sent, req.Message)
+			randomIDs = append(randomIDs, req.RandomID)
+			if len(sent) == 1 {
+				require.Equal(t, []tg.MessageEntityClass{&tg.MessageEntityBold{Offset: 0, Length: 3000}}, req.Entities)
+			} else {
+				require.Empty(t, req.Entities)
+			}
+		}).ThenResult(&tg.Updates{})
+	}
+
+	n, err := sendText(context.Background(), api, peer, text)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.True(t, mock.AllWereMet())
+	require.Equal(t, []string{strings.Repeat("x", 3000), strings.Repeat("y", 3000)}, sent)
+	require.NotEqual(t, randomIDs[0], randomIDs[1])
+}
+
+func TestSendText_StopsOnError(t *testing.T) {
+	mock := tgmock.New(t)
+	api := tg.NewClient(mock)
+
+	mock.Expect().ThenFlood(30)
+	n, err := sendText(context.Background(), api, &tg.InputPeerSelf{}, strings.Repeat("a ", 3000))
+	require.Error(t, err)
+	require.Zero(t, n)
+	require.Contains(t, err.Error(), "send part 1/2")
+}
+
+func TestResolvePeer_SavedMessages(t *testing.T) {
+	c := &RealTelegramClient{peers: make(map[int64]tg.InputPeerClass)}
+	peer, err := c.resolvePeer(context.Background(), nil, SavedMessagesChatID)
+	require.NoError(t, err)
+	require.Equal(t, &tg.InputPeerSelf{}, peer)
+}
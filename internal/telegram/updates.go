@@ -0,0 +1,280 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/gotd/td/telegram/updates"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+)
+
+// UpdateSink receives real-time message events for groups and supergroups.
+// Private chats and broadcast channels are filtered out before reaching the sink.
+type UpdateSink interface {
+	OnNewMessage(ctx context.Context, chat GroupInfo, msg Message) error
+	OnEditMessage(ctx context.Context, chat GroupInfo, msg Message) error
+	// OnDeleteMessages is called with chatID 0 for basic groups:
+	// Telegram does not report the chat there, message IDs are unique per account.
+	OnDeleteMessages(ctx context.Context, chatID int64, messageIDs []int64) error
+}
+
+// SetUpdateSink enables real-time updates: Run keeps listening after the callback
+// returns and pushes new, edited and deleted messages into the sink.
+// Must be called before Run.
+func (c *RealTelegramClient) SetUpdateSink(sink UpdateSink) {
+	c.sink = sink
+}
+
+// newUpdatesManager wires the dispatcher into gotd's updates manager, which
+// tracks pts/qts and fetches missed updates (getDifference) after reconnects.
+func (c *RealTelegramClient) newUpdatesManager(statePath string) *updates.Manager {
+	dispatcher := tg.NewUpdateDispatcher()
+	dispatcher.OnNewMessage(func(ctx context.Context, e tg.Entities, u *tg.UpdateNewMessage) error {
+		return c.handleMessage(ctx, e, u.Message, c.sink.OnNewMessage)
+	})
+	dispatcher.OnNewChannelMessage(func(ctx context.Context, e tg.Entities, u *tg.UpdateNewChannelMessage) error {
+		return c.handleMessage(ctx, e, u.Message, c.sink.OnNewMessage)
+	})
+	dispatcher.OnEditMessage(func(ctx context.Context, e tg.Entities, u *tg.UpdateEditMessage) error {
+		return c.handleMessage(ctx, e, u.Message, c.sink.OnEditMessage)
+	})
+	dispatcher.OnEditChannelMessage(func(ctx context.Context, e tg.Entities, u *tg.UpdateEditChannelMessage) error {
+		return c.handleMessage(ctx, e, u.Message, c.sink.OnEditMessage)
+	})
+	dispatcher.OnDeleteMessages(func(ctx context.Context, e tg.Entities, u *tg.UpdateDeleteMessages) error {
+		return c.handleDelete(ctx, 0, u.Messages)
+	})
+	dispatcher.OnDeleteChannelMessages(func(ctx context.Context, e tg.Entities, u *tg.UpdateDeleteChannelMessages) error {
+		return c.handleDelete(ctx, u.ChannelID, u.Messages)
+	})
+
+	state := newFileStateStorage(statePath)
+	return updates.New(updates.Config{
+		Handler:      dispatcher,
+		Storage:      state,
+		AccessHasher: state,
+		Logger:       zap.NewNop(),
+		OnChannelTooLong: func(channelID int64) {
+			c.log.Warn("Channel updates gap is too long, run history sync to recover", zap.Int64("chat_id", channelID))
+		},
+	})
+}
+
+// handleMessage converts a message update and forwards it to the sink.
+// Sink errors are logged and swallowed so that one bad message does not stall the stream.
+func (c *RealTelegramClient) handleMessage(
+	ctx context.Context,
+	e tg.Entities,
+	raw tg.MessageClass,
+	deliver func(ctx context.Context, chat GroupInfo, msg Message) error,
+) error {
+	m, ok := raw.(*tg.Message)
+	if !ok {
+		return nil
+	}
+	chat, ok := c.groupOf(m.PeerID, e)
+	if !ok {
+		return nil
+	}
+
+	chats := make(map[int64]tg.ChatClass, len(e.Chats)+len(e.Channels))
+	for id, ch := range e.Chats {
+		chats[id] = ch
+	}
+	for id, ch := range e.Channels {
+		chats[id] = ch
+	}
+	msg := convertMessage(m, chat.ChatID, e.Users, chats)
+
+	if err := deliver(ctx, chat, msg); err != nil {
+		c.log.Error("Failed to handle message update",
+			zap.Int64("chat_id", chat.ChatID),
+			zap.Int64("message_id", msg.ID),
+			zap.Error(err),
+		)
+	}
+	return nil
+}
+
+func (c *RealTelegramClient) handleDelete(ctx context.Context, chatID int64, ids []int) error {
+	messageIDs := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		messageIDs = append(messageIDs, int64(id))
+	}
+	if err := c.sink.OnDeleteMessages(ctx, chatID, messageIDs); err != nil {
+		c.log.Error("Failed to handle delete update", zap.Int64("chat_id", chatID), zap.Error(err))
+	}
+	return nil
+}
+
+// groupOf describes the chat a message belongs to.
+// Private chats and broadcast channels are not collected.
+func (c *RealTelegramClient) groupOf(peer tg.PeerClass, e tg.Entities) (GroupInfo, bool) {
+	switch p := peer.(type) {
+	case *tg.PeerChat:
+		info := GroupInfo{ChatID: p.ChatID, Type: GroupTypeGroup}
+		if chat, ok := e.Chats[p.ChatID]; ok {
+			info.Title = chat.Title
+		}
+		return info, true
+	case *tg.PeerChannel:
+		channel, ok := e.Channels[p.ChannelID]
+		if !ok || !channel.Megagroup {
+			return GroupInfo{}, false
+		}
+		c.rememberPeer(channel.ID, channel.AsInputPeer())
+		return GroupInfo{ChatID: channel.ID, Title: channel.Title, Type: GroupTypeSupergroup}, true
+	}
+	return GroupInfo{}, false
+}
+
+// fileStateStorage persists the updates state (pts/qts/seq and per-channel pts)
+// and channel access hashes next to the session file, so that missed updates
+// are recovered after a restart.
+type fileStateStorage struct {
+	path string
+	mu   sync.Mutex
+	data fileState
+}
+
+type fileState struct {
+	States   map[int64]updates.State   `json:"states"`
+	Channels map[int64]map[int64]int   `json:"channels"`
+	Hashes   map[int64]map[int64]int64 `json:"hashes"`
+}
+
+var (
+	_ updates.StateStorage        = (*fileStateStorage)(nil)
+	_ updates.ChannelAccessHasher = (*fileStateStorage)(nil)
+)
+
+var errStateNotFound = errors.New("updates state not found")
+
+func newFileStateStorage(path string) *fileStateStorage {
+	s := &fileStateStorage{path: path}
+	s.data.States = make(map[int64]updates.State)
+	s.data.Channels = make(map[int64]map[int64]int)
+	s.data.Hashes = make(map[int64]map[int64]int64)
+	if raw, err := os.ReadFile(path); err == nil {
+		// A corrupted file only means a full resync, so decode errors are ignored.
+		_ = json.Unmarshal(raw, &s.data)
+	}
+	return s
+}
+
+// save must be called with mu held.
+func (s *fileStateStorage) save() error {
+	raw, err := json.Marshal(s.data)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *fileStateStorage) GetState(ctx context.Context, userID int64) (updates.State, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.data.States[userID]
+	return state, ok, nil
+}
+
+func (s *fileStateStorage) SetState(ctx context.Context, userID int64, state updates.State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.States[userID] = state
+	s.data.Channels[userID] = make(map[int64]int)
+	return s.save()
+}
+
+func (s *fileStateStorage) update(userID int64, fn func(st *updates.State)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.data.States[userID]
+	if !ok {
+		return errStateNotFound
+	}
+	fn(&state)
+	s.data.States[userID] = state
+	return s.save()
+}
+
+func (s *fileStateStorage) SetPts(ctx context.Context, userID int64, pts int) error {
+	return s.update(userID, func(st *updates.State) { st.Pts = pts })
+}
+
+func (s *fileStateStorage) SetQts(ctx context.Context, userID int64, qts int) error {
+	return s.update(userID, func(st *updates.State) { st.Qts = qts })
+}
+
+func (s *fileStateStorage) SetDate(ctx context.Context, userID int64, date int) error {
+	return s.update(userID, func(st *updates.State) { st.Date = date })
+}
+
+func (s *fileStateStorage) SetSeq(ctx context.Context, userID int64, seq int) error {
+	return s.update(userID, func(st *updates.State) { st.Seq = seq })
+}
+
+func (s *fileStateStorage) SetDateSeq(ctx context.Context, userID int64, date, seq int) error {
+	return s.update(userID, func(st *updates.State) { st.Date, st.Seq = date, seq })
+}
+
+func (s *fileStateStorage) GetChannelPts(ctx context.Context, userID, channelID int64) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pts, ok := s.data.Channels[userID][channelID]
+	return pts, ok, nil
+}
+
+func (s *fileStateStorage) SetChannelPts(ctx context.Context, userID, channelID int64, pts int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	channels, ok := s.data.Channels[userID]
+	if !ok {
+		return errStateNotFound
+	}
+	channels[channelID] = pts
+	return s.save()
+}
+
+func (s *fileStateStorage) ForEachChannels(ctx context.Context, userID int64, f func(ctx context.Context, channelID int64, pts int) error) error {
+	s.mu.Lock()
+	channels := make(map[int64]int, len(s.data.Channels[userID]))
+	for id, pts := range s.data.Channels[userID] {
+		channels[id] = pts
+	}
+	s.mu.Unlock()
+
+	for id, pts := range channels {
+		if err := f(ctx, id, pts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileStateStorage) SetChannelAccessHash(ctx context.Context, userID, channelID, accessHash int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hashes, ok := s.data.Hashes[userID]
+	if !ok {
+		hashes = make(map[int64]int64)
+		s.data.Hashes[userID] = hashes
+	}
+	hashes[channelID] = accessHash
+	return s.save()
+}
+
+func (s *fileStateStorage) GetChannelAccessHash(ctx context.Context, userID, channelID int64) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hash, ok := s.data.Hashes[userID][channelID]
+	return hash, ok, nil
+}
@@ -0,0 +1,111 @@
+package telegram
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	applog "github.com/azalio/tg-summary/internal/log"
+	"github.com/gotd/td/telegram/updates"
+	"github.com/gotd/td/tg"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSink struct {
+	chats   []GroupInfo
+	msgs    []Message
+	deleted map[int64][]int64
+}
+
+func (r *recordingSink) OnNewMessage(ctx context.Context, chat GroupInfo, msg Message) error {
+	r.chats = append(r.chats, chat)
+	r.msgs = append(r.msgs, msg)
+	return nil
+}
+
+func (r *recordingSink) OnEditMessage(ctx context.Context, chat GroupInfo, msg Message) error {
+	return r.OnNewMessage(ctx, chat, msg)
+}
+
+func (r *recordingSink) OnDeleteMessages(ctx context.Context, chatID int64, messageIDs []int64) error {
+	r.deleted[chatID] = append(r.deleted[chatID], messageIDs...)
+	return nil
+}
+
+func TestHandleMessage_FiltersAndConverts(t *testing.T) {
+	logger, cleanup, err := applog.NewLogger()
+	require.NoError(t, err)
+	defer cleanup()
+	sink := &recordingSink{deleted: make(map[int64][]int64)}
+	c := &RealTelegramClient{log: logger, sink: sink, peers: make(map[int64]tg.InputPeerClass)}
+
+	super := &tg.Channel{ID: 2, Title: "Super", Megagroup: true}
+	super.SetAccessHash(99)
+	e := tg.Entities{
+		Users: map[int64]*tg.User{7: {ID: 7, FirstName: "Olga"}},
+		Chats: map[int64]*tg.Chat{1: {ID: 1, Title: "Group"}},
+		Channels: map[int64]*tg.Channel{
+			2: super,
+			3: {ID: 3, Title: "Broadcast"},
+		},
+	}
+	msg := func(peer tg.PeerClass) *tg.Message {
+		m := &tg.Message{ID: 5, Date: 100, Message: "hi", PeerID: peer}
+		m.SetFromID(&tg.PeerUser{UserID: 7})
+		return m
+	}
+
+	ctx := context.Background()
+	require.NoError(t, c.handleMessage(ctx, e, msg(&tg.PeerChat{ChatID: 1}), sink.OnNewMessage))
+	require.NoError(t, c.handleMessage(ctx, e, msg(&tg.PeerChannel{ChannelID: 2}), sink.OnNewMessage))
+	require.NoError(t, c.handleMessage(ctx, e, msg(&tg.PeerChannel{ChannelID: 3}), sink.OnNewMessage))
+	require.NoError(t, c.handleMessage(ctx, e, msg(&tg.PeerUser{UserID: 7}), sink.OnNewMessage))
+
+	require.Equal(t, []GroupInfo{
+		{ChatID: 1, Title: "Group", Type: GroupTypeGroup},
+		{ChatID: 2, Title: "Super", Type: GroupTypeSupergroup},
+	}, sink.chats)
+	require.Equal(t, "Olga", sink.msgs[1].Sender)
+	require.False(t, sink.msgs[1].SenderUnknown)
+	require.Equal(t, int64(2), sink.msgs[1].ChatID)
+
+	// Updates may come without the sender entity.
+	require.NoError(t, c.handleMessage(ctx, tg.Entities{Chats: e.Chats}, msg(&tg.PeerChat{ChatID: 1}), sink.OnNewMessage))
+	require.Equal(t, "id7", sink.msgs[2].Sender)
+	require.True(t, sink.msgs[2].SenderUnknown)
+
+	peer, ok := c.cachedPeer(2)
+	require.True(t, ok)
+	require.Equal(t, int64(99), peer.(*tg.InputPeerChannel).AccessHash)
+
+	require.NoError(t, c.handleDelete(ctx, 2, []int{5, 6}))
+	require.Equal(t, []int64{5, 6}, sink.deleted[2])
+}
+
+func TestFileStateStorage_Persists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "updates.json")
+
+	s := newFileStateStorage(path)
+	require.ErrorIs(t, s.SetPts(ctx, 1, 10), errStateNotFound)
+	require.NoError(t, s.SetState(ctx, 1, updates.State{Pts: 1, Qts: 2, Date: 3, Seq: 4}))
+	require.NoError(t, s.SetPts(ctx, 1, 10))
+	require.NoError(t, s.SetChannelPts(ctx, 1, 2, 50))
+	require.NoError(t, s.SetChannelAccessHash(ctx, 1, 2, 99))
+
+	reloaded := newFileStateStorage(path)
+	state, ok, err := reloaded.GetState(ctx, 1)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, updates.State{Pts: 10, Qts: 2, Date: 3, Seq: 4}, state)
+
+	pts, ok, err := reloaded.GetChannelPts(ctx, 1, 2)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 50, pts)
+
+	hash, ok, err := reloaded.GetChannelAccessHash(ctx, 1, 2)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, int64(99), hash)
+}